@@ -486,12 +486,12 @@ func (w Inode_attributes_Which) String() string {
 const Inode_TypeID = 0xc0029f81b3eee594
 
 func NewInode(s *capnp.Segment) (Inode, error) {
-	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 24, PointerCount: 3})
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 24, PointerCount: 4})
 	return Inode{st}, err
 }
 
 func NewRootInode(s *capnp.Segment) (Inode, error) {
-	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 24, PointerCount: 3})
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 24, PointerCount: 4})
 	return Inode{st}, err
 }
 
@@ -704,12 +704,37 @@ func (s Inode) SetCreationTime(v uint32) {
 	s.Struct.SetUint32(16, v)
 }
 
+func (s Inode) Xattrs() (XAttr_List, error) {
+	p, err := s.Struct.Ptr(3)
+	return XAttr_List{List: p.List()}, err
+}
+
+func (s Inode) HasXattrs() bool {
+	p, err := s.Struct.Ptr(3)
+	return p.IsValid() || err != nil
+}
+
+func (s Inode) SetXattrs(v XAttr_List) error {
+	return s.Struct.SetPtr(3, v.List.ToPtr())
+}
+
+// NewXattrs sets the xattrs field to a newly
+// allocated XAttr_List, preferring placement in s's segment.
+func (s Inode) NewXattrs(n int32) (XAttr_List, error) {
+	l, err := NewXAttr_List(s.Struct.Segment(), n)
+	if err != nil {
+		return XAttr_List{}, err
+	}
+	err = s.Struct.SetPtr(3, l.List.ToPtr())
+	return l, err
+}
+
 // Inode_List is a list of Inode.
 type Inode_List struct{ capnp.List }
 
 // NewInode creates a new list of Inode.
 func NewInode_List(s *capnp.Segment, sz int32) (Inode_List, error) {
-	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 24, PointerCount: 3}, sz)
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 24, PointerCount: 4}, sz)
 	return Inode_List{l}, err
 }
 
@@ -764,12 +789,12 @@ type Dir struct{ capnp.Struct }
 const Dir_TypeID = 0x8a228653b964fd48
 
 func NewDir(s *capnp.Segment) (Dir, error) {
-	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 16, PointerCount: 5})
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 16, PointerCount: 6})
 	return Dir{st}, err
 }
 
 func NewRootDir(s *capnp.Segment) (Dir, error) {
-	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 16, PointerCount: 5})
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 16, PointerCount: 6})
 	return Dir{st}, err
 }
 
@@ -908,12 +933,37 @@ func (s Dir) SetCreationTime(v uint32) {
 	s.Struct.SetUint32(12, v)
 }
 
+func (s Dir) Xattrs() (XAttr_List, error) {
+	p, err := s.Struct.Ptr(5)
+	return XAttr_List{List: p.List()}, err
+}
+
+func (s Dir) HasXattrs() bool {
+	p, err := s.Struct.Ptr(5)
+	return p.IsValid() || err != nil
+}
+
+func (s Dir) SetXattrs(v XAttr_List) error {
+	return s.Struct.SetPtr(5, v.List.ToPtr())
+}
+
+// NewXattrs sets the xattrs field to a newly
+// allocated XAttr_List, preferring placement in s's segment.
+func (s Dir) NewXattrs(n int32) (XAttr_List, error) {
+	l, err := NewXAttr_List(s.Struct.Segment(), n)
+	if err != nil {
+		return XAttr_List{}, err
+	}
+	err = s.Struct.SetPtr(5, l.List.ToPtr())
+	return l, err
+}
+
 // Dir_List is a list of Dir.
 type Dir_List struct{ capnp.List }
 
 // NewDir creates a new list of Dir.
 func NewDir_List(s *capnp.Segment, sz int32) (Dir_List, error) {
-	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 16, PointerCount: 5}, sz)
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 16, PointerCount: 6}, sz)
 	return Dir_List{l}, err
 }
 
@@ -1255,89 +1305,177 @@ func (p ACI_Right_Promise) Struct() (ACI_Right, error) {
 	return ACI_Right{s}, err
 }
 
-const schema_ae9223e76351538a = "x\xda\xacV\x7f\x88TU\x14>\xdf\xbdo~,\xcc" +
-	"6\xf3z#\xfe\xc0e\xd2\x92t\xc9EW\x83\\\x8a" +
-	"\xb5M\xcb\x15\x97\xbc;J\x18\x06\xbd\x9dy\xbb\xfb\x9c" +
-	"\xd9\x99a\xe6M\xb2B,\x1aI.\x88\xac\xac\xfd\xc2" +
-	"\xc8L\xc2\xbf*\xcc \xc9\xc8\"\x08\"\xa4$\xc1\xc2" +
-	"\x8d\"m1B\x8a\x94\\p{q\xe6\xed\xbc\x99]" +
-	"\xa7\xff\x9a\xbf\xee|\xef\xdc\xf3\x9d\xfb\x9d\xef\x9d\xfbV" +
-	"-\x97\xeb\xc5\xea\xc0\xfd\x1a\x91Z\x15\x08\xba{.\xf4" +
-	"<y\xe9B\xfb\x01\xd2\xe3\xc2\xcd<<x\xfe\x95\x93" +
-	"\xcee\"\x18S\xf8\xca\x08\x88\x10\x91\x011Bp7" +
-	"M\xa7\xcf$\xf7/\x1d%\x15\x81pG\x93*5y" +
-	"\xef\xe1w)\x10\xe0\x90ub\x9f\xf1\x08\x07\xafY'" +
-	"\xbe\x04\xe1\xef\x09\xfcu~\xc9\x89\x13z\x04u\xa1\xe0" +
-	"\xd0%\xda\xeb\xc6\x0a\x8dW\xcb\xb4N\x82;~\xf5\xfa" +
-	"\x07{\xdf\x14\xe78\xaf\xac\x0b\x96\x1c\xb2Q;l\xf4" +
-	"p\xf0\x9an\xed)\x10\xdc\xd6[\x9f\xdb/$\x8f_" +
-	"\xa4\xd9\x99+u\x1e\x08\x9c2\xc6*\xe5\x1c\x0c\xec&" +
-	"\xd4N\xa3\"\xb8\xa3\x8ck\x81\xe3\xc6\x9f\x81\xf9D\xc6" +
-	"T%x\xe7\xcb\xe6;W\x8f-\xbcB\x0dJ\xde\x1e" +
-	"\x1c5\x9e\x09\xf2jG\x90K>\xf4Kf\xb2gl" +
-	"\xde\xaf\xa4b\x80{\xf4\xd3\x1f\xe6\x8d\x1f9=9\x13" +
-	"<\x1c<e\xec\xad\x04?\x1f\xe4\xc4\xfe\xe3F\xe7\x9b" +
-	"\x08\xee3~\x0e\xce'Zs-x\x88\xcf\xb7h\xc7" +
-	"\x8d\xb5\xfb\xdf\x9e\xfe\xbda\xcd{\xc3\xa3\xc6\x810\xaf" +
-	"^\x0cs\xea\x8e\x8f\x8f\xb6\xf4\xcd\xef\xbd>7\xb8\xa2" +
-	"\xc6\xcd\xf0)c\xba\x12<\x15~\x8f\xe0F~\xec(" +
-	"\xac+\x97\xa6H\xdd\x0dYS}\xbb\x0cA\x83f\x8c" +
-	"5\xfdF0\x8e4M\x12\xdc\xa1|\xda\xca\xb6\xa5L" +
-	"Q\xc8\x15:\x92\x05+e\x9b\xd9\xb6m\xc3\x05\x8bh" +
-	"+\xa0\xe2\x10D\xfa\x83\x1dD\x80\xbe\xb2\x9d\x08B_" +
-	"\xd6E\x04\xa9\xb7l&\x82\xa6/\xec\"\xea,\xe5S" +
-	"\x19\xcbI\xf4e\xf3\xa9\xccHj\xd0,\xa6\xad\xe7\xdc" +
-	"~\xbb?_\xb09\x13\x8d\x94s\x99\\~w\xce\xa7" +
-	"\x03\xd3m\xb0\x8b\x15\x92{\xa4F\xa4\x81H\xff\xb6\x95" +
-	"H}-\xa1.\x09\xe8@\x1c\x0c^\xdcL\xa4\xbe\x93" +
-	"P?\x09\xe8Bx%M0xYB\xdd\x12\xd0\xa5" +
-	"\x8cC\x12\xe97;\x88\xd4\x1f\x12\xea\xb6\x00\xb484" +
-	"\"}\x8aS\xde\x90\xe8\x85\x80\x1e\xd0\xe2\x08\x10\xe9\xd3" +
-	"\x1cxK\"\xa91\x1a\x14q\x04\xd9\xfc\x18%Jj" +
-	"\x90H\xc6\x18\x0f\xc9x\xa5\x17\xcd\xd8E\x94\x8c0\xbe" +
-	"\x00\x02\xd1\x9c9d!B\x02\x11\x82\x9b\xcd\xa7L\xc7" +
-	"\xce\xe7\x88\xc8\xc7R\xf9\x9cc\xe5\x9c\x12cw\x11\xb6" +
-	"J Vk\x03\x81\xc1\xce\x82Y\xb4rNuO\xb4" +
-	"d\xef\xb1\xd0D\x02M\x84N3\x95\xcdX\xc3~\xbe" +
-	"\xa1|\xda\xee\xb7S&\x98h\x9b=d\x11!L\x02" +
-	"a\xe6*Z\x1e\x7f\x94\x1f\xf8\xf0,\xa1\x93\xe5\xbe\x0d" +
-	"\xd2.\xb2\xd6\x9a\xafu\xf3R\"\x15\x96Pq\x81\xd0" +
-	"\x1c\xb6\xda\xd6\xee\\>\x0d\x8bw.\xf0w\xbe\xc6\x92" +
-	"\x8eK\xa8c\x02\xd5&\xbd\xc1\xd8\xab\x12\xea\x84\x00\x04" +
-	"\xea<\xa8\xbf\xf54\x09]z\x1a\xeb\x07Y\xf9\x97$" +
-	"\xd4\xb8\x80\xaey\x02\xebc\xa3\xb5\x84\x95\x1e\x859\xe3" +
-	".\"uTB\x9d\x9c#\xf9,\xa9\\\xd3q\x8av" +
-	"_\xd9!i\x95\xfeo\xdd\x1e\xb7\xb3V\xa2\x8bM\xcd" +
-	"\x02\x84}\x01V\xf0a\xef\x93P\xab\xeal\xba\x92\xf5" +
-	"\\.\xa1\xd6\x0aD\x07\xcd\xd2 \x9aI\xa0\x99<q" +
-	"g\xd6s\xfaR\xb0R!\xdb\xcc*\x0d\xa8\x9b\xceh" +
-	"\x8d\xf2\x1b\xf8\x1f\x84>_k\x1d\x9f3\\\xb0\x10\xad" +
-	"\xe5  J\x88\xa6M\xc7lL\xbd\xc5\xcee\xbcW" +
-	"\xbc\xce\x11\x1d5Gt:fq\xc0r\x1a\x9b\xe2\xd1" +
-	"\xc7\xba\xdb\x12\xbd\xf6\xc0\xa03G\x97\xf6\x06e\xf6\x11" +
-	"\xa9\x07$\xd4C\x02\x89\"\xef\xf1s\x96KVq\xa0" +
-	"\x98/S\xa8`\xa7\x11\"\x81P\x03&\xaa\xa8\xe3\xcf" +
-	"a\x1d\xed\x1e\xb5Z\xec\xf3~\xc8\xbc\xefK\xa8\xb3u" +
-	"\xfd8\xc3\xe0i\x09u\x8e\x1d\xe9M\x8dOX\xb3\x8f" +
-	"$\xd4\x17<5\x8475>\xe3c\x9f\x9d\x19:\x1a" +
-	"\xbc\xb1qq\x11\x91\xfaFB]fK\"\x8e\x00\xa0" +
-	"\x7f\xbf\xb4n\x12U\xa6\x06\xa0O0xIB]\x11" +
-	"H\x94\xeb\x8d\x9a\x18\x98e[>X\xf5\x94\x9d\x15%" +
-	"J\xb5\xe9\xe0\x9f\xcf\x9b\x0e\xd2NW-\x19*\xdbi" +
-	"\x04H \xe0V\x7fD\x14\x1ah\x84\xdei_\xaf\xc7" +
-	"u-\xea\xad\xb9\xa6\xaa\xd4\xea\x8e\x99\x16m\x12p+" +
-	"3<i\xef!\xd4\x8a\xad`u\xc5\xfa7\xb3W\xec" +
-	"l\xd6\xed%\xab\x98x\xa2\x98/\x17\x989\xe23o" +
-	"d\xed\xd7K\xa8-uM\xea\xe6&m\x90P[k" +
-	"M\xea\xe1r6I\xa8ms^\xfc\x84=\x9c\xefN" +
-	"W\xffu\xf2\xbf\x9c\xe3\x0f\x82Y7\x19\x8f-\xab\xcd" +
-	"\x9b\x0d\xd1\xb2c\x95TLj\x8b]\x17\x1e\x85\xc9=" +
-	"\xdb)\xa1\x06\x05Z\xf0\x0f\xc3\xec\x04\x8bK|VB" +
-	"e\x05Z\xc4\xb4;\xe3\x05\x9b\xe1\xb4\x84*\x08\xb4\xc8" +
-	"\xdb\x0c\xf3%2\xd4E\xa4\x06%\x94#\x10J\xdbE" +
-	"\xc4\xaa\x9fB\x04\xc4\x08\xd1~;k!V\xbb\xe7g" +
-	"\xe0\xac\x9d\xcb V\xfb\x0a\xf1\xe0\x91\x92w\xf9\"V" +
-	"\xffi\xc6O\xfe\x0d\x00\x00\xff\xff\x8e\x9dO\xa6"
+type XAttr struct{ capnp.Struct }
+
+// XAttr_TypeID is the unique identifier for the type XAttr.
+const XAttr_TypeID = 0xd969a1a01af8294a
+
+func NewXAttr(s *capnp.Segment) (XAttr, error) {
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 2})
+	return XAttr{st}, err
+}
+
+func NewRootXAttr(s *capnp.Segment) (XAttr, error) {
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 2})
+	return XAttr{st}, err
+}
+
+func ReadRootXAttr(msg *capnp.Message) (XAttr, error) {
+	root, err := msg.RootPtr()
+	return XAttr{root.Struct()}, err
+}
+
+func (s XAttr) String() string {
+	str, _ := text.Marshal(0xd969a1a01af8294a, s.Struct)
+	return str
+}
+
+func (s XAttr) Name() (string, error) {
+	p, err := s.Struct.Ptr(0)
+	return p.Text(), err
+}
+
+func (s XAttr) HasName() bool {
+	p, err := s.Struct.Ptr(0)
+	return p.IsValid() || err != nil
+}
+
+func (s XAttr) NameBytes() ([]byte, error) {
+	p, err := s.Struct.Ptr(0)
+	return p.TextBytes(), err
+}
+
+func (s XAttr) SetName(v string) error {
+	return s.Struct.SetText(0, v)
+}
+
+func (s XAttr) Value() ([]byte, error) {
+	p, err := s.Struct.Ptr(1)
+	return []byte(p.Data()), err
+}
+
+func (s XAttr) HasValue() bool {
+	p, err := s.Struct.Ptr(1)
+	return p.IsValid() || err != nil
+}
+
+func (s XAttr) SetValue(v []byte) error {
+	return s.Struct.SetData(1, v)
+}
+
+// XAttr_List is a list of XAttr.
+type XAttr_List struct{ capnp.List }
+
+// NewXAttr creates a new list of XAttr.
+func NewXAttr_List(s *capnp.Segment, sz int32) (XAttr_List, error) {
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 0, PointerCount: 2}, sz)
+	return XAttr_List{l}, err
+}
+
+func (s XAttr_List) At(i int) XAttr { return XAttr{s.List.Struct(i)} }
+
+func (s XAttr_List) Set(i int, v XAttr) error { return s.List.SetStruct(i, v.Struct) }
+
+func (s XAttr_List) String() string {
+	str, _ := text.MarshalList(0xd969a1a01af8294a, s.List)
+	return str
+}
+
+// XAttr_Promise is a wrapper for a XAttr promised by a client call.
+type XAttr_Promise struct{ *capnp.Pipeline }
+
+func (p XAttr_Promise) Struct() (XAttr, error) {
+	s, err := p.Pipeline.Struct()
+	return XAttr{s}, err
+}
+
+const schema_ae9223e76351538a = "x\xda\xbcVahT\xd9\x15>\xdf\xbdo\xf2f\xc2" +
+	"\xd8\x99\xd77b#\x86X\xab\xa0A\x83\x89)\xb4C" +
+	"a4\xb5m\"\x86z\x1d\x85\xb6\xf8\xa3/3/\xc9" +
+	"s&3\xc3\xcc\x1bm,%\xd4\x82\xd0\xfc\x91\x8am" +
+	"iQj\xac-\xf5\x87\xad\xb5\x0a\x95*\xd5\xd2\xfe\xe9" +
+	"\x0f)RA\x17]\x16V7\xcb\xb2+\xbb\xac\xb0\x0a" +
+	"f\xdfr\xded\xde\xbc\x8c\xb3\xec\xbf\x9d_w\xbew" +
+	"\xee9\xe7~\xdf9\xe7\xde\xed?\x96;\xc5`dN" +
+	"#R\xc3\x91.\xef\xd8\xdd\xf1\xef\xde\xbf;\xf4s2" +
+	"R\xc2+|c\xfa\xce\xaf\xff\xe4>$\x82\xb9I\xfc" +
+	"\xd7\x1c\x14:\x91\xb9M\xcc\x11\xbc\xd1\xa5\xfc\xf5\xec\x89" +
+	"\x0d\xf3\xa4\xe2\x10\xde|V\xe5\x16\xbfr\xea\xcf\x14\xe9" +
+	"b\x13[\x1c7\x1d6\xdea\x0b\x0f\x84\x8f\x1e\xe1\xc3" +
+	";_\xbep\xc1\x88#d\x0a6\xfd\x8d\xf6[sA" +
+	"\xe3\xd5Y-C\xf0N?y\xfa\xb7\x9f\xfeN\xdcb" +
+	"\xbf2d\xec\x9b\xdc\xd4N\x99\xff\xe1\xd5\x8e\xdb\xdaI" +
+	"\x10\xbc\xfe\xe7\xffr~\x96=\x7f\x8fVz\xf6\xf3\x1c" +
+	"\xef\xbab\x1e\xf4\xd3Q]G\x09\xde\x9e-\xcf\xd7\x9e" +
+	"[p\x1et\xb2\xbd\xd4u\x8a`^\xf2\xed\x82S\xab" +
+	"8^I7\xa6\x9f7\x0d}\x0d\x91\xd9\xa3\xb3\xf1\xa1" +
+	"_Y\x7f|r\xae\xe71u8Z]\x9f7\x7f\xa2" +
+	"\xf3jV\xe7\xa3\x9d|\xb3\xb08\xfe\x8b\xd5o\x91J" +
+	"\x02\xde\x99\x7f\xbe\xb6\xfa\xf4/\xaf.6y\xd0\xaf\x98" +
+	"\x0b\xbe\xf1Y\xdfq\xf0\xb9\x8d\x07\xc9&K\xfaq\x13" +
+	"\xd15D;bQ\x9f\x87\xb5\xdf\x7f6|\xe2\xf7K" +
+	"\xefv\xccy!6o^\x8c\xf1\xea\x0f1v\x9d\xfe" +
+	"\xc7\x99\xde\x895\xfb\x9f\xb6\x1b\xfbL\xac\xee\xbeb\xf6" +
+	"v\xf3\xaa\xa7\xfb/\x04/\xfez\xba\xf2\xf5z\xed\x05" +
+	"\xa9/B\xb6\xd49(uh\xd0\xcc\xbfv\xbfC0" +
+	"\xafu/\x12\xbc\x99r\xde.\x0e\xe4,Q)U\xd2" +
+	"\xd9\x8a\x9ds\xac\xe2\xc0\x81\xd9\x8aM\xb4\x0fP)\x08" +
+	"\"\xe3\xabi\"\xc0\xd86D\x04al\x1a!\x824" +
+	"z\xf7\x10A3zF\x882\xb5r\xae`\xbb}\x13" +
+	"\xc5r\xae0\x97\x9b\xb6\xaay\xfb\x887\xe9L\x96+" +
+	"\x0e{\xa2\xb9z\xa9P*\x1f-\x05\xe1\xc0\xe1v;" +
+	"U?\xc8F\xa9\x11i 2\xde\xeb'RoK\xa8" +
+	"g\x02\x06\x90\x02\x83\x1f\xec!R\xefK\xa8\x97\x02\x86" +
+	"\x10\x8d\x94^0\xf8\\\"\x9b\x82\x80!e\x0a\x92\xc8" +
+	"4\x90&\xca\xc6!\x91\xfd\x12\x04\xa0\xa5\xa01?\xe8" +
+	"'\xca&\x19^\xc7\xe6\x11-\x85\x08\xb3\xe5\x9b\xa7\x18" +
+	"_\xcfx\x97H\xa1\x8b\xc8\xec\xc5<Qv=\xe3[" +
+	"\x19\xd7e\xca\x17e\x0b\x0e\x13e73>\xccx4" +
+	"\x92B\x94\xc8\x1c\xf4\xfdle|\x14\x02\x89\x925c" +
+	"#N\x02q\x82W,\xe7,\xd7)\x97\x88(\xc0r" +
+	"\xe5\x92k\x97\xdc\x1ac_ \xec\x93@\xb2\xa5\x13\x81" +
+	"\xc1L\xc5\xaa\xda%\xb7\xb9'Qs\x8e\xd9\x88\x91@" +
+	"\x8c\x90\xb1r\xc5\x82=\x1b\xf8\x9b)\xe7\x9dI'g" +
+	"\x81\x03\x1dpfl\"DI \xca\xb1\xaav#~" +
+	"\x82?4\xe1\xcc\x8f,\xd7\xad\xd6Z\xd1\x83NkD" +
+	"_\xa9T\xb6>\xb1[:U\x16K\x0b\xc4Z\xb5\x81" +
+	"HE%TJ@o\xcb\xa6\xb5u\xacT\xce\xc3\xe6" +
+	"\x9d\xeb\x82\x9d\xd7X\xe6\xcb\x12\xea\x86@S\xe5\xeb\x8c" +
+	"]\x95P\xb7\x04 \x10*b\xe3\xe6\x0fH\x18\xb2\xa1" +
+	"\x8dq1M\xa4.H\xa8\xcb\x02\x86\xd6\x10\xc6\xb84" +
+	"\xdfr\xe8\xab\x1be\x8f\x87\x89\xd4\xdf%\xd4\xbfYZ" +
+	"\x99B\x8c\xc8\xb8\xcd\xdboH\xa8\xfbm:\xad\xe0\xd7" +
+	"cr\x9c\x89\xbaK\xd2\xae}\xded\x7f\xdb)\xda}" +
+	"#\xdcJ\xccZ4`m\x0b3\xb4QBm\x0f5" +
+	"\xc76\x16a\xb3\x84\x1a\x16HL[\xb5i\xac\"\x81" +
+	"U\xd4Pdy\xbd\xd2\xff\xf7v\xb9.\xaa\x9f\xe9x" +
+	"(\xe48LT\xdf\x11\xabX\xb7;\xbb\xe6\x01\xa2;" +
+	"VQi@\xe8ZB\x7f\x82G\xca\xa7\x84\x0c\"\xf6" +
+	"\x87\"\xba\xb3\x15\x1b\x89\x96\x0f\x02\x12\x84D\xder\xad" +
+	"\xce\xa1\xf7:\xa5\x02Q[\x85\xa6[\x15\x9aq\xad\xea" +
+	"\x94\xedv.\xd2]\xdf\x1c\x1b\xe8\xdb\xefLM\xbbm" +
+	"\x94\x0fuHs\x82Hm\x95P_\x13\xe8\xab\xf2\x9e" +
+	"\xc0g\xbdfW\xa7\xaa\xe5:\xe9\x15'\x0f\x9d\x04\xf4" +
+	"\x0e\x91\xc8g'\xb8X\x0c\x0c5B\x87\x1bd(T" +
+	"\xcfA\x87\x0c\x85;\xa41\x06o\xf6\x87j\x9c[D" +
+	"\xb6\xd5\xb8\xa1\xc1\x9f\x81\xc6\xbd\xb5D\xea\x7f\x12\xea!" +
+	"\xb7\x08R\x88\x00\xc6\x03\xae\x9e\xffK\xa87\x9a\xd3\x0f" +
+	"0\x1e1x_B=\x16\xe8\xab\xaf\x90~jE\xc7" +
+	"\xf0\xc1\x9a\xa7\xcc\xf8L\x84J<8_\xa3\xc4\xa5\x93" +
+	"ov\x83^w\xf2\x88\x90@\xc4k\xfe\x88H\x9f\xea" +
+	"\x84\xbe\xda\x19Dm\x12\xedoUM\x93\xa9\xc1\xf4\xb2" +
+	"D\xa3\x02\x9e\x7f)e\x9dc\x84V\xb2>\x16J6" +
+	"x\x92t\xea\xc7\x835\xbb\xda\xf7\x9dj\xb9^\xe1\xc8" +
+	"\xf1 \xf2\xb7\x98\xfb\x9d\x12joH\xa41\x16i\xb7" +
+	"\x84\xda\xd7\x12i\x9c\xd3\x19\x95P\x07\xda[\xc9\x99-" +
+	"\x8f\xe5\x9b\xff2\xfc\xaf\xe4\x063h\xc5\xd5\xccc\xd4" +
+	"\x1eh\x8c\xa5D\xdd\xb5k*)\xb5u\x9e\x87F\x08" +
+	"\x8b5;$\xa1\xa6\x05z\xf1\xb1\x87F%\xd8\x9c\xe2" +
+	"\x0f%TQ\xa0W,y\xcb\xb5\xe00\x9c\x97P\x15" +
+	"\x81^\xf9\x92\xe1\x08\x9113B\xa4\xa6%\x94+\xa0" +
+	"\xe7\x9d*\x92\xcd7 \x01IBb\xd2)\xdaH\xb6" +
+	"\x1e.\xcbp\xd1)\x15\x90l=\xab\x1a\xf0\\\xad\xf1" +
+	"\x9a@2\xfc&\xe5/\x9f\x0c\x00zd{\xfd"
 
 func init() {
 	schemas.Register(schema_ae9223e76351538a,
@@ -1346,6 +1484,7 @@ func init() {
 		0xa4a421ce00f301dd,
 		0xc0029f81b3eee594,
 		0xd5a2538369c2f82a,
+		0xd969a1a01af8294a,
 		0xdc74a897ce683c6b,
 		0xe419a0e5a661965c,
 		0xe615914de76be38f,
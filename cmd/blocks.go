@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/codegangsta/cli"
+	"github.com/threefoldtech/0-fs/rofs"
+	"github.com/threefoldtech/0-fs/storage/router"
+)
+
+var serveBlocksCommand = cli.Command{
+	Name:  "serve-blocks",
+	Usage: "serve blocks (read only by default) over the redis protocol, so other nodes can list this node as a zdb pool in their router.yaml. Clients are not authenticated",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "listen",
+			Value: ":9900",
+			Usage: "address to listen on",
+		},
+		cli.StringFlag{
+			Name:  "dir",
+			Usage: "serve the blocks of a directory with the layout of file:// storage destinations",
+		},
+		cli.StringFlag{
+			Name:  "cache",
+			Usage: "serve the blocks of a block cache (see --cache-mode), the block cache is always read only",
+		},
+		cli.BoolFlag{
+			Name:  "writable",
+			Usage: "accept SET requests to the --dir directory. There is no authentication, any client that can reach the listen address can write blocks",
+		},
+	},
+	Action: serveBlocks,
+}
+
+func serveBlocks(ctx *cli.Context) error {
+	dir, cache := ctx.String("dir"), ctx.String("cache")
+	if (len(dir) == 0) == (len(cache) == 0) {
+		return fmt.Errorf("expecting either --dir or --cache")
+	}
+
+	writable := ctx.Bool("writable")
+	if writable && len(dir) == 0 {
+		return fmt.Errorf("--writable is only supported with --dir")
+	}
+
+	var store router.Store
+	if len(dir) != 0 {
+		store = router.NewFileStore(dir)
+	} else {
+		blocks := rofs.NewCache(cache, nil)
+		blocks.SetMode(rofs.BlockCache)
+		store = rofs.NewBlockStore(blocks)
+	}
+
+	// clients are not authenticated, so blocks are only served by default
+	if !writable {
+		store = router.ReadOnly(store)
+	} else {
+		log.Warningf("accepting writes from any client that can reach %s", ctx.String("listen"))
+	}
+
+	listen := ctx.String("listen")
+	log.Infof("serving blocks on %s", listen)
+	return router.NewServer(store).ListenAndServe(listen)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/codegangsta/cli"
+	g8ufs "github.com/threefoldtech/0-fs"
+)
+
+var commitCommand = cli.Command{
+	Name:      "commit",
+	Usage:     "create an flist layer from the changes made to an overlay mount, to be layered on top of the mounted flist on the next mount",
+	ArgsUsage: "<backend> <out.flist>",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "storage",
+			Usage: "storage url (e.g. zdb://hub.grid.tf:9900) to upload the file blocks to, it's also written as the flist router.yaml",
+		},
+	},
+	Action: commit,
+}
+
+func commit(ctx *cli.Context) error {
+	args := ctx.Args()
+	if len(args) != 2 {
+		return fmt.Errorf("expecting a backend directory and an output flist")
+	}
+
+	backend, out := args.Get(0), args.Get(1)
+	pool, config, err := storagePool(ctx.String("storage"))
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+
+	if err := g8ufs.Commit(backend, file, pool, config); err != nil {
+		file.Close()
+		os.Remove(out)
+		return err
+	}
+
+	log.Infof("flist '%s' created", out)
+	return file.Close()
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/codegangsta/cli"
+	"github.com/threefoldtech/0-fs/flist"
+	"github.com/threefoldtech/0-fs/storage/router"
+)
+
+var createCommand = cli.Command{
+	Name:      "create",
+	Usage:     "create an flist from a local directory",
+	ArgsUsage: "<dir> <out.flist>",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "storage",
+			Usage: "storage url (e.g. zdb://hub.grid.tf:9900) to upload the file blocks to, it's also written as the flist router.yaml",
+		},
+	},
+	Action: create,
+}
+
+// storagePool creates a pool that routes all the blocks to the storage url
+func storagePool(url string) (router.Pool, *router.Config, error) {
+	if len(url) == 0 {
+		return nil, nil, fmt.Errorf("--storage is required")
+	}
+
+	config := router.Config{
+		Pools: map[string]router.PoolConfig{
+			"storage": {
+				"00:FF": url,
+			},
+		},
+		Lookup: []string{
+			"storage",
+		},
+	}
+
+	if err := config.Valid(); err != nil {
+		return nil, nil, err
+	}
+
+	hashRange, err := router.NewRange("00:FF")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dest, err := router.NewDestination(url)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return router.DefaultPoolFactory(router.Rule{Range: hashRange, Destination: dest}), &config, nil
+}
+
+func create(ctx *cli.Context) error {
+	args := ctx.Args()
+	if len(args) != 2 {
+		return fmt.Errorf("expecting a source directory and an output flist")
+	}
+
+	src, out := args.Get(0), args.Get(1)
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		return fmt.Errorf("'%s' is not a directory", src)
+	}
+
+	pool, config, err := storagePool(ctx.String("storage"))
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+
+	if err := flist.Create(src, file, pool, config); err != nil {
+		file.Close()
+		os.Remove(out)
+		return err
+	}
+
+	log.Infof("flist '%s' created", out)
+	return file.Close()
+}
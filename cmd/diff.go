@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/codegangsta/cli"
+	"github.com/threefoldtech/0-fs/meta"
+)
+
+var diffCommand = cli.Command{
+	Name:      "diff",
+	Usage:     "print the added, removed and modified entries between two flists",
+	ArgsUsage: "<a.flist> <b.flist>",
+	Flags:     []cli.Flag{jsonFlag},
+	Action:    diff,
+}
+
+// diffChange is the printable change of an entry
+type diffChange struct {
+	Path     string          `json:"path"`
+	Kind     meta.ChangeKind `json:"kind"`
+	Metadata bool            `json:"metadata"`
+	Content  bool            `json:"content"`
+	Old      *entryInfo      `json:"old,omitempty"`
+	New      *entryInfo      `json:"new,omitempty"`
+}
+
+func diffMark(change meta.Change) string {
+	switch change.Kind {
+	case meta.Added:
+		return "A"
+	case meta.Removed:
+		return "D"
+	}
+
+	mark := "M"
+	if change.Content {
+		mark += "c"
+	}
+	if change.Metadata {
+		mark += "m"
+	}
+
+	return mark
+}
+
+func diff(ctx *cli.Context) error {
+	args := ctx.Args()
+	if len(args) != 2 {
+		return fmt.Errorf("expecting two flists")
+	}
+
+	a, err := openMeta(args.Get(0))
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	b, err := openMeta(args.Get(1))
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+
+	asJSON := ctx.Bool("json")
+	changes := []diffChange{}
+	err = meta.Diff(a, b, func(change meta.Change) error {
+		if !asJSON {
+			fmt.Printf("%-3s /%s\n", diffMark(change), change.Path)
+			return nil
+		}
+
+		out := diffChange{
+			Path:     change.Path,
+			Kind:     change.Kind,
+			Metadata: change.Metadata,
+			Content:  change.Content,
+		}
+
+		if change.Old != nil {
+			info := newEntryInfo(change.Path, change.Old)
+			out.Old = &info
+		}
+
+		if change.New != nil {
+			info := newEntryInfo(change.Path, change.New)
+			out.New = &info
+		}
+
+		changes = append(changes, out)
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if asJSON {
+		return printJSON(changes)
+	}
+
+	return nil
+}
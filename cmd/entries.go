@@ -0,0 +1,281 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/threefoldtech/0-fs/flistfs"
+	"github.com/threefoldtech/0-fs/meta"
+	"github.com/threefoldtech/0-fs/rofs"
+	"github.com/threefoldtech/0-fs/storage"
+)
+
+// entryInfo is the printable metadata of an flist entry
+type entryInfo struct {
+	Path             string            `json:"path"`
+	Type             string            `json:"type"`
+	Mode             string            `json:"mode"`
+	UID              uint32            `json:"uid"`
+	GID              uint32            `json:"gid"`
+	Size             uint64            `json:"size"`
+	Blocks           int               `json:"blocks"`
+	BlockSize        uint64            `json:"block_size,omitempty"`
+	LinkTarget       string            `json:"link_target,omitempty"`
+	SpecialData      string            `json:"special_data,omitempty"`
+	CreationTime     time.Time         `json:"creation_time"`
+	ModificationTime time.Time         `json:"modification_time"`
+	XAttrs           map[string]string `json:"xattrs,omitempty"`
+
+	fileMode os.FileMode
+}
+
+func typeName(t meta.NodeType) string {
+	switch t {
+	case meta.DirType:
+		return "dir"
+	case meta.RegularType:
+		return "file"
+	case meta.LinkType:
+		return "link"
+	case meta.BlockDeviceType:
+		return "block"
+	case meta.CharDeviceType:
+		return "char"
+	case meta.FIFOType:
+		return "fifo"
+	case meta.SocketType:
+		return "socket"
+	default:
+		return "unknown"
+	}
+}
+
+func newEntryInfo(p string, m meta.Meta) entryInfo {
+	info := m.Info()
+	entry := entryInfo{
+		Path:             p,
+		Type:             typeName(info.Type),
+		Mode:             fmt.Sprintf("%04o", info.Access.Mode),
+		UID:              info.Access.UID,
+		GID:              info.Access.GID,
+		Size:             info.Size,
+		Blocks:           len(m.Blocks()),
+		BlockSize:        info.FileBlockSize,
+		LinkTarget:       info.LinkTarget,
+		SpecialData:      info.SpecialData,
+		CreationTime:     time.Unix(int64(info.CreationTime), 0).UTC(),
+		ModificationTime: time.Unix(int64(info.ModificationTime), 0).UTC(),
+		fileMode:         flistfs.FileMode(info),
+	}
+
+	for key, value := range info.XAttrs {
+		if entry.XAttrs == nil {
+			entry.XAttrs = make(map[string]string)
+		}
+		entry.XAttrs[key] = string(value)
+	}
+
+	return entry
+}
+
+// name is the entry name as printed by ls and tree, with the link target
+func (e *entryInfo) name() string {
+	name := path.Base(e.Path)
+	if len(e.LinkTarget) != 0 {
+		name += " -> " + e.LinkTarget
+	}
+
+	return name
+}
+
+// cleanPath converts a user path to an flist path
+func cleanPath(p string) string {
+	return strings.Trim(path.Clean("/"+p), "/")
+}
+
+func get(store meta.Store, p string) (meta.Meta, error) {
+	m, ok := store.Get(p)
+	if !ok {
+		return nil, fmt.Errorf("'/%s' not found", p)
+	}
+
+	return m, nil
+}
+
+// list returns the entries of the directory p sorted by path, or the entry
+// itself if p is not a directory
+func list(store meta.Store, p string) ([]entryInfo, error) {
+	m, err := get(store, p)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []entryInfo{}
+	if m.IsDir() {
+		for _, child := range m.Children() {
+			entries = append(entries, newEntryInfo(path.Join(p, child.Name()), child))
+		}
+	} else {
+		entries = append(entries, newEntryInfo(p, m))
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Path < entries[j].Path
+	})
+
+	return entries, nil
+}
+
+// writeFile writes the file content to w, blocks are downloaded one by one
+func writeFile(w io.Writer, storage storage.Storage, m meta.Meta) error {
+	downloader := rofs.NewDownloader(storage, m)
+	remaining := m.Info().Size
+	for i := range m.Blocks() {
+		data, err := downloader.DownloadBlock(i)
+		if err != nil {
+			return err
+		}
+
+		if uint64(len(data)) > remaining {
+			data = data[:remaining]
+		}
+		remaining -= uint64(len(data))
+
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// treeNode is a tree entry for the json output
+type treeNode struct {
+	entryInfo
+	Children []*treeNode `json:"children,omitempty"`
+}
+
+// buildTree returns the tree of the entry p, and the number of directories and
+// files under it. Children are sorted by path
+func buildTree(store meta.Walker, p string) (root *treeNode, dirs, files int, err error) {
+	nodes := make(map[string]*treeNode)
+	err = store.Walk(p, func(q string, m meta.Meta) error {
+		node := &treeNode{entryInfo: newEntryInfo(q, m)}
+		nodes[q] = node
+		if root == nil {
+			root = node
+			return nil
+		}
+
+		if m.IsDir() {
+			dirs++
+		} else {
+			files++
+		}
+
+		parent := nodes[path.Dir(q)]
+		if path.Dir(q) == "." {
+			parent = nodes[""]
+		}
+		parent.Children = append(parent.Children, node)
+		return nil
+	})
+
+	if err == meta.ErrNotFound {
+		return nil, 0, 0, fmt.Errorf("'/%s' not found", p)
+	} else if err != nil {
+		return nil, 0, 0, err
+	}
+
+	var sortTree func(node *treeNode)
+	sortTree = func(node *treeNode) {
+		sort.Slice(node.Children, func(i, j int) bool {
+			return node.Children[i].Path < node.Children[j].Path
+		})
+
+		for _, child := range node.Children {
+			sortTree(child)
+		}
+	}
+
+	sortTree(root)
+	return root, dirs, files, nil
+}
+
+// printTree prints the children of node to w, like the tree command
+func printTree(w io.Writer, node *treeNode, prefix string) {
+	for i, child := range node.Children {
+		branch, indent := "├── ", "│   "
+		if i == len(node.Children)-1 {
+			branch, indent = "└── ", "    "
+		}
+
+		fmt.Fprintf(w, "%s%s%s\n", prefix, branch, child.name())
+		printTree(w, child, prefix+indent)
+	}
+}
+
+// usage is the disk usage of an flist entry
+type usage struct {
+	Path   string `json:"path"`
+	Size   uint64 `json:"size"`
+	Files  int    `json:"files"`
+	Blocks int    `json:"blocks"`
+}
+
+func (u *usage) add(m meta.Meta) {
+	if m.Info().Type != meta.RegularType {
+		return
+	}
+
+	u.Size += m.Info().Size
+	u.Files++
+	u.Blocks += len(m.Blocks())
+}
+
+// diskUsage returns the usage of each child of the directory p sorted by path,
+// followed by the total usage of p
+func diskUsage(store meta.Walker, p string) ([]*usage, error) {
+	m, err := get(store, p)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*usage
+	if m.IsDir() {
+		for _, child := range m.Children() {
+			u := &usage{Path: path.Join(p, child.Name())}
+			err := store.Walk(u.Path, func(_ string, m meta.Meta) error {
+				u.add(m)
+				return nil
+			})
+
+			if err != nil {
+				return nil, err
+			}
+
+			entries = append(entries, u)
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Path < entries[j].Path
+	})
+
+	total := &usage{Path: p}
+	for _, u := range entries {
+		total.Size += u.Size
+		total.Files += u.Files
+		total.Blocks += u.Blocks
+	}
+	if !m.IsDir() {
+		total.add(m)
+	}
+
+	return append(entries, total), nil
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/threefoldtech/0-fs/meta"
+)
+
+// testFlist creates a small flist with meta.Writer:
+//
+//	bin/ls, bin/sh, bin/sh-link -> sh, empty/, etc/passwd
+func testFlist(t *testing.T) meta.Walker {
+	root := t.TempDir()
+	writer, err := meta.NewWriter(root)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	blocks := func(n int) []meta.BlockInfo {
+		var list []meta.BlockInfo
+		for i := 0; i < n; i++ {
+			list = append(list, meta.BlockInfo{Key: []byte{byte(i)}, Decipher: []byte{byte(i)}})
+		}
+		return list
+	}
+
+	dir := meta.Info{Type: meta.DirType, Access: meta.Access{Mode: 0755}}
+	file := func(size uint64) meta.Info {
+		return meta.Info{Type: meta.RegularType, Access: meta.Access{Mode: 0644}, Size: size, FileBlockSize: 512 * 1024}
+	}
+
+	entries := []struct {
+		path   string
+		info   meta.Info
+		blocks int
+	}{
+		{"", dir, 0},
+		{"bin", dir, 0},
+		{"bin/sh", file(100), 1},
+		{"bin/ls", file(200), 2},
+		{"bin/sh-link", meta.Info{Type: meta.LinkType, Access: meta.Access{Mode: 0777}, LinkTarget: "sh"}, 0},
+		{"empty", dir, 0},
+		{"etc", dir, 0},
+		{"etc/passwd", file(10), 1},
+	}
+
+	for _, entry := range entries {
+		if ok := assert.NoError(t, writer.Add(entry.path, entry.info, blocks(entry.blocks))); !ok {
+			t.Fatal()
+		}
+	}
+
+	if ok := assert.NoError(t, writer.Close()); !ok {
+		t.Fatal()
+	}
+
+	store, err := meta.NewStore(root)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	t.Cleanup(func() { store.Close() })
+	return store.(meta.Walker)
+}
+
+func TestCleanPath(t *testing.T) {
+	cases := []struct {
+		path string
+		want string
+	}{
+		{"", ""},
+		{"/", ""},
+		{".", ""},
+		{"/etc/", "etc"},
+		{"etc//passwd", "etc/passwd"},
+		{"./bin/../etc", "etc"},
+		{"../../etc", "etc"},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.want, cleanPath(c.path), c.path)
+	}
+}
+
+func TestList(t *testing.T) {
+	store := testFlist(t)
+	cases := []struct {
+		path  string
+		paths []string
+		err   bool
+	}{
+		{"", []string{"bin", "empty", "etc"}, false},
+		{"bin", []string{"bin/ls", "bin/sh", "bin/sh-link"}, false},
+		{"empty", []string{}, false},
+		{"etc/passwd", []string{"etc/passwd"}, false},
+		{"missing", nil, true},
+	}
+
+	for _, c := range cases {
+		entries, err := list(store, c.path)
+		if c.err {
+			assert.Error(t, err, c.path)
+			continue
+		}
+
+		if ok := assert.NoError(t, err, c.path); !ok {
+			continue
+		}
+
+		paths := []string{}
+		for _, entry := range entries {
+			paths = append(paths, entry.Path)
+		}
+		assert.Equal(t, c.paths, paths, c.path)
+	}
+
+	entries, _ := list(store, "bin")
+	assert.Equal(t, "file", entries[0].Type)
+	assert.Equal(t, uint64(200), entries[0].Size)
+	assert.Equal(t, 2, entries[0].Blocks)
+	assert.Equal(t, "0644", entries[0].Mode)
+	assert.Equal(t, "sh-link -> sh", entries[2].name())
+}
+
+func TestDiskUsage(t *testing.T) {
+	store := testFlist(t)
+	cases := []struct {
+		path  string
+		usage []usage
+		err   bool
+	}{
+		{"", []usage{
+			{Path: "bin", Size: 300, Files: 2, Blocks: 3},
+			{Path: "empty"},
+			{Path: "etc", Size: 10, Files: 1, Blocks: 1},
+			{Path: "", Size: 310, Files: 3, Blocks: 4},
+		}, false},
+		{"bin", []usage{
+			{Path: "bin/ls", Size: 200, Files: 1, Blocks: 2},
+			{Path: "bin/sh", Size: 100, Files: 1, Blocks: 1},
+			{Path: "bin/sh-link"},
+			{Path: "bin", Size: 300, Files: 2, Blocks: 3},
+		}, false},
+		{"etc/passwd", []usage{
+			{Path: "etc/passwd", Size: 10, Files: 1, Blocks: 1},
+		}, false},
+		{"missing", nil, true},
+	}
+
+	for _, c := range cases {
+		entries, err := diskUsage(store, c.path)
+		if c.err {
+			assert.Error(t, err, c.path)
+			continue
+		}
+
+		if ok := assert.NoError(t, err, c.path); !ok {
+			continue
+		}
+
+		var got []usage
+		for _, u := range entries {
+			got = append(got, *u)
+		}
+		assert.Equal(t, c.usage, got, c.path)
+	}
+}
+
+func TestTree(t *testing.T) {
+	store := testFlist(t)
+	cases := []struct {
+		path   string
+		output string
+		dirs   int
+		files  int
+		err    bool
+	}{
+		{"", "" +
+			"├── bin\n" +
+			"│   ├── ls\n" +
+			"│   ├── sh\n" +
+			"│   └── sh-link -> sh\n" +
+			"├── empty\n" +
+			"└── etc\n" +
+			"    └── passwd\n", 3, 4, false},
+		{"etc", "└── passwd\n", 0, 1, false},
+		{"bin/sh", "", 0, 0, false},
+		{"missing", "", 0, 0, true},
+	}
+
+	for _, c := range cases {
+		root, dirs, files, err := buildTree(store, c.path)
+		if c.err {
+			assert.Error(t, err, c.path)
+			continue
+		}
+
+		if ok := assert.NoError(t, err, c.path); !ok {
+			continue
+		}
+
+		var output bytes.Buffer
+		printTree(&output, root, "")
+		assert.Equal(t, c.output, output.String(), c.path)
+		assert.Equal(t, c.dirs, dirs, c.path)
+		assert.Equal(t, c.files, files, c.path)
+	}
+}
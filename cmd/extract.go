@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/codegangsta/cli"
+	"github.com/threefoldtech/0-fs/flist"
+)
+
+var extractCommand = cli.Command{
+	Name:      "extract",
+	Usage:     "extract the content of an flist to a directory (or a tar archive) without mounting it",
+	ArgsUsage: "<flist> <dest>",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "tar",
+			Usage: "write a tar archive to dest instead of a directory, use - for stdout",
+		},
+		cli.StringFlag{
+			Name:  "storage-url",
+			Usage: "fallback storage url in case the flist router.yaml doesn't have the blocks",
+		},
+		cli.IntFlag{
+			Name:  "workers",
+			Usage: "number of files to download in parallel",
+			Value: flist.DefaultExtractWorkers,
+		},
+	},
+	Action: extract,
+}
+
+func extract(ctx *cli.Context) error {
+	args := ctx.Args()
+	if len(args) != 2 {
+		return fmt.Errorf("expecting an flist and a destination")
+	}
+
+	src, dest := args.Get(0), args.Get(1)
+	store, err := openFlist(src, ctx.String("storage-url"))
+	if err != nil {
+		return err
+	}
+
+	defer store.Close()
+
+	workers := ctx.Int("workers")
+	if !ctx.Bool("tar") {
+		if err := flist.Extract(store, store.data, dest, workers); err != nil {
+			return err
+		}
+
+		log.Infof("flist '%s' extracted to '%s'", src, dest)
+		return nil
+	}
+
+	var output io.WriteCloser = os.Stdout
+	if dest != "-" {
+		if output, err = os.Create(dest); err != nil {
+			return err
+		}
+	}
+
+	if err := flist.ExtractTar(store, store.data, output, workers); err != nil {
+		output.Close()
+		if dest != "-" {
+			os.Remove(dest)
+		}
+		return err
+	}
+
+	return output.Close()
+}
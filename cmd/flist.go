@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/threefoldtech/0-fs/meta"
+	"github.com/threefoldtech/0-fs/storage"
+	"github.com/threefoldtech/0-fs/storage/router"
+)
+
+// flistStore is an opened flist, with its meta and data stores
+type flistStore struct {
+	meta.Walker
+	data *router.Router
+	db   string
+	tmp  string
+}
+
+// Check checks the flist meta store entries if the store supports it (see meta.Checker),
+// otherwise it walks over the flist and reports no errors
+func (f *flistStore) Check(fn meta.CheckFn) error {
+	if checker, ok := f.Walker.(meta.Checker); ok {
+		return checker.Check(fn)
+	}
+
+	return f.Walk("", func(p string, m meta.Meta) error {
+		return fn(p, m, nil)
+	})
+}
+
+func (f *flistStore) Close() error {
+	err := f.Walker.Close()
+	if f.data != nil {
+		f.data.Close()
+	}
+
+	if len(f.tmp) != 0 {
+		os.RemoveAll(f.tmp)
+	}
+
+	return err
+}
+
+// openMeta opens the flist meta store only, the data store is not set (see
+// flistStore.openData). If name is an flist archive, it's unpacked in a temporary
+// directory that is removed on Close
+func openMeta(name string) (*flistStore, error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &flistStore{db: name}
+	if !info.IsDir() {
+		if f.tmp, err = os.MkdirTemp("", "0-fs-flist-"); err != nil {
+			return nil, err
+		}
+
+		f.db = f.tmp
+		if err := unpack(name, f.db); err != nil {
+			os.RemoveAll(f.tmp)
+			return nil, err
+		}
+	}
+
+	store, err := meta.NewStore(f.db)
+	if err != nil {
+		os.RemoveAll(f.tmp)
+		return nil, err
+	}
+
+	walker, ok := store.(meta.Walker)
+	if !ok {
+		store.Close()
+		os.RemoveAll(f.tmp)
+		return nil, fmt.Errorf("flist store doesn't support walking")
+	}
+	f.Walker = walker
+
+	return f, nil
+}
+
+// openData sets the data store of the flist from the flist router.yaml. If url
+// is not empty, it's used as a fallback storage for the flist blocks
+func (f *flistStore) openData(url string) error {
+	var fallback *router.Router
+	if len(url) != 0 {
+		var err error
+		if fallback, err = storage.NewSimpleStorage(url); err != nil {
+			return err
+		}
+	}
+
+	data, err := getDataStore([]string{f.db}, fallback)
+	if err != nil {
+		if fallback != nil {
+			fallback.Close()
+		}
+		return err
+	}
+
+	f.data = data
+	return nil
+}
+
+// openFlist opens the flist meta store and its data store, see openMeta
+// and flistStore.openData
+func openFlist(name string, url string) (*flistStore, error) {
+	f, err := openMeta(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := f.openData(url); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func unpack(name, dest string) error {
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+	return meta.Unpack(file, dest)
+}
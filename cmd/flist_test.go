@@ -0,0 +1,103 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/threefoldtech/0-fs/flist"
+	"github.com/threefoldtech/0-fs/meta"
+	"golang.org/x/crypto/blake2b"
+)
+
+func TestVerifyFlistACI(t *testing.T) {
+	root := t.TempDir()
+	writer, err := meta.NewWriter(root)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	dir := meta.Info{Type: meta.DirType, Access: meta.Access{Mode: 0755}}
+	file := meta.Info{Type: meta.RegularType, Access: meta.Access{Mode: 0600, UID: 10}}
+	for p, info := range map[string]meta.Info{"": dir, "etc": dir, "etc/passwd": file} {
+		if ok := assert.NoError(t, writer.Add(p, info, nil)); !ok {
+			t.Fatal()
+		}
+	}
+
+	if ok := assert.NoError(t, writer.Close()); !ok {
+		t.Fatal()
+	}
+
+	// drop the ACI of etc/passwd, the file still points to it
+	db, err := sql.Open("sqlite3", path.Join(root, meta.SQLiteDBName))
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	hasher, _ := blake2b.New(16, nil)
+	fmt.Fprintf(hasher, "aci:%d:%d:%o", file.Access.UID, file.Access.GID, file.Access.Mode)
+	_, err = db.Exec("delete from entries where key = ?", fmt.Sprintf("%x", hasher.Sum(nil)))
+	db.Close()
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	// blocks are looked up in the flist router
+	config := fmt.Sprintf("pools:\n  local:\n    \"00:FF\": file://%s\nlookup:\n  - local\n", t.TempDir())
+	if err := os.WriteFile(path.Join(root, "router.yaml"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := openFlist(root, "")
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	defer store.Close()
+
+	report, err := flist.Verify(store, store.data, false, 1)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	assert.False(t, report.OK())
+	if ok := assert.Len(t, report.Problems, 1); ok {
+		assert.Equal(t, "etc/passwd", report.Problems[0].Path)
+		assert.Contains(t, report.Problems[0].Error, "missing ACI")
+	}
+}
+
+func TestOpenFlistNoStorage(t *testing.T) {
+	root := t.TempDir()
+	writer, err := meta.NewWriter(root)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	if ok := assert.NoError(t, writer.Add("", meta.Info{Type: meta.DirType, Access: meta.Access{Mode: 0755}}, nil)); !ok {
+		t.Fatal()
+	}
+	if ok := assert.NoError(t, writer.Close()); !ok {
+		t.Fatal()
+	}
+
+	// without router.yaml nor storage url, the blocks can't be looked up
+	_, err = openFlist(root, "")
+	assert.Equal(t, errNoStorage, err)
+
+	// but the metadata can still be read
+	store, err := openMeta(root)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	defer store.Close()
+
+	_, ok := store.Get("")
+	assert.True(t, ok)
+	assert.Nil(t, store.data)
+
+	if ok := assert.NoError(t, store.openData("zdb://localhost:9900")); ok {
+		assert.NotNil(t, store.data)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/codegangsta/cli"
+	"github.com/threefoldtech/0-fs/flist"
+)
+
+var importOCICommand = cli.Command{
+	Name:      "import-oci",
+	Usage:     "create flists from an OCI image layout directory",
+	ArgsUsage: "<oci-layout-dir> <out-dir>",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "storage",
+			Usage: "storage url (e.g. zdb://hub.grid.tf:9900) to upload the file blocks to, it's also written as the flists router.yaml",
+		},
+		cli.StringFlag{
+			Name:  "ref",
+			Usage: "image ref name (org.opencontainers.image.ref.name) to import, required if the layout has many images",
+		},
+		cli.BoolFlag{
+			Name:  "per-layer",
+			Usage: "create one flist per image layer (to be stacked with many --meta) instead of a single flattened flist",
+		},
+	},
+	Action: importOCI,
+}
+
+func importOCI(ctx *cli.Context) error {
+	args := ctx.Args()
+	if len(args) != 2 {
+		return fmt.Errorf("expecting an OCI layout directory and an output directory")
+	}
+
+	src, out := args.Get(0), args.Get(1)
+	image, err := flist.OpenOCI(src, ctx.String("ref"))
+	if err != nil {
+		return err
+	}
+
+	pool, config, err := storagePool(ctx.String("storage"))
+	if err != nil {
+		return err
+	}
+
+	flists, err := flist.ImportOCI(image, out, pool, config, ctx.Bool("per-layer"))
+	if err != nil {
+		return err
+	}
+
+	for _, name := range flists {
+		log.Infof("flist '%s' created", name)
+	}
+
+	return nil
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/codegangsta/cli"
+	"github.com/threefoldtech/0-fs/meta"
+)
+
+var jsonFlag = cli.BoolFlag{
+	Name:  "json",
+	Usage: "print the output as json",
+}
+
+var inspectCommands = []cli.Command{
+	{
+		Name:      "ls",
+		Usage:     "list a directory of an flist",
+		ArgsUsage: "<flist> [path]",
+		Flags:     []cli.Flag{jsonFlag},
+		Action:    inspect(ls),
+	},
+	{
+		Name:      "stat",
+		Usage:     "print the metadata of an flist entry",
+		ArgsUsage: "<flist> <path>",
+		Flags:     []cli.Flag{jsonFlag},
+		Action:    inspect(stat),
+	},
+	{
+		Name:      "cat",
+		Usage:     "print the content of a file of an flist",
+		ArgsUsage: "<flist> <path>",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "storage-url",
+				Usage: "fallback storage url in case the flist router.yaml doesn't have the blocks",
+			},
+		},
+		Action: inspect(cat),
+	},
+	{
+		Name:      "tree",
+		Usage:     "print the tree of a directory of an flist",
+		ArgsUsage: "<flist> [path]",
+		Flags:     []cli.Flag{jsonFlag},
+		Action:    inspect(tree),
+	},
+	{
+		Name:      "du",
+		Usage:     "print the disk usage of the entries of a directory of an flist",
+		ArgsUsage: "<flist> [path]",
+		Flags:     []cli.Flag{jsonFlag},
+		Action:    inspect(du),
+	},
+}
+
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+type inspectFn func(ctx *cli.Context, store *flistStore, p string) error
+
+// inspect opens the meta store of the flist given as first argument, and calls fn
+// with the path given as second argument
+func inspect(fn inspectFn) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		args := ctx.Args()
+		if len(args) < 1 || len(args) > 2 {
+			return fmt.Errorf("expecting an flist and an optional path")
+		}
+
+		store, err := openMeta(args.Get(0))
+		if err != nil {
+			return err
+		}
+
+		defer store.Close()
+		return fn(ctx, store, cleanPath(args.Get(1)))
+	}
+}
+
+func ls(ctx *cli.Context, store *flistStore, p string) error {
+	entries, err := list(store, p)
+	if err != nil {
+		return err
+	}
+
+	if ctx.Bool("json") {
+		return printJSON(entries)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.AlignRight)
+	for _, entry := range entries {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t %s\t %s\n",
+			entry.fileMode, entry.UID, entry.GID, entry.Size,
+			entry.ModificationTime.Format("Jan _2 2006 15:04"), entry.name(),
+		)
+	}
+
+	return w.Flush()
+}
+
+func stat(ctx *cli.Context, store *flistStore, p string) error {
+	m, err := get(store, p)
+	if err != nil {
+		return err
+	}
+
+	entry := newEntryInfo(p, m)
+	if ctx.Bool("json") {
+		return printJSON(entry)
+	}
+
+	fmt.Printf("  Path: /%s\n", entry.Path)
+	fmt.Printf("  Type: %s\n", entry.Type)
+	fmt.Printf("  Mode: %s (%s)\n", entry.Mode, entry.fileMode)
+	fmt.Printf("   Uid: %d\n", entry.UID)
+	fmt.Printf("   Gid: %d\n", entry.GID)
+	fmt.Printf("  Size: %d\n", entry.Size)
+	if entry.Type == "file" {
+		fmt.Printf("Blocks: %d (block size %d)\n", entry.Blocks, entry.BlockSize)
+	}
+	if len(entry.LinkTarget) != 0 {
+		fmt.Printf("Target: %s\n", entry.LinkTarget)
+	}
+	if len(entry.SpecialData) != 0 {
+		fmt.Printf("Device: %s\n", entry.SpecialData)
+	}
+	fmt.Printf("Modify: %s\n", entry.ModificationTime)
+	fmt.Printf("Create: %s\n", entry.CreationTime)
+
+	var keys []string
+	for key := range entry.XAttrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fmt.Printf(" XAttr: %s=%q\n", key, entry.XAttrs[key])
+	}
+
+	return nil
+}
+
+func cat(ctx *cli.Context, store *flistStore, p string) error {
+	m, err := get(store, p)
+	if err != nil {
+		return err
+	}
+
+	if m.Info().Type != meta.RegularType {
+		return fmt.Errorf("'/%s' is not a regular file", p)
+	}
+
+	if err := store.openData(ctx.String("storage-url")); err != nil {
+		return err
+	}
+
+	return writeFile(os.Stdout, store.data, m)
+}
+
+func tree(ctx *cli.Context, store *flistStore, p string) error {
+	root, dirs, files, err := buildTree(store, p)
+	if err != nil {
+		return err
+	}
+
+	if ctx.Bool("json") {
+		return printJSON(root)
+	}
+
+	fmt.Printf("/%s\n", p)
+	printTree(os.Stdout, root, "")
+	fmt.Printf("\n%d directories, %d files\n", dirs, files)
+	return nil
+}
+
+func du(ctx *cli.Context, store *flistStore, p string) error {
+	entries, err := diskUsage(store, p)
+	if err != nil {
+		return err
+	}
+
+	if ctx.Bool("json") {
+		return printJSON(entries)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SIZE\tFILES\tBLOCKS\tPATH")
+	for _, u := range entries {
+		fmt.Fprintf(w, "%d\t%d\t%d\t/%s\n", u.Size, u.Files, u.Blocks, u.Path)
+	}
+
+	return w.Flush()
+}
@@ -2,29 +2,39 @@ package main
 
 import (
 	"fmt"
+	"math"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/codegangsta/cli"
 	"github.com/op/go-logging"
 	g8ufs "github.com/threefoldtech/0-fs"
+	"github.com/threefoldtech/0-fs/rofs"
 )
 
 var log = logging.MustGetLogger("main")
 
 // Cmd is a struct holding all the value of the CLI flags
 type Cmd struct {
-	Meta     []string
-	Backend  string
-	Cache    string
-	URL      string
-	Router   string
-	Reset    bool
-	Debug    bool
-	Daemon   bool
-	PidPath  string
-	LogPath  string
-	ReadOnly bool
+	Meta       []string
+	Backend    string
+	Cache      string
+	CacheSize  uint64
+	CacheFiles uint64
+	CacheMode  rofs.CacheMode
+	URL        string
+	Router     string
+	Reset      bool
+	Debug      bool
+	Daemon     bool
+	PidPath    string
+	LogPath    string
+	ReadOnly   bool
+	Metrics    string
+	Prefetch   string
+	Record     string
+	Inodes     rofs.InodeMode
 }
 
 // Validate command
@@ -35,23 +45,77 @@ func (c *Cmd) Validate() (errs []error) {
 	return nil
 }
 
+// parseSize parses a size string like 100M or 10G into bytes
+func parseSize(value string) (uint64, error) {
+	s := strings.TrimSpace(strings.ToUpper(value))
+	if len(s) == 0 {
+		return 0, nil
+	}
+
+	units := map[byte]uint64{
+		'K': 1 << 10,
+		'M': 1 << 20,
+		'G': 1 << 30,
+		'T': 1 << 40,
+	}
+
+	multiplier := uint64(1)
+	if unit, ok := units[s[len(s)-1]]; ok {
+		multiplier = unit
+		s = s[:len(s)-1]
+	}
+
+	size, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size: %s", err)
+	}
+
+	if size > math.MaxUint64/multiplier {
+		return 0, fmt.Errorf("invalid size: %s is too large", value)
+	}
+
+	return size * multiplier, nil
+}
+
 func action(ctx *cli.Context) error {
 	args := ctx.Args()
 	if len(args) != 1 {
 		return fmt.Errorf("expecting a single mount point argument")
 	}
 
+	cacheSize, err := parseSize(ctx.GlobalString("cache-size"))
+	if err != nil {
+		return err
+	}
+
+	cacheMode, err := rofs.ParseCacheMode(ctx.GlobalString("cache-mode"))
+	if err != nil {
+		return err
+	}
+
+	inodes, err := rofs.ParseInodeMode(ctx.GlobalString("inodes"))
+	if err != nil {
+		return err
+	}
+
 	cmd := Cmd{
-		Meta:     ctx.GlobalStringSlice("meta"),
-		Backend:  ctx.GlobalString("backend"),
-		Cache:    ctx.GlobalString("cache"),
-		URL:      ctx.GlobalString("storage-url"),
-		Router:   ctx.GlobalString("local-router"),
-		Reset:    ctx.GlobalBool("reset"),
-		Daemon:   ctx.GlobalBool("daemon"),
-		PidPath:  ctx.GlobalString("pid"),
-		LogPath:  ctx.GlobalString("log"),
-		ReadOnly: ctx.GlobalBool("ro"),
+		Meta:       ctx.GlobalStringSlice("meta"),
+		Backend:    ctx.GlobalString("backend"),
+		Cache:      ctx.GlobalString("cache"),
+		CacheSize:  cacheSize,
+		CacheFiles: ctx.GlobalUint64("cache-files"),
+		CacheMode:  cacheMode,
+		URL:        ctx.GlobalString("storage-url"),
+		Router:     ctx.GlobalString("local-router"),
+		Reset:      ctx.GlobalBool("reset"),
+		Daemon:     ctx.GlobalBool("daemon"),
+		PidPath:    ctx.GlobalString("pid"),
+		LogPath:    ctx.GlobalString("log"),
+		ReadOnly:   ctx.GlobalBool("ro"),
+		Metrics:    ctx.GlobalString("metrics-listen"),
+		Prefetch:   ctx.GlobalString("prefetch-file"),
+		Record:     ctx.GlobalString("record-file"),
+		Inodes:     inodes,
 	}
 	errs := cmd.Validate()
 	var buf strings.Builder
@@ -71,7 +135,7 @@ func main() {
 	app := cli.App{
 		Name:      "0-fs",
 		Usage:     "start a zero-fs instance by mounting one or more flists into mount target",
-		UsageText: "0-fs [options] <mount-target>",
+		UsageText: "0-fs [options] <mount-target>\n   0-fs command [command options] [arguments...]",
 		Version:   g8ufs.Version().String(),
 		Flags: []cli.Flag{
 			cli.BoolFlag{
@@ -95,6 +159,19 @@ func main() {
 				Name:  "cache",
 				Usage: "external (common) cache directory, if not provided a temporary cache location will be created under `backend`",
 			},
+			cli.StringFlag{
+				Name:  "cache-size",
+				Usage: "max size of the cache directory (e.g. 500M, 10G), least recently used files are evicted once the limit is reached. Unlimited if not set",
+			},
+			cli.Uint64Flag{
+				Name:  "cache-files",
+				Usage: "max number of files (or blocks in block cache mode) in the cache directory, least recently used files are evicted once the limit is reached. Unlimited if not set",
+			},
+			cli.StringFlag{
+				Name:  "cache-mode",
+				Value: rofs.FileCache.String(),
+				Usage: "how downloaded data is kept in the cache: 'file' (one cache file per file) or 'block' (one cache file per block, blocks are shared between files, flists and mounts using the same cache). Use migrate-cache to convert an existing file cache",
+			},
 			cli.StringFlag{
 				Name:  "storage-url",
 				Value: "zdb://hub.grid.tf:9900",
@@ -112,6 +189,23 @@ func main() {
 				Name:  "log",
 				Usage: "write logs to file (default to stderr)",
 			},
+			cli.StringFlag{
+				Name:  "metrics-listen",
+				Usage: "listen address (e.g. :9100) to expose prometheus metrics under /metrics. Disabled if not set",
+			},
+			cli.StringFlag{
+				Name:  "prefetch-file",
+				Usage: "file with the list of paths (one per line) to download to the cache in the background once mounted",
+			},
+			cli.StringFlag{
+				Name:  "record-file",
+				Usage: "write the path of each opened file to this file in the order of first access, to be used as --prefetch-file on the next mount",
+			},
+			cli.StringFlag{
+				Name:  "inodes",
+				Value: rofs.CacheInodes.String(),
+				Usage: "how inode numbers are reported: 'cache' (inode of the cached file, unstable), 'stable' (derived from the path) or 'hardlink' (stable, and files with the same content and metadata are hard links)",
+			},
 			cli.BoolFlag{
 				Name:  "daemon,d",
 				Usage: "start 0-fs as a daemon",
@@ -150,6 +244,18 @@ func main() {
 			return nil
 		},
 		Action: action,
+		Commands: append([]cli.Command{
+			createCommand,
+			importOCICommand,
+			extractCommand,
+			diffCommand,
+			verifyCommand,
+			prefetchCommand,
+			migrateCacheCommand,
+			serveCommand,
+			serveBlocksCommand,
+			commitCommand,
+		}, inspectCommands...),
 	}
 
 	if err := app.Run(os.Args); err != nil {
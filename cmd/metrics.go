@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// serveMetrics exposes the prometheus metrics on listen under /metrics. The
+// address is bound before it returns, so a bad address fails the mount, and
+// the metrics are served in the background
+func serveMetrics(listen string) error {
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+	}
+
+	log.Infof("serving metrics on %s", listener.Addr())
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			log.Errorf("metrics server failed: %s", err)
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/codegangsta/cli"
+	"github.com/threefoldtech/0-fs/rofs"
+)
+
+var migrateCacheCommand = cli.Command{
+	Name:      "migrate-cache",
+	Usage:     "copy the cached files of flists to the block cache (see --cache-mode) so they are not downloaded again",
+	ArgsUsage: "<flist> [flists...]",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "cache",
+			Value: "/tmp/backend/ca",
+			Usage: "cache directory to migrate",
+		},
+		cli.BoolFlag{
+			Name:  "keep",
+			Usage: "keep the migrated cache files, by default they are removed unless in use",
+		},
+	},
+	Action: migrateCache,
+}
+
+func migrateCache(ctx *cli.Context) error {
+	args := ctx.Args()
+	if len(args) < 1 {
+		return fmt.Errorf("expecting one or more flists")
+	}
+
+	dir := ctx.String("cache")
+	cache := rofs.NewCache(dir, nil)
+	cache.SetMode(rofs.BlockCache)
+
+	for _, name := range args {
+		store, err := openMeta(name)
+		if err != nil {
+			return err
+		}
+
+		count, err := cache.Migrate(store, !ctx.Bool("keep"))
+		store.Close()
+		if err != nil {
+			return fmt.Errorf("failed to migrate '%s': %s", name, err)
+		}
+
+		log.Infof("migrated %d files of '%s' to '%s'", count, name, dir)
+	}
+
+	return nil
+}
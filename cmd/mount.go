@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"path"
@@ -10,16 +11,36 @@ import (
 	"time"
 
 	"github.com/sevlyar/go-daemon"
-	"github.com/threefoldtech/0-fs/meta"
+	"github.com/threefoldtech/0-fs/rofs"
 
 	g8ufs "github.com/threefoldtech/0-fs"
 )
 
-func start(cmd *Cmd, name, target string) (*g8ufs.G8ufs, error) {
+// readPrefetch reads the list of paths to prefetch
+func readPrefetch(name string) ([]string, error) {
+	if len(name) == 0 {
+		return nil, nil
+	}
+
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+	return rofs.ReadPathList(file)
+}
+
+func start(cmd *Cmd, name, target string, record io.Writer) (*g8ufs.G8ufs, error) {
+	prefetch, err := readPrefetch(cmd.Prefetch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prefetch file: %s", err)
+	}
+
 	// Test if the meta path is a directory
 	// if not, it's maybe a flist/tar.gz
 
-	metaStore, dataStore, err := getStoresFromCmd(cmd)
+	metaStore, dataStore, layers, err := getStoresFromCmd(cmd)
 
 	if err != nil {
 		return nil, err
@@ -28,14 +49,21 @@ func start(cmd *Cmd, name, target string) (*g8ufs.G8ufs, error) {
 	log.Debug("router\n", dataStore)
 
 	return g8ufs.Mount(&g8ufs.Options{
-		Name:     name,
-		Store:    metaStore,
-		Backend:  cmd.Backend,
-		Cache:    cmd.Cache,
-		Target:   target,
-		Storage:  dataStore,
-		Reset:    cmd.Reset,
-		ReadOnly: cmd.ReadOnly,
+		Name:       name,
+		Store:      metaStore,
+		Backend:    cmd.Backend,
+		Cache:      cmd.Cache,
+		CacheSize:  cmd.CacheSize,
+		CacheFiles: cmd.CacheFiles,
+		CacheMode:  cmd.CacheMode,
+		Target:     target,
+		Storage:    dataStore,
+		Layers:     layers,
+		Reset:      cmd.Reset,
+		ReadOnly:   cmd.ReadOnly,
+		Prefetch:   prefetch,
+		Record:     record,
+		Inodes:     cmd.Inodes,
 	})
 }
 
@@ -50,21 +78,22 @@ func reload(fs *g8ufs.G8ufs, cmd *Cmd) error {
 	}
 
 	//rebuild the stores
-	extra := strings.Split(string(content), "\n")
-	extraMeta, err := getMetaStore(extra)
-	if err != nil {
-		return err
+	var extra []string
+	for _, line := range strings.Split(string(content), "\n") {
+		if line = strings.TrimSpace(line); len(line) != 0 {
+			extra = append(extra, line)
+		}
 	}
 
-	// - first use the ones passed via command line
-	metaStore, _, err := getStoresFromCmd(cmd)
+	// - first use the ones passed via command line, then add
+	// the extra on top, and build the data store from all flists
+	metaStore, dataStore, layers, err := getStoresFromCmd(cmd, extra...)
 	if err != nil {
 		return err
 	}
 
-	// - then add the extra on top
-	metaStore = meta.Layered(metaStore, extraMeta)
-	fs.SetMetaStore(metaStore)
+	// the old stores and routers are closed once not used anymore
+	fs.Reload(metaStore, dataStore, layers)
 
 	return nil
 }
@@ -127,7 +156,24 @@ func mount(cmd *Cmd, target string) error {
 			log.Error(err)
 		}
 	}()
-	fs, err = start(cmd, fmt.Sprint(syscall.Getpid()), target)
+	if len(cmd.Metrics) != 0 {
+		if err := serveMetrics(cmd.Metrics); err != nil {
+			return err
+		}
+	}
+
+	var record io.Writer
+	if len(cmd.Record) != 0 {
+		file, err := os.Create(cmd.Record)
+		if err != nil {
+			return err
+		}
+
+		defer file.Close()
+		record = file
+	}
+
+	fs, err = start(cmd, fmt.Sprint(syscall.Getpid()), target, record)
 	if err != nil {
 		return err
 	}
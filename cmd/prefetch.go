@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/codegangsta/cli"
+	"github.com/threefoldtech/0-fs/rofs"
+)
+
+var prefetchCommand = cli.Command{
+	Name:      "prefetch",
+	Usage:     "download files of an flist to a cache directory ahead of mounting it",
+	ArgsUsage: "<flist> [paths...]",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "cache",
+			Value: "/tmp/backend/ca",
+			Usage: "cache directory to fill, must be the cache (or `backend`/ca) used by the mount",
+		},
+		cli.StringFlag{
+			Name:  "cache-mode",
+			Value: rofs.FileCache.String(),
+			Usage: "cache mode (file or block), must be the cache mode used by the mount",
+		},
+		cli.StringFlag{
+			Name:  "prefetch-file",
+			Usage: "file with the list of paths (one per line) to prefetch, in addition to the paths arguments",
+		},
+		cli.StringFlag{
+			Name:  "storage-url",
+			Usage: "fallback storage url in case the flist router.yaml doesn't have the blocks",
+		},
+		cli.IntFlag{
+			Name:  "workers",
+			Usage: "number of files to download in parallel",
+			Value: rofs.DefaultPrefetchWorkers,
+		},
+	},
+	Action: prefetch,
+}
+
+func prefetch(ctx *cli.Context) error {
+	args := ctx.Args()
+	if len(args) < 1 {
+		return fmt.Errorf("expecting an flist and optional paths")
+	}
+
+	mode, err := rofs.ParseCacheMode(ctx.String("cache-mode"))
+	if err != nil {
+		return err
+	}
+
+	paths, err := readPrefetch(ctx.String("prefetch-file"))
+	if err != nil {
+		return err
+	}
+
+	for _, p := range args.Tail() {
+		paths = append(paths, cleanPath(p))
+	}
+
+	if len(paths) == 0 {
+		// the whole flist
+		paths = []string{""}
+	}
+
+	store, err := openFlist(args.First(), ctx.String("storage-url"))
+	if err != nil {
+		return err
+	}
+
+	defer store.Close()
+
+	dir := ctx.String("cache")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	cache := rofs.NewCache(dir, store.data)
+	cache.SetMode(mode)
+	count := cache.Prefetch(context.Background(), store, paths, ctx.Int("workers"))
+	log.Infof("prefetched %d files to '%s'", count, dir)
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/codegangsta/cli"
+	"github.com/threefoldtech/0-fs/flistfs"
+	"github.com/threefoldtech/0-fs/rofs"
+)
+
+var serveCommand = cli.Command{
+	Name:      "serve",
+	Usage:     "serve the content of an flist over HTTP (and optionally WebDAV) without mounting it",
+	ArgsUsage: "<flist>",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "listen",
+			Value: ":8080",
+			Usage: "address to listen on",
+		},
+		cli.StringFlag{
+			Name:  "cache",
+			Value: "/tmp/backend/ca",
+			Usage: "cache directory, downloaded blocks are kept there and served locally afterwards",
+		},
+		cli.StringFlag{
+			Name:  "cache-mode",
+			Value: rofs.FileCache.String(),
+			Usage: "cache mode (file or block), must be the cache mode of any mount that shares the cache",
+		},
+		cli.StringFlag{
+			Name:  "storage-url",
+			Usage: "fallback storage url in case the flist router.yaml doesn't have the blocks",
+		},
+		cli.BoolFlag{
+			Name:  "webdav",
+			Usage: "serve the (read only) WebDAV methods as well, so the flist can be browsed with WebDAV clients",
+		},
+	},
+	Action: serve,
+}
+
+func serve(ctx *cli.Context) error {
+	args := ctx.Args()
+	if len(args) != 1 {
+		return fmt.Errorf("expecting an flist")
+	}
+
+	mode, err := rofs.ParseCacheMode(ctx.String("cache-mode"))
+	if err != nil {
+		return err
+	}
+
+	store, err := openFlist(args.First(), ctx.String("storage-url"))
+	if err != nil {
+		return err
+	}
+
+	defer store.Close()
+
+	dir := ctx.String("cache")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	cache := rofs.NewCache(dir, store.data)
+	cache.SetMode(mode)
+
+	listen := ctx.String("listen")
+	log.Infof("serving '%s' on %s", args.First(), listen)
+	return flistfs.NewServer(listen, flistfs.NewCached(store, cache), ctx.Bool("webdav")).ListenAndServe()
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"os"
 	"path"
 
@@ -9,6 +10,9 @@ import (
 	"github.com/threefoldtech/0-fs/storage/router"
 )
 
+// errNoStorage is returned when the blocks of the flists can't be looked up anywhere
+var errNoStorage = errors.New("the flist has no router.yaml and no storage url is set (see --storage-url)")
+
 func getDB(db string) (string, error) {
 	f, err := os.Open(db)
 	if err != nil {
@@ -33,8 +37,18 @@ func getDB(db string) (string, error) {
 	return db, nil
 }
 
-func getMetaStore(dbs []string) (meta.Store, error) {
-	var stores []meta.Store
+// getMetaStores opens the meta store of each flist, dbs entries are updated
+// with the path of the extracted flists. Empty entries have a nil store
+func getMetaStores(dbs []string) ([]meta.Store, error) {
+	stores := make([]meta.Store, len(dbs))
+
+	closeAll := func() {
+		for _, store := range stores {
+			if store != nil {
+				store.Close()
+			}
+		}
+	}
 
 	for i, db := range dbs {
 		if len(db) == 0 {
@@ -43,6 +57,7 @@ func getMetaStore(dbs []string) (meta.Store, error) {
 		var err error
 		db, err = getDB(db)
 		if err != nil {
+			closeAll()
 			return nil, err
 		}
 
@@ -51,31 +66,63 @@ func getMetaStore(dbs []string) (meta.Store, error) {
 
 		store, err := meta.NewStore(db)
 		if err != nil {
+			closeAll()
 			return nil, err
 		}
 
-		stores = append(stores, store)
+		stores[i] = store
+	}
+
+	return stores, nil
+}
+
+// getRouter returns the router of the flist router.yaml, or nil if
+// the flist has no router.yaml
+func getRouter(db string) (*router.Router, error) {
+	cfg, err := router.NewConfigFromFile(path.Join(db, "router.yaml"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
 	}
 
-	return meta.Layered(stores...), nil
+	return cfg.Router(nil)
 }
 
 func getDataStore(dbs []string, fb *router.Router) (*router.Router, error) {
+	dataStore, _, err := getDataStores(dbs, fb)
+	return dataStore, err
+}
+
+// getDataStores returns a data store that looks up blocks in the routers of all the
+// flists, and the data store of each flist that only looks up blocks in the flist
+// own router (nil if the flist has no router.yaml). Both include the fallback router
+// if not nil. On error, the routers of the flists are closed, but not the fallback router
+func getDataStores(dbs []string, fb *router.Router) (*router.Router, []*router.Router, error) {
 	var routers []*router.Router
-	for _, db := range dbs {
-		cfg, err := router.NewConfigFromFile(path.Join(db, "router.yaml"))
-		if os.IsNotExist(err) {
+	layers := make([]*router.Router, len(dbs))
+	for i, db := range dbs {
+		if len(db) == 0 {
 			continue
-		} else if err != nil {
-			return nil, err
 		}
 
-		r, err := cfg.Router(nil)
+		r, err := getRouter(db)
 		if err != nil {
-			return nil, err
+			for _, r := range routers {
+				r.Close()
+			}
+			return nil, nil, err
+		}
+
+		if r == nil {
+			// flists without router.yaml (older flists) keep using
+			// the routers of all the flists
+			continue
 		}
 
 		routers = append(routers, r)
+		// pools are shared with the merged router
+		layers[i] = router.Merge(r, fb)
 	}
 
 	if fb != nil {
@@ -83,13 +130,18 @@ func getDataStore(dbs []string, fb *router.Router) (*router.Router, error) {
 		routers = append(routers, fb)
 	}
 
-	return router.Merge(routers...), nil
+	if len(routers) == 0 {
+		return nil, nil, errNoStorage
+	}
+
+	return router.Merge(routers...), layers, nil
 }
 
-func layerLocalStore(local string, store *router.Router) (*router.Router, error) {
+// getLocalRouter returns the router of the local router.yaml, nil if not set
+func getLocalRouter(local string) (*router.Router, error) {
 	if len(local) == 0 {
 		//no local router
-		return store, nil
+		return nil, nil
 	}
 
 	config, err := router.NewConfigFromFile(local)
@@ -97,40 +149,72 @@ func layerLocalStore(local string, store *router.Router) (*router.Router, error)
 		return nil, err
 	}
 
-	localRouter, err := config.Router(nil)
-	if err != nil {
-		return nil, err
-	}
-
-	return router.Merge(localRouter, store), nil
+	return config.Router(nil)
 }
 
-// getStoresFromCmd helper function to initialize stores from cmd line
-func getStoresFromCmd(cmd *Cmd) (metaStore meta.Store, dataStore *router.Router, err error) {
-	metaStore, err = getMetaStore(cmd.Meta)
+// getStoresFromCmd helper function to initialize stores from cmd line, and the extra
+// flists (layered on top) if any. The data store looks up blocks in the routers of all
+// flists, while the layers map each flist meta store to a data store that only uses
+// the flist own router. Both data stores are layered with the local router
+func getStoresFromCmd(cmd *Cmd, extra ...string) (metaStore meta.Store, dataStore *router.Router, layers map[meta.Store]storage.Storage, err error) {
+	dbs := append(append([]string{}, cmd.Meta...), extra...)
+	stores, err := getMetaStores(dbs)
 	if err != nil {
 		return
 	}
 
+	//keep the extracted flists paths
+	copy(cmd.Meta, dbs)
+	metaStore = meta.Layered(stores...)
+
+	defer func() {
+		if err != nil {
+			metaStore.Close()
+		}
+	}()
+
+	var fallback *router.Router
 	if len(cmd.URL) != 0 {
 		//prepare the fallback storage
-		dataStore, err = storage.NewSimpleStorage(cmd.URL)
+		fallback, err = storage.NewSimpleStorage(cmd.URL)
 		if err != nil {
 			return
 		}
 	}
 
-	//get a merged datastore from all flists
-	dataStore, err = getDataStore(cmd.Meta, dataStore)
+	//get a merged datastore from all flists, and one for each flist
+	dataStore, routers, err := getDataStores(dbs, fallback)
 	if err != nil {
+		if fallback != nil {
+			fallback.Close()
+		}
 		return
 	}
 
 	//finally merge with local router.yaml
-	dataStore, err = layerLocalStore(cmd.Router, dataStore)
+	local, err := getLocalRouter(cmd.Router)
 	if err != nil {
+		// the merged data store holds the pools of all the routers
+		dataStore.Close()
 		return
 	}
 
+	if local != nil {
+		dataStore = router.Merge(local, dataStore)
+	}
+
+	layers = make(map[meta.Store]storage.Storage)
+	for i, store := range stores {
+		if store == nil || routers[i] == nil {
+			continue
+		}
+
+		if local != nil {
+			routers[i] = router.Merge(local, routers[i])
+		}
+
+		layers[store] = routers[i]
+	}
+
 	return
 }
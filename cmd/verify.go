@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/codegangsta/cli"
+	"github.com/threefoldtech/0-fs/flist"
+)
+
+var verifyCommand = cli.Command{
+	Name:      "verify",
+	Usage:     "check the integrity of an flist and the availability of its blocks",
+	ArgsUsage: "<flist>",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "download",
+			Usage: "download every block and validate its hash, by default only the blocks availability is checked",
+		},
+		cli.StringFlag{
+			Name:  "storage-url",
+			Usage: "fallback storage url in case the flist router.yaml doesn't have the blocks",
+		},
+		cli.BoolFlag{
+			Name:  "strict",
+			Usage: "fail on warnings too (e.g. absolute symlinks to paths outside of the flist, like /proc)",
+		},
+		cli.IntFlag{
+			Name:  "workers",
+			Usage: "number of blocks to check in parallel",
+			Value: flist.DefaultVerifyWorkers,
+		},
+		jsonFlag,
+	},
+	Action: verify,
+}
+
+func verify(ctx *cli.Context) error {
+	args := ctx.Args()
+	if len(args) != 1 {
+		return fmt.Errorf("expecting an flist")
+	}
+
+	store, err := openFlist(args.Get(0), ctx.String("storage-url"))
+	if err != nil {
+		return err
+	}
+
+	defer store.Close()
+
+	report, err := flist.Verify(store, store.data, ctx.Bool("download"), ctx.Int("workers"))
+	if err != nil {
+		return err
+	}
+
+	if ctx.Bool("json") {
+		if report.Problems == nil {
+			report.Problems = []flist.Problem{}
+		}
+		if report.Warnings == nil {
+			report.Warnings = []flist.Problem{}
+		}
+		if err := printJSON(report); err != nil {
+			return err
+		}
+	} else {
+		for _, warning := range report.Warnings {
+			fmt.Printf("/%s: warning: %s\n", warning.Path, warning.Error)
+		}
+
+		for _, problem := range report.Problems {
+			fmt.Printf("/%s: %s\n", problem.Path, problem.Error)
+		}
+
+		fmt.Printf("%d entries, %d files, %d links, %d blocks checked\n",
+			report.Entries, report.Files, report.Links, report.Blocks)
+	}
+
+	if !report.OK() {
+		return fmt.Errorf("%d problems found", len(report.Problems))
+	}
+
+	if ctx.Bool("strict") && len(report.Warnings) != 0 {
+		return fmt.Errorf("%d warnings found", len(report.Warnings))
+	}
+
+	return nil
+}
@@ -0,0 +1,260 @@
+package flist
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"syscall"
+
+	"github.com/op/go-logging"
+	"github.com/threefoldtech/0-fs/meta"
+	"github.com/threefoldtech/0-fs/rofs"
+	"github.com/threefoldtech/0-fs/storage"
+	"github.com/threefoldtech/0-fs/storage/router"
+	"golang.org/x/sys/unix"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// RouterName is the name of the routing table file in an flist
+	RouterName = "router.yaml"
+)
+
+var (
+	log = logging.MustGetLogger("flist")
+)
+
+// Builder builds a new flist. File blocks are uploaded to a storage while the
+// metadata is kept in a temporary directory until the flist is packed
+type Builder struct {
+	root     string
+	writer   *meta.Writer
+	uploader *rofs.Uploader
+	packed   bool
+}
+
+// NewBuilder creates a new builder that uploads the file blocks to storage
+func NewBuilder(storage storage.Setter) (*Builder, error) {
+	root, err := os.MkdirTemp("", "flist-")
+	if err != nil {
+		return nil, err
+	}
+
+	writer, err := meta.NewWriter(root)
+	if err != nil {
+		os.RemoveAll(root)
+		return nil, err
+	}
+
+	return &Builder{
+		root:     root,
+		writer:   writer,
+		uploader: rofs.NewUploader(storage),
+	}, nil
+}
+
+// Add adds an entry to the flist at path p. The content of regular
+// files is read from r and uploaded, r is ignored for all other types
+func (b *Builder) Add(p string, info meta.Info, r io.Reader) error {
+	var blocks []meta.BlockInfo
+	if info.Type == meta.RegularType {
+		var err error
+		if blocks, err = b.uploader.Upload(r); err != nil {
+			return err
+		}
+
+		info.FileBlockSize = b.uploader.BlockSize()
+	}
+
+	return b.writer.Add(p, info, blocks)
+}
+
+// AddDir walks the local directory src and adds all its content to the flist
+// under the same relative path. Symlinks are added as is and never followed.
+// Hard links are added as separate files, the content of each link is read
+// and uploaded again
+func (b *Builder) AddDir(src string) error {
+	return filepath.Walk(src, func(name string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, name)
+		if err != nil {
+			return err
+		}
+
+		info, err := Stat(name, fi)
+		if err != nil {
+			return err
+		}
+
+		return b.addFile(name, rel, info)
+	})
+}
+
+// addFile adds the local file name to the flist at path rel, the content of
+// regular files is read from name
+func (b *Builder) addFile(name, rel string, info meta.Info) error {
+	log.Debugf("adding '%s' (%s)", rel, info.Type)
+	if info.Type != meta.RegularType {
+		return b.Add(rel, info, nil)
+	}
+
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+	return b.Add(rel, info, file)
+}
+
+// Pack writes the flist archive to w. If config is not nil it's used as the
+// flist routing table. The builder can't be used after Pack
+func (b *Builder) Pack(w io.Writer, config *router.Config) error {
+	b.packed = true
+	if err := b.writer.Close(); err != nil {
+		return err
+	}
+
+	if config != nil {
+		data, err := yaml.Marshal(config)
+		if err != nil {
+			return err
+		}
+
+		if err := os.WriteFile(path.Join(b.root, RouterName), data, 0644); err != nil {
+			return err
+		}
+	}
+
+	return meta.Pack(b.root, w)
+}
+
+// Close removes the temporary files of the builder
+func (b *Builder) Close() error {
+	if !b.packed {
+		if err := b.writer.Close(); err != nil {
+			log.Errorf("failed to close flist db: %s", err)
+		}
+	}
+
+	return os.RemoveAll(b.root)
+}
+
+// Stat converts the local file info of the file name into flist meta info
+func Stat(name string, fi os.FileInfo) (meta.Info, error) {
+	stat, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok || stat == nil {
+		return meta.Info{}, fmt.Errorf("failed to get stat of '%s'", name)
+	}
+
+	info := meta.Info{
+		CreationTime:     uint32(stat.Ctim.Sec),
+		ModificationTime: uint32(stat.Mtim.Sec),
+		Access: meta.Access{
+			UID:  stat.Uid,
+			GID:  stat.Gid,
+			Mode: stat.Mode & 07777,
+		},
+		Type: meta.NodeType(stat.Mode & syscall.S_IFMT),
+	}
+
+	switch info.Type {
+	case meta.RegularType:
+		info.Size = uint64(stat.Size)
+	case meta.LinkType:
+		target, err := os.Readlink(name)
+		if err != nil {
+			return info, err
+		}
+		info.LinkTarget = target
+	case meta.BlockDeviceType, meta.CharDeviceType:
+		dev := uint64(stat.Rdev)
+		info.SpecialData = fmt.Sprintf("%d,%d", unix.Major(dev), unix.Minor(dev))
+	}
+
+	attrs, err := xattrs(name)
+	if err != nil {
+		return info, err
+	}
+
+	info.XAttrs = attrs
+	return info, nil
+}
+
+// xattrs reads the extended attributes of file name (without following links)
+func xattrs(name string) (map[string][]byte, error) {
+	size, err := unix.Llistxattr(name, nil)
+	if err == unix.ENOTSUP || size == 0 {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, size)
+	size, err = unix.Llistxattr(name, buf)
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := make(map[string][]byte)
+	for _, attr := range splitNames(buf[:size]) {
+		size, err := unix.Lgetxattr(name, attr, nil)
+		if err == unix.ENODATA {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		value := make([]byte, size)
+		size, err = unix.Lgetxattr(name, attr, value)
+		if err != nil {
+			return nil, err
+		}
+
+		attrs[attr] = value[:size]
+	}
+
+	if len(attrs) == 0 {
+		return nil, nil
+	}
+
+	return attrs, nil
+}
+
+// splitNames splits the null terminated names returned by listxattr
+func splitNames(buf []byte) []string {
+	var names []string
+	start := 0
+	for i, c := range buf {
+		if c == 0 {
+			if i > start {
+				names = append(names, string(buf[start:i]))
+			}
+			start = i + 1
+		}
+	}
+
+	return names
+}
+
+// Create creates an flist archive from the local directory src and writes it to w.
+// File blocks are uploaded to storage, and config is used as the flist routing table
+func Create(src string, w io.Writer, storage storage.Setter, config *router.Config) error {
+	builder, err := NewBuilder(storage)
+	if err != nil {
+		return err
+	}
+
+	defer builder.Close()
+
+	if err := builder.AddDir(src); err != nil {
+		return err
+	}
+
+	return builder.Pack(w, config)
+}
@@ -0,0 +1,127 @@
+package flist
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/threefoldtech/0-fs/internal/testutil"
+	"github.com/threefoldtech/0-fs/meta"
+	"github.com/threefoldtech/0-fs/rofs"
+	"github.com/threefoldtech/0-fs/storage/router"
+	"golang.org/x/sys/unix"
+)
+
+func TestCreate(t *testing.T) {
+	src := t.TempDir()
+
+	if err := os.MkdirAll(path.Join(src, "bin/sub"), 0750); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string][]byte{
+		"small":   testutil.MakeFile(t, path.Join(src, "small"), 100),
+		"bin/big": testutil.MakeFile(t, path.Join(src, "bin/big"), 3*rofs.DefaultBlockSize*1024+10),
+		"empty":   testutil.MakeFile(t, path.Join(src, "empty"), 0),
+	}
+
+	if err := os.Symlink("bin/big", path.Join(src, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := syscall.Mkfifo(path.Join(src, "fifo"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// not all filesystems support user extended attributes
+	withXAttrs := unix.Lsetxattr(path.Join(src, "small"), "user.comment", []byte("small file"), 0) == nil
+
+	storage := testutil.Storage{}
+	config := &router.Config{
+		Pools: map[string]router.PoolConfig{
+			"local": {"00:FF": "zdb://localhost:9900"},
+		},
+		Lookup: []string{"local"},
+	}
+
+	var archive bytes.Buffer
+	if ok := assert.NoError(t, Create(src, &archive, storage, config)); !ok {
+		t.Fatal()
+	}
+
+	dest := t.TempDir()
+	if ok := assert.NoError(t, meta.Unpack(&archive, dest)); !ok {
+		t.Fatal()
+	}
+
+	loaded, err := router.NewConfigFromFile(path.Join(dest, RouterName))
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	assert.Equal(t, config.Pools, loaded.Pools)
+	assert.Equal(t, config.Lookup, loaded.Lookup)
+
+	store, err := meta.NewStore(dest)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	defer store.Close()
+
+	for name, data := range files {
+		m, ok := store.Get(name)
+		if ok := assert.True(t, ok, name); !ok {
+			continue
+		}
+
+		info := m.Info()
+		assert.Equal(t, meta.RegularType, info.Type, name)
+		assert.Equal(t, uint64(len(data)), info.Size, name)
+		assert.Equal(t, uint32(0640), info.Access.Mode, name)
+
+		if len(data) == 0 {
+			assert.Empty(t, m.Blocks())
+			continue
+		}
+
+		output, err := os.CreateTemp(t.TempDir(), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ok := assert.NoError(t, rofs.NewDownloader(storage, m).Download(output)); !ok {
+			t.Fatal()
+		}
+		output.Close()
+
+		downloaded, err := os.ReadFile(output.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, data, downloaded, name)
+	}
+
+	sub, ok := store.Get("bin/sub")
+	if ok := assert.True(t, ok); ok {
+		assert.True(t, sub.IsDir())
+		assert.Equal(t, uint32(0750), sub.Info().Access.Mode)
+	}
+
+	link, ok := store.Get("link")
+	if ok := assert.True(t, ok); ok {
+		assert.Equal(t, meta.LinkType, link.Info().Type)
+		assert.Equal(t, "bin/big", link.Info().LinkTarget)
+	}
+
+	fifo, ok := store.Get("fifo")
+	if ok := assert.True(t, ok); ok {
+		assert.Equal(t, meta.FIFOType, fifo.Info().Type)
+	}
+
+	if withXAttrs {
+		small, _ := store.Get("small")
+		assert.Equal(t, map[string][]byte{"user.comment": []byte("small file")}, small.Info().XAttrs)
+	}
+}
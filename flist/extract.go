@@ -0,0 +1,557 @@
+package flist
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/threefoldtech/0-fs/meta"
+	"github.com/threefoldtech/0-fs/rofs"
+	"github.com/threefoldtech/0-fs/storage"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// DefaultExtractWorkers is the default number of files downloaded in parallel
+	DefaultExtractWorkers = 4
+
+	partialSuffix = ".0-fs-partial"
+	progressEvery = 100
+)
+
+type walkEntry struct {
+	path string
+	meta meta.Meta
+}
+
+// entryPath validates the path of an flist entry, a path that is absolute or
+// outside of the flist root is rejected, so a crafted flist can't write outside
+// of the extract destination
+func entryPath(p string) (string, error) {
+	if len(p) == 0 {
+		return p, nil
+	}
+
+	clean := path.Clean(p)
+	if path.IsAbs(p) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("invalid entry path '%s'", p)
+	}
+
+	if clean == "." {
+		clean = ""
+	}
+
+	return clean, nil
+}
+
+// entries walks over the full flist, directories come before their content
+func entries(store meta.Walker) ([]walkEntry, error) {
+	var list []walkEntry
+	err := store.Walk("", func(p string, m meta.Meta) error {
+		p, err := entryPath(p)
+		if err != nil {
+			return err
+		}
+
+		list = append(list, walkEntry{path: p, meta: m})
+		return nil
+	})
+
+	return list, err
+}
+
+// target is the local path of the entry p under dest. None of the parents of
+// the entry under dest can be a symlink, otherwise writing to the entry would
+// write to wherever the link points
+func target(dest, p string) (string, error) {
+	name := dest
+	parts := strings.Split(p, "/")
+	for _, part := range parts[:len(parts)-1] {
+		name = filepath.Join(name, part)
+		stat, err := os.Lstat(name)
+		if os.IsNotExist(err) {
+			break
+		} else if err != nil {
+			return "", err
+		}
+
+		if stat.Mode()&os.ModeSymlink != 0 {
+			return "", fmt.Errorf("parent '%s' of '%s' is a symlink", name, p)
+		}
+	}
+
+	return filepath.Join(dest, p), nil
+}
+
+type progress struct {
+	done  int64
+	total int
+}
+
+func (p *progress) inc() {
+	done := atomic.AddInt64(&p.done, 1)
+	if done%progressEvery == 0 || int(done) == p.total {
+		log.Infof("extracted %d/%d files", done, p.total)
+	}
+}
+
+// Extract writes the full content of the flist to the dest directory, file blocks are
+// downloaded from storage, up to workers files are downloaded in parallel. Ownership
+// (only if running as root), modes, modification times and extended attributes are
+// restored. Extract can be resumed, files that were already fully extracted to dest
+// are not downloaded again
+func Extract(store meta.Walker, storage storage.Storage, dest string, workers int) error {
+	if workers <= 0 {
+		workers = DefaultExtractWorkers
+	}
+
+	list, err := entries(store)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return err
+	}
+
+	var files []walkEntry
+	var dirs []walkEntry
+	for _, entry := range list {
+		name, err := target(dest, entry.path)
+		if err != nil {
+			return err
+		}
+
+		info := entry.meta.Info()
+
+		switch info.Type {
+		case meta.DirType:
+			if err := makeDir(name); err != nil {
+				return err
+			}
+			dirs = append(dirs, entry)
+		case meta.RegularType:
+			files = append(files, entry)
+		default:
+			if err := makeNode(name, info); err != nil {
+				return err
+			}
+
+			if err := setAttrs(name, info); err != nil {
+				return err
+			}
+		}
+	}
+
+	counter := progress{total: len(files)}
+	feed := make(chan walkEntry)
+	group, ctx := errgroup.WithContext(context.Background())
+
+	for i := 0; i < workers; i++ {
+		group.Go(func() error {
+			for entry := range feed {
+				name, err := target(dest, entry.path)
+				if err != nil {
+					return err
+				}
+
+				if err := extractFile(storage, name, entry.meta); err != nil {
+					return fmt.Errorf("failed to extract '%s': %s", entry.path, err)
+				}
+				counter.inc()
+			}
+
+			return nil
+		})
+	}
+
+	group.Go(func() error {
+		defer close(feed)
+		for _, entry := range files {
+			select {
+			case feed <- entry:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+
+		return nil
+	})
+
+	if err := group.Wait(); err != nil {
+		return err
+	}
+
+	// directories attributes are set last, deepest first, since
+	// creating their content changes their modification time
+	for i := len(dirs) - 1; i >= 0; i-- {
+		entry := dirs[i]
+		name, err := target(dest, entry.path)
+		if err != nil {
+			return err
+		}
+
+		if err := setAttrs(name, entry.meta.Info()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// prepare removes name if it exists and is not of type nodeType
+func prepare(name string, nodeType meta.NodeType) (exists bool, err error) {
+	stat, err := os.Lstat(name)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	if stat.Mode().IsRegular() && nodeType == meta.RegularType ||
+		stat.IsDir() && nodeType == meta.DirType {
+		return true, nil
+	}
+
+	return false, os.RemoveAll(name)
+}
+
+func makeDir(name string) error {
+	exists, err := prepare(name, meta.DirType)
+	if err != nil {
+		return err
+	}
+
+	// the directory must be writable until its content is extracted
+	if exists {
+		return os.Chmod(name, 0700)
+	}
+
+	return os.Mkdir(name, 0700)
+}
+
+func makeNode(name string, info meta.Info) error {
+	// other types are always recreated
+	if _, err := prepare(name, meta.UnknownType); err != nil {
+		return err
+	}
+
+	var major, minor uint32
+	if info.SpecialData != "" {
+		fmt.Sscanf(info.SpecialData, "%d,%d", &major, &minor)
+	}
+
+	switch info.Type {
+	case meta.LinkType:
+		return os.Symlink(info.LinkTarget, name)
+	case meta.FIFOType:
+		return unix.Mkfifo(name, info.Access.Mode)
+	case meta.CharDeviceType, meta.BlockDeviceType, meta.SocketType:
+		err := unix.Mknod(name, uint32(info.Type)|info.Access.Mode, int(unix.Mkdev(major, minor)))
+		if err == unix.EPERM {
+			log.Warningf("not allowed to create %s '%s', skipping", info.Type, name)
+			return nil
+		}
+		return err
+	default:
+		return fmt.Errorf("unknown type of '%s'", name)
+	}
+}
+
+// extracted checks if the file name was already fully extracted
+func extracted(name string, info meta.Info) bool {
+	stat, err := os.Lstat(name)
+	if err != nil || !stat.Mode().IsRegular() {
+		return false
+	}
+
+	return uint64(stat.Size()) == info.Size && stat.ModTime().Unix() == int64(info.ModificationTime)
+}
+
+func extractFile(storage storage.Storage, name string, m meta.Meta) error {
+	info := m.Info()
+	if extracted(name, info) {
+		return setAttrs(name, info)
+	}
+
+	if _, err := prepare(name, meta.UnknownType); err != nil {
+		return err
+	}
+
+	// the file is downloaded to a partial file first, so an interrupted
+	// extract never leaves a file that looks complete
+	partial := name + partialSuffix
+	if err := os.Remove(partial); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	// never write through a symlink left at the partial path
+	output, err := os.OpenFile(partial, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(partial)
+
+	if err := download(storage, m, output); err != nil {
+		output.Close()
+		return err
+	}
+
+	if err := output.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(partial, name); err != nil {
+		return err
+	}
+
+	return setAttrs(name, info)
+}
+
+// download writes the file content to output
+func download(storage storage.Storage, m meta.Meta, output *os.File) error {
+	info := m.Info()
+	if len(m.Blocks()) != 0 {
+		if err := rofs.NewDownloader(storage, m).Download(output); err != nil {
+			return err
+		}
+	}
+
+	return output.Truncate(int64(info.Size))
+}
+
+// setAttrs sets the ownership, mode, extended attributes and times of name
+func setAttrs(name string, info meta.Info) error {
+	if _, err := os.Lstat(name); os.IsNotExist(err) {
+		// skipped node
+		return nil
+	}
+
+	if os.Geteuid() == 0 {
+		if err := os.Lchown(name, int(info.Access.UID), int(info.Access.GID)); err != nil {
+			return err
+		}
+	}
+
+	if info.Type != meta.LinkType {
+		if err := os.Chmod(name, os.FileMode(info.Access.Mode&0777)|modeBits(info.Access.Mode)); err != nil {
+			return err
+		}
+	}
+
+	for key, value := range info.XAttrs {
+		if err := unix.Lsetxattr(name, key, value, 0); err != nil {
+			log.Warningf("failed to set extended attribute '%s' of '%s': %s", key, name, err)
+		}
+	}
+
+	mtime := unix.NsecToTimespec(time.Unix(int64(info.ModificationTime), 0).UnixNano())
+	return unix.UtimesNanoAt(unix.AT_FDCWD, name, []unix.Timespec{mtime, mtime}, unix.AT_SYMLINK_NOFOLLOW)
+}
+
+// modeBits converts the setuid, setgid and sticky bits to os.FileMode
+func modeBits(mode uint32) os.FileMode {
+	var bits os.FileMode
+	if mode&unix.S_ISUID != 0 {
+		bits |= os.ModeSetuid
+	}
+	if mode&unix.S_ISGID != 0 {
+		bits |= os.ModeSetgid
+	}
+	if mode&unix.S_ISVTX != 0 {
+		bits |= os.ModeSticky
+	}
+
+	return bits
+}
+
+// tarHeader converts an flist entry into a tar header
+func tarHeader(p string, info meta.Info) (*tar.Header, bool) {
+	hdr := &tar.Header{
+		Name:       p,
+		Mode:       int64(info.Access.Mode),
+		Uid:        int(info.Access.UID),
+		Gid:        int(info.Access.GID),
+		ModTime:    time.Unix(int64(info.ModificationTime), 0),
+		ChangeTime: time.Unix(int64(info.CreationTime), 0),
+		Format:     tar.FormatPAX,
+	}
+
+	switch info.Type {
+	case meta.DirType:
+		hdr.Typeflag = tar.TypeDir
+		hdr.Name += "/"
+	case meta.RegularType:
+		hdr.Typeflag = tar.TypeReg
+		hdr.Size = int64(info.Size)
+	case meta.LinkType:
+		hdr.Typeflag = tar.TypeSymlink
+		hdr.Linkname = info.LinkTarget
+	case meta.CharDeviceType, meta.BlockDeviceType:
+		hdr.Typeflag = tar.TypeChar
+		if info.Type == meta.BlockDeviceType {
+			hdr.Typeflag = tar.TypeBlock
+		}
+		fmt.Sscanf(info.SpecialData, "%d,%d", &hdr.Devmajor, &hdr.Devminor)
+	case meta.FIFOType:
+		hdr.Typeflag = tar.TypeFifo
+	default:
+		return nil, false
+	}
+
+	for key, value := range info.XAttrs {
+		if hdr.PAXRecords == nil {
+			hdr.PAXRecords = make(map[string]string)
+		}
+		hdr.PAXRecords[xattrPAXPrefix+key] = string(value)
+	}
+
+	return hdr, true
+}
+
+type downloaded struct {
+	file *os.File
+	err  error
+}
+
+// ExtractTar writes the full content of the flist as a tar archive to w, file blocks
+// are downloaded from storage, up to workers files are downloaded in parallel ahead
+// of the file being written to the archive
+func ExtractTar(store meta.Walker, storage storage.Storage, w io.Writer, workers int) error {
+	if workers <= 0 {
+		workers = DefaultExtractWorkers
+	}
+
+	list, err := entries(store)
+	if err != nil {
+		return err
+	}
+
+	// files are downloaded in order, and consumed in the same order. The
+	// semaphore limits the number of files downloaded ahead
+	results := make([]chan downloaded, len(list))
+	for i, entry := range list {
+		if entry.meta.Info().Type == meta.RegularType {
+			results[i] = make(chan downloaded, 1)
+		}
+	}
+
+	sem := make(chan struct{}, workers)
+	done := make(chan struct{})
+	fed := make(chan int, 1)
+
+	go func() {
+		started := 0
+		defer func() { fed <- started }()
+
+		for i, entry := range list {
+			if results[i] == nil {
+				continue
+			}
+
+			select {
+			case sem <- struct{}{}:
+			case <-done:
+				return
+			}
+
+			started = i + 1
+			go func(m meta.Meta, result chan<- downloaded) {
+				file, err := os.CreateTemp("", "0-fs-extract-")
+				if err == nil {
+					err = download(storage, m, file)
+				}
+				result <- downloaded{file: file, err: err}
+			}(entry.meta, results[i])
+		}
+	}()
+
+	consumed := 0
+	defer func() {
+		// clean up the files downloaded ahead if we stopped early
+		close(done)
+		started := <-fed
+		if consumed >= started {
+			// all the started downloads were consumed
+			return
+		}
+
+		for _, result := range results[consumed:started] {
+			if result == nil {
+				continue
+			}
+
+			if out := <-result; out.file != nil {
+				out.file.Close()
+				os.Remove(out.file.Name())
+			}
+		}
+	}()
+
+	writer := tar.NewWriter(w)
+	counter := progress{total: len(list)}
+	for i, entry := range list {
+		consumed = i + 1
+		if len(entry.path) == 0 {
+			// root directory
+			counter.inc()
+			continue
+		}
+
+		info := entry.meta.Info()
+		hdr, ok := tarHeader(entry.path, info)
+		if !ok {
+			log.Warningf("can't write %s '%s' to a tar, skipping", info.Type, entry.path)
+			counter.inc()
+			continue
+		}
+
+		if info.Type == meta.RegularType {
+			if err := writeTarFile(writer, hdr, results[i], sem); err != nil {
+				return fmt.Errorf("failed to extract '%s': %s", entry.path, err)
+			}
+		} else if err := writer.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		counter.inc()
+	}
+
+	return writer.Close()
+}
+
+func writeTarFile(writer *tar.Writer, hdr *tar.Header, result <-chan downloaded, sem <-chan struct{}) error {
+	out := <-result
+	<-sem
+
+	if out.file != nil {
+		defer func() {
+			out.file.Close()
+			os.Remove(out.file.Name())
+		}()
+	}
+
+	if out.err != nil {
+		return out.err
+	}
+
+	if _, err := out.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	if err := writer.WriteHeader(hdr); err != nil {
+		return err
+	}
+
+	_, err := io.Copy(writer, out.file)
+	return err
+}
@@ -0,0 +1,250 @@
+package flist
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/threefoldtech/0-fs/internal/testutil"
+	"github.com/threefoldtech/0-fs/meta"
+	"github.com/threefoldtech/0-fs/rofs"
+	"github.com/threefoldtech/0-fs/storage/router"
+)
+
+func extractSource(t *testing.T) (string, map[string][]byte, meta.Walker, testutil.Storage) {
+	src := t.TempDir()
+	if err := os.MkdirAll(path.Join(src, "bin/sub"), 0750); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string][]byte{
+		"small":   testutil.MakeFile(t, path.Join(src, "small"), 100),
+		"bin/big": testutil.MakeFile(t, path.Join(src, "bin/big"), 2*rofs.DefaultBlockSize*1024+10),
+		"empty":   testutil.MakeFile(t, path.Join(src, "empty"), 0),
+	}
+
+	if err := os.Symlink("bin/big", path.Join(src, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	mtime := time.Unix(1500000000, 0)
+	for name := range files {
+		if err := os.Chtimes(path.Join(src, name), mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store, storage := createSource(t, src)
+	return src, files, store, storage
+}
+
+// createSource creates an flist of the directory src and opens it
+func createSource(t *testing.T, src string) (meta.Walker, testutil.Storage) {
+	storage := testutil.Storage{}
+	config := &router.Config{
+		Pools:  map[string]router.PoolConfig{"local": {"00:FF": "zdb://localhost:9900"}},
+		Lookup: []string{"local"},
+	}
+
+	var archive bytes.Buffer
+	if err := Create(src, &archive, storage, config); err != nil {
+		t.Fatal(err)
+	}
+
+	db := t.TempDir()
+	if err := meta.Unpack(&archive, db); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := meta.NewStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return store.(meta.Walker), storage
+}
+
+func TestExtract(t *testing.T) {
+	_, files, store, storage := extractSource(t)
+	defer store.Close()
+
+	dest := path.Join(t.TempDir(), "root")
+	if ok := assert.NoError(t, Extract(store, storage, dest, 2)); !ok {
+		t.Fatal()
+	}
+
+	for name, data := range files {
+		content, err := os.ReadFile(path.Join(dest, name))
+		if ok := assert.NoError(t, err, name); !ok {
+			continue
+		}
+		assert.Equal(t, data, content, name)
+
+		stat, _ := os.Stat(path.Join(dest, name))
+		assert.Equal(t, os.FileMode(0640), stat.Mode(), name)
+		assert.Equal(t, int64(1500000000), stat.ModTime().Unix(), name)
+	}
+
+	stat, err := os.Stat(path.Join(dest, "bin/sub"))
+	if ok := assert.NoError(t, err); ok {
+		assert.Equal(t, os.ModeDir|0750, stat.Mode())
+	}
+
+	target, err := os.Readlink(path.Join(dest, "link"))
+	assert.NoError(t, err)
+	assert.Equal(t, "bin/big", target)
+
+	// resume, all the files are already extracted so nothing is downloaded
+	assert.NoError(t, Extract(store, testutil.Storage{}, dest, 2))
+
+	// a partially extracted file is downloaded again
+	if err := os.Truncate(path.Join(dest, "bin/big"), 10); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Error(t, Extract(store, testutil.Storage{}, dest, 2))
+	if ok := assert.NoError(t, Extract(store, storage, dest, 2)); ok {
+		content, _ := os.ReadFile(path.Join(dest, "bin/big"))
+		assert.Equal(t, files["bin/big"], content)
+	}
+}
+
+func TestExtractTar(t *testing.T) {
+	_, files, store, storage := extractSource(t)
+	defer store.Close()
+
+	var archive bytes.Buffer
+	if ok := assert.NoError(t, ExtractTar(store, storage, &archive, 2)); !ok {
+		t.Fatal()
+	}
+
+	found := make(map[string]*tar.Header)
+	reader := tar.NewReader(&archive)
+	for {
+		hdr, err := reader.Next()
+		if err == io.EOF {
+			break
+		} else if ok := assert.NoError(t, err); !ok {
+			t.Fatal()
+		}
+
+		found[hdr.Name] = hdr
+		if data, ok := files[hdr.Name]; ok {
+			content, err := io.ReadAll(reader)
+			assert.NoError(t, err)
+			assert.Equal(t, data, content, hdr.Name)
+			assert.Equal(t, int64(0640), hdr.Mode, hdr.Name)
+		}
+	}
+
+	for name := range files {
+		assert.Contains(t, found, name)
+	}
+
+	if ok := assert.Contains(t, found, "bin/sub/"); ok {
+		assert.Equal(t, byte(tar.TypeDir), found["bin/sub/"].Typeflag)
+	}
+
+	if ok := assert.Contains(t, found, "link"); ok {
+		assert.Equal(t, "bin/big", found["link"].Linkname)
+	}
+
+	// a failed download must not hang
+	assert.Error(t, ExtractTar(store, testutil.Storage{}, io.Discard, 2))
+}
+
+func TestExtractTarLastEntry(t *testing.T) {
+	// the last entry is a symlink after a regular file
+	src := t.TempDir()
+	testutil.MakeFile(t, path.Join(src, "a"), 100)
+	if err := os.Symlink("a", path.Join(src, "z")); err != nil {
+		t.Fatal(err)
+	}
+
+	// no regular files at all
+	empty := t.TempDir()
+	if err := os.Mkdir(path.Join(empty, "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("dir", path.Join(empty, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dir := range []string{src, empty} {
+		store, storage := createSource(t, dir)
+		defer store.Close()
+
+		var archive bytes.Buffer
+		if ok := assert.NoError(t, ExtractTar(store, storage, &archive, 2)); !ok {
+			continue
+		}
+
+		var names []string
+		reader := tar.NewReader(&archive)
+		for {
+			hdr, err := reader.Next()
+			if err == io.EOF {
+				break
+			} else if ok := assert.NoError(t, err); !ok {
+				break
+			}
+			names = append(names, hdr.Name)
+		}
+
+		if dir == src {
+			assert.Equal(t, []string{"a", "z"}, names)
+		} else {
+			assert.Equal(t, []string{"dir/", "link"}, names)
+		}
+	}
+}
+
+// escapeWalker adds an entry outside of the flist root to the walk
+type escapeWalker struct {
+	meta.Walker
+	name string
+}
+
+func (w escapeWalker) Walk(p string, fn meta.WalkFn) error {
+	if err := w.Walker.Walk(p, fn); err != nil {
+		return err
+	}
+
+	m, _ := w.Get("small")
+	return fn(w.name, m)
+}
+
+func TestExtractEscape(t *testing.T) {
+	_, _, store, storage := extractSource(t)
+	defer store.Close()
+
+	root := t.TempDir()
+	dest := path.Join(root, "root")
+	for _, name := range []string{"../escape", "/escape", "bin/../../escape"} {
+		err := Extract(escapeWalker{Walker: store, name: name}, storage, dest, 2)
+		assert.Error(t, err, name)
+
+		_, err = os.Lstat(path.Join(root, "escape"))
+		assert.True(t, os.IsNotExist(err), name)
+	}
+
+	// a symlink to a directory outside of dest is never written through
+	outside := t.TempDir()
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, path.Join(dest, "out")); err != nil {
+		t.Fatal(err)
+	}
+
+	err := Extract(escapeWalker{Walker: store, name: "out/escape"}, storage, dest, 2)
+	assert.Error(t, err)
+
+	entries, _ := os.ReadDir(outside)
+	assert.Empty(t, entries)
+}
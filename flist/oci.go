@@ -0,0 +1,310 @@
+package flist
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/threefoldtech/0-fs/storage"
+	"github.com/threefoldtech/0-fs/storage/router"
+)
+
+// OCI media types and annotations used to read an image layout
+const (
+	MediaTypeOCIIndex       = "application/vnd.oci.image.index.v1+json"
+	MediaTypeOCIManifest    = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeDockerList     = "application/vnd.docker.distribution.manifest.list.v2+json"
+	MediaTypeDockerManifest = "application/vnd.docker.distribution.manifest.v2+json"
+
+	AnnotationRefName = "org.opencontainers.image.ref.name"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// Descriptor describes an OCI blob
+type Descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type ociIndex struct {
+	MediaType string       `json:"mediaType"`
+	Manifests []Descriptor `json:"manifests"`
+}
+
+type ociManifest struct {
+	MediaType string       `json:"mediaType"`
+	Layers    []Descriptor `json:"layers"`
+}
+
+// OCIImage is a container image read from an OCI image layout directory
+type OCIImage struct {
+	root string
+	// Layers of the image, from the bottom to the top layer
+	Layers []Descriptor
+}
+
+func (i *OCIImage) blob(digest string) (string, error) {
+	parts := strings.SplitN(digest, ":", 2)
+	if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 || strings.ContainsAny(digest, "/\\") {
+		return "", fmt.Errorf("invalid digest '%s'", digest)
+	}
+
+	return filepath.Join(i.root, "blobs", parts[0], parts[1]), nil
+}
+
+func (i *OCIImage) readJSON(digest string, v interface{}) error {
+	name, err := i.blob(digest)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+// manifest finds the image manifest in the index. If ref is not empty, only the
+// manifest with this ref name is used, otherwise the index must have one manifest
+func (i *OCIImage) manifest(index *ociIndex, ref string) (Descriptor, error) {
+	var found []Descriptor
+	for _, desc := range index.Manifests {
+		if len(ref) == 0 || desc.Annotations[AnnotationRefName] == ref {
+			found = append(found, desc)
+		}
+	}
+
+	if len(found) == 0 {
+		return Descriptor{}, fmt.Errorf("image '%s' not found", ref)
+	} else if len(found) > 1 && len(ref) == 0 {
+		return Descriptor{}, fmt.Errorf("image layout has %d images, a ref name is required", len(found))
+	}
+
+	return found[0], nil
+}
+
+// OpenOCI reads the image with the given ref name from the OCI image layout
+// directory root. The ref can be empty if the layout has a single image. If
+// the image is multi-platform, the first platform is used
+func OpenOCI(root, ref string) (*OCIImage, error) {
+	image := &OCIImage{root: root}
+
+	data, err := os.ReadFile(filepath.Join(root, "index.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var index ociIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("invalid image index: %s", err)
+	}
+
+	desc, err := image.manifest(&index, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	// follow nested indexes (multi-platform images)
+	for desc.MediaType == MediaTypeOCIIndex || desc.MediaType == MediaTypeDockerList {
+		var nested ociIndex
+		if err := image.readJSON(desc.Digest, &nested); err != nil {
+			return nil, err
+		}
+
+		if len(nested.Manifests) == 0 {
+			return nil, fmt.Errorf("image index '%s' is empty", desc.Digest)
+		}
+
+		desc = nested.Manifests[0]
+	}
+
+	if desc.MediaType != MediaTypeOCIManifest && desc.MediaType != MediaTypeDockerManifest {
+		return nil, fmt.Errorf("unsupported manifest media type '%s'", desc.MediaType)
+	}
+
+	var manifest ociManifest
+	if err := image.readJSON(desc.Digest, &manifest); err != nil {
+		return nil, err
+	}
+
+	image.Layers = manifest.Layers
+	return image, nil
+}
+
+type layerReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (r *layerReader) Close() error {
+	var err error
+	for i := len(r.closers) - 1; i >= 0; i-- {
+		if cerr := r.closers[i].Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+
+	return err
+}
+
+// Open opens the layer tar archive, the layer is decompressed if needed
+func (i *OCIImage) Open(layer Descriptor) (io.ReadCloser, error) {
+	name, err := i.blob(layer.Digest)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	buffered := bufio.NewReader(file)
+	magic, _ := buffered.Peek(4)
+
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		zr, err := gzip.NewReader(buffered)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+
+		return &layerReader{Reader: zr, closers: []io.Closer{file, zr}}, nil
+	case bytes.HasPrefix(magic, zstdMagic):
+		file.Close()
+		return nil, fmt.Errorf("layer '%s' is zstd compressed which is not supported", layer.Digest)
+	default:
+		return &layerReader{Reader: buffered, closers: []io.Closer{file}}, nil
+	}
+}
+
+// layerName returns the flist name of a layer, names sort in layers order
+func layerName(index int, layer Descriptor) string {
+	digest := layer.Digest
+	if i := strings.Index(digest, ":"); i >= 0 {
+		digest = digest[i+1:]
+	}
+
+	if len(digest) > 12 {
+		digest = digest[:12]
+	}
+
+	return fmt.Sprintf("%02d-%s.flist", index, digest)
+}
+
+func createFile(name string, fn func(w io.Writer) error) error {
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(file); err != nil {
+		file.Close()
+		os.Remove(name)
+		return err
+	}
+
+	return file.Close()
+}
+
+// ImportOCI converts the image into flists written to the out directory, file blocks
+// are uploaded to storage, and config is used as the flists routing table. By default
+// the layers are flattened into a single `image.flist`, if perLayer is set one flist
+// is created per layer (with its whiteouts) to be stacked with meta.Layered in the
+// same order. It returns the paths of the created flists
+func ImportOCI(image *OCIImage, out string, storage storage.Setter, config *router.Config, perLayer bool) ([]string, error) {
+	if err := os.MkdirAll(out, 0755); err != nil {
+		return nil, err
+	}
+
+	if !perLayer {
+		name := filepath.Join(out, "image.flist")
+		err := createFile(name, func(w io.Writer) error {
+			builder, err := NewBuilder(storage)
+			if err != nil {
+				return err
+			}
+
+			defer builder.Close()
+
+			for _, layer := range image.Layers {
+				log.Infof("applying layer %s", layer.Digest)
+				if err := image.apply(layer, builder.ApplyLayer); err != nil {
+					return err
+				}
+			}
+
+			return builder.Pack(w, config)
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		return []string{name}, nil
+	}
+
+	var flists []string
+	// regular files of all the layers, for hard links to files of lower layers
+	files := make(map[string]tarEntry)
+	for i, layer := range image.Layers {
+		log.Infof("importing layer %s", layer.Digest)
+		name := filepath.Join(out, layerName(i, layer))
+		err := createFile(name, func(w io.Writer) error {
+			builder, err := NewBuilder(storage)
+			if err != nil {
+				return err
+			}
+
+			defer builder.Close()
+
+			err = image.apply(layer, func(r io.Reader) error {
+				return builder.addTar(r, files)
+			})
+
+			if err != nil {
+				return err
+			}
+
+			return builder.Pack(w, config)
+		})
+
+		if err != nil {
+			return flists, err
+		}
+
+		flists = append(flists, name)
+	}
+
+	return flists, nil
+}
+
+func (i *OCIImage) apply(layer Descriptor, fn func(io.Reader) error) error {
+	reader, err := i.Open(layer)
+	if err != nil {
+		return err
+	}
+
+	defer reader.Close()
+
+	if err := fn(reader); err != nil {
+		return fmt.Errorf("failed to import layer '%s': %s", layer.Digest, err)
+	}
+
+	return nil
+}
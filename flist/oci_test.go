@@ -0,0 +1,241 @@
+package flist
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/threefoldtech/0-fs/internal/testutil"
+	"github.com/threefoldtech/0-fs/meta"
+	"github.com/threefoldtech/0-fs/storage/router"
+)
+
+type tarFile struct {
+	name string
+	typ  byte
+	data string
+	link string
+}
+
+func writeBlob(t *testing.T, root string, data []byte) string {
+	digest := fmt.Sprintf("%x", sha256.Sum256(data))
+	dir := path.Join(root, "blobs", "sha256")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path.Join(dir, digest), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return "sha256:" + digest
+}
+
+func makeLayer(t *testing.T, compress bool, files ...tarFile) []byte {
+	var buf bytes.Buffer
+	var gz *gzip.Writer
+	tw := tar.NewWriter(&buf)
+	if compress {
+		gz = gzip.NewWriter(&buf)
+		tw = tar.NewWriter(gz)
+	}
+
+	for _, f := range files {
+		hdr := &tar.Header{
+			Name:     f.name,
+			Typeflag: f.typ,
+			Linkname: f.link,
+			Mode:     0644,
+			Size:     int64(len(f.data)),
+		}
+
+		if f.typ == tar.TypeDir {
+			hdr.Mode = 0755
+		}
+
+		if f.typ != tar.TypeReg {
+			hdr.Size = 0
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := tw.Write([]byte(f.data)); err != nil && hdr.Size > 0 {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return buf.Bytes()
+}
+
+func makeOCI(t *testing.T, layers ...[]byte) string {
+	root := t.TempDir()
+
+	manifest := ociManifest{MediaType: MediaTypeOCIManifest}
+	for _, layer := range layers {
+		manifest.Layers = append(manifest.Layers, Descriptor{
+			MediaType: "application/vnd.oci.image.layer.v1.tar+gzip",
+			Digest:    writeBlob(t, root, layer),
+			Size:      int64(len(layer)),
+		})
+	}
+
+	data, _ := json.Marshal(manifest)
+	index := ociIndex{
+		Manifests: []Descriptor{{
+			MediaType:   MediaTypeOCIManifest,
+			Digest:      writeBlob(t, root, data),
+			Size:        int64(len(data)),
+			Annotations: map[string]string{AnnotationRefName: "latest"},
+		}},
+	}
+
+	data, _ = json.Marshal(index)
+	if err := os.WriteFile(path.Join(root, "index.json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return root
+}
+
+func openFlist(t *testing.T, name string) meta.Store {
+	file, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	dest := t.TempDir()
+	if err := meta.Unpack(file, dest); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := meta.NewStore(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return store
+}
+
+// walk lists all the entries of the store
+func walk(store meta.Store) []string {
+	var entries []string
+	var visit func(p string)
+	visit = func(p string) {
+		m, ok := store.Get(p)
+		if !ok || !m.IsDir() {
+			return
+		}
+
+		for _, child := range m.Children() {
+			name := path.Join(p, child.Name())
+			entries = append(entries, name)
+			visit(name)
+		}
+	}
+
+	visit("")
+	sort.Strings(entries)
+	return entries
+}
+
+func TestImportOCI(t *testing.T) {
+	root := makeOCI(t,
+		makeLayer(t, true,
+			tarFile{name: "bin/", typ: tar.TypeDir},
+			tarFile{name: "bin/sh", typ: tar.TypeReg, data: "shell"},
+			tarFile{name: "bin/ls", typ: tar.TypeReg, data: "list"},
+			tarFile{name: "etc/", typ: tar.TypeDir},
+			tarFile{name: "etc/passwd", typ: tar.TypeReg, data: "root"},
+			tarFile{name: "opt/app/lib/a.so", typ: tar.TypeReg, data: "lib"},
+			tarFile{name: "opt/app/config", typ: tar.TypeReg, data: "config"},
+		),
+		makeLayer(t, false,
+			tarFile{name: "bin/.wh.ls", typ: tar.TypeReg},
+			tarFile{name: "bin/sh2", typ: tar.TypeLink, link: "bin/sh"},
+			tarFile{name: "opt/app/.wh..wh..opq", typ: tar.TypeReg},
+			tarFile{name: "opt/app/new", typ: tar.TypeReg, data: "new"},
+			tarFile{name: "etc/passwd", typ: tar.TypeSymlink, link: "shadow"},
+		),
+	)
+
+	expected := []string{
+		"bin", "bin/sh", "bin/sh2",
+		"etc", "etc/passwd",
+		"opt", "opt/app", "opt/app/new",
+	}
+
+	image, err := OpenOCI(root, "latest")
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	assert.Len(t, image.Layers, 2)
+
+	config := &router.Config{
+		Pools:  map[string]router.PoolConfig{"local": {"00:FF": "zdb://localhost:9900"}},
+		Lookup: []string{"local"},
+	}
+
+	storage := testutil.Storage{}
+	flat, err := ImportOCI(image, t.TempDir(), storage, config, false)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	assert.Len(t, flat, 1)
+
+	store := openFlist(t, flat[0])
+	defer store.Close()
+	assert.Equal(t, expected, walk(store))
+
+	sh2, _ := store.Get("bin/sh2")
+	sh, _ := store.Get("bin/sh")
+	assert.Equal(t, sh.Blocks(), sh2.Blocks())
+
+	passwd, _ := store.Get("etc/passwd")
+	assert.Equal(t, meta.LinkType, passwd.Info().Type)
+
+	layers, err := ImportOCI(image, t.TempDir(), storage, config, true)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	assert.Len(t, layers, 2)
+
+	var stores []meta.Store
+	for _, name := range layers {
+		stores = append(stores, openFlist(t, name))
+	}
+
+	layered := meta.Layered(stores...)
+	defer layered.Close()
+	assert.Equal(t, expected, walk(layered))
+}
+
+func TestOpenOCIRef(t *testing.T) {
+	root := makeOCI(t, makeLayer(t, true, tarFile{name: "a", typ: tar.TypeReg, data: "a"}))
+
+	_, err := OpenOCI(root, "")
+	assert.NoError(t, err)
+
+	_, err = OpenOCI(root, "missing")
+	assert.Error(t, err)
+}
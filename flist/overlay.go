@@ -0,0 +1,125 @@
+package flist
+
+import (
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/threefoldtech/0-fs/meta"
+	"github.com/threefoldtech/0-fs/storage"
+	"github.com/threefoldtech/0-fs/storage/router"
+)
+
+const (
+	// overlayXAttrPrefix is the prefix of the extended attributes used by
+	// overlayfs to keep its state in the upper directory
+	overlayXAttrPrefix = "trusted.overlay."
+	// overlayOpaque marks a directory of the upper directory as opaque
+	overlayOpaque = overlayXAttrPrefix + "opaque"
+)
+
+// overlayWhiteout checks if info is an overlayfs whiteout, a 0/0 char device
+func overlayWhiteout(info meta.Info) bool {
+	return info.Type == meta.CharDeviceType && info.SpecialData == "0,0"
+}
+
+// overlayAttrs removes the overlayfs attributes from info, it returns true if
+// the directory is opaque
+func overlayAttrs(p string, info *meta.Info) bool {
+	opaque := false
+	for key, value := range info.XAttrs {
+		if !strings.HasPrefix(key, overlayXAttrPrefix) {
+			continue
+		}
+
+		switch key {
+		case overlayOpaque:
+			opaque = string(value) == "y"
+		case overlayXAttrPrefix + "redirect", overlayXAttrPrefix + "metacopy":
+			log.Warningf("'%s' has overlay attribute '%s', its content in the lower layer is not part of the commit", p, key)
+		}
+
+		delete(info.XAttrs, key)
+	}
+
+	if len(info.XAttrs) == 0 {
+		info.XAttrs = nil
+	}
+
+	return opaque
+}
+
+// marker is the info of an empty whiteout entry
+func marker(info meta.Info) meta.Info {
+	return meta.Info{
+		CreationTime:     info.CreationTime,
+		ModificationTime: info.ModificationTime,
+		Access: meta.Access{
+			UID:  info.Access.UID,
+			GID:  info.Access.GID,
+			Mode: 0644,
+		},
+		Type: meta.RegularType,
+	}
+}
+
+// AddOverlay walks the overlayfs upper directory upper and adds its content to
+// the flist as a layer. The overlayfs whiteouts (0/0 char devices) are added as
+// `.wh.<name>` entries and the opaque directories get a `.wh..wh..opq` entry, so
+// the flist deletes the same entries when layered on top of the lower flist with
+// meta.Layered
+func (b *Builder) AddOverlay(upper string) error {
+	return filepath.Walk(upper, func(name string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(upper, name)
+		if err != nil {
+			return err
+		}
+
+		info, err := Stat(name, fi)
+		if err != nil {
+			return err
+		}
+
+		if overlayWhiteout(info) {
+			dir, base := path.Split(rel)
+			log.Debugf("adding whiteout of '%s'", rel)
+			return b.writer.Add(path.Join(dir, meta.WhiteoutPrefix+base), marker(info), nil)
+		}
+
+		opaque := overlayAttrs(rel, &info)
+		if err := b.addFile(name, rel, info); err != nil {
+			return err
+		}
+
+		if opaque {
+			log.Debugf("adding opaque marker of '%s'", rel)
+			return b.writer.Add(path.Join(rel, meta.WhiteoutOpaque), marker(info), nil)
+		}
+
+		return nil
+	})
+}
+
+// CommitOverlay creates an flist layer from the overlayfs upper directory upper
+// (see Builder.AddOverlay) and writes it to w. File blocks are uploaded to
+// storage, and config is used as the flist routing table
+func CommitOverlay(upper string, w io.Writer, storage storage.Setter, config *router.Config) error {
+	builder, err := NewBuilder(storage)
+	if err != nil {
+		return err
+	}
+
+	defer builder.Close()
+
+	if err := builder.AddOverlay(upper); err != nil {
+		return err
+	}
+
+	return builder.Pack(w, config)
+}
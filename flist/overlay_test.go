@@ -0,0 +1,116 @@
+package flist
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/threefoldtech/0-fs/internal/testutil"
+	"github.com/threefoldtech/0-fs/meta"
+	"golang.org/x/sys/unix"
+)
+
+func testStore(t *testing.T, create func(w *bytes.Buffer) error) meta.Store {
+	var archive bytes.Buffer
+	if ok := assert.NoError(t, create(&archive)); !ok {
+		t.Fatal()
+	}
+
+	dest := t.TempDir()
+	if ok := assert.NoError(t, meta.Unpack(&archive, dest)); !ok {
+		t.Fatal()
+	}
+
+	store, err := meta.NewStore(dest)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestCommitOverlay(t *testing.T) {
+	lower, upper := t.TempDir(), t.TempDir()
+	for _, dir := range []string{"etc", "opt/app", "var/lib"} {
+		if err := os.MkdirAll(path.Join(lower, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.MkdirAll(path.Join(upper, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testutil.MakeFile(t, path.Join(lower, "etc/hosts"), 10)
+	testutil.MakeFile(t, path.Join(lower, "etc/passwd"), 10)
+	testutil.MakeFile(t, path.Join(lower, "opt/app/old"), 10)
+	testutil.MakeFile(t, path.Join(lower, "var/lib/data"), 10)
+
+	// etc/passwd is deleted, etc/new is added
+	if err := unix.Mknod(path.Join(upper, "etc/passwd"), unix.S_IFCHR|0600, 0); err != nil {
+		t.Skipf("can't create whiteout: %s", err)
+	}
+	hosts := testutil.MakeFile(t, path.Join(upper, "etc/hosts"), 20)
+	testutil.MakeFile(t, path.Join(upper, "etc/new"), 10)
+
+	// var/lib/data is replaced, and a device that is not a whiteout is kept
+	testutil.MakeFile(t, path.Join(upper, "var/lib/data"), 30)
+	if err := unix.Mknod(path.Join(upper, "var/lib/null"), unix.S_IFCHR|0666, int(unix.Mkdev(1, 3))); err != nil {
+		t.Fatal(err)
+	}
+
+	// opt/app is opaque, not all filesystems support trusted attributes
+	opaque := unix.Lsetxattr(path.Join(upper, "opt/app"), overlayOpaque, []byte("y"), 0) == nil
+	testutil.MakeFile(t, path.Join(upper, "opt/app/new"), 10)
+
+	storage := testutil.Storage{}
+	base := testStore(t, func(w *bytes.Buffer) error {
+		return Create(lower, w, storage, nil)
+	})
+	layer := testStore(t, func(w *bytes.Buffer) error {
+		return CommitOverlay(upper, w, storage, nil)
+	})
+
+	whiteout, ok := layer.Get("etc/.wh.passwd")
+	if ok := assert.True(t, ok); ok {
+		assert.Equal(t, meta.RegularType, whiteout.Info().Type)
+	}
+
+	store := meta.Layered(base, layer)
+	for _, p := range []string{"etc/hosts", "etc/new", "opt/app/new", "var/lib/data", "var/lib/null"} {
+		_, ok := store.Get(p)
+		assert.True(t, ok, p)
+	}
+
+	_, ok = store.Get("etc/passwd")
+	assert.False(t, ok)
+
+	m, _ := store.Get("etc/hosts")
+	assert.Equal(t, uint64(len(hosts)), m.Info().Size)
+
+	m, _ = store.Get("var/lib/null")
+	assert.Equal(t, meta.CharDeviceType, m.Info().Type)
+	assert.Equal(t, "1,3", m.Info().SpecialData)
+
+	if !opaque {
+		t.Log("trusted extended attributes not supported, skipping opaque directories")
+		return
+	}
+
+	_, ok = store.Get("opt/app/old")
+	assert.False(t, ok)
+
+	m, ok = layer.Get("opt/app")
+	if ok := assert.True(t, ok); ok {
+		assert.Empty(t, m.Info().XAttrs)
+	}
+
+	dir, _ := store.Get("opt/app")
+	var names []string
+	for _, child := range dir.Children() {
+		names = append(names, child.Name())
+	}
+	assert.Equal(t, []string{"new"}, names)
+}
@@ -0,0 +1,207 @@
+package flist
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/threefoldtech/0-fs/meta"
+)
+
+const (
+	xattrPAXPrefix = "SCHILY.xattr."
+)
+
+type tarEntry struct {
+	path   string
+	info   meta.Info
+	blocks []meta.BlockInfo
+}
+
+// tarLayer is the content of a layer tar
+type tarLayer struct {
+	entries []tarEntry
+	// whiteouts are the entries deleted from the lower layers
+	whiteouts []string
+	// opaques are the directories that hide the lower layers content
+	opaques []string
+}
+
+func cleanPath(name string) string {
+	return strings.Trim(path.Clean("/"+name), "/")
+}
+
+// tarInfo converts a tar header into flist meta info
+func tarInfo(hdr *tar.Header) (meta.Info, bool) {
+	info := meta.Info{
+		ModificationTime: uint32(hdr.ModTime.Unix()),
+		CreationTime:     uint32(hdr.ModTime.Unix()),
+		Access: meta.Access{
+			UID:  uint32(hdr.Uid),
+			GID:  uint32(hdr.Gid),
+			Mode: uint32(hdr.Mode) & 07777,
+		},
+	}
+
+	if !hdr.ChangeTime.IsZero() {
+		info.CreationTime = uint32(hdr.ChangeTime.Unix())
+	}
+
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		info.Type = meta.DirType
+	case tar.TypeReg:
+		info.Type = meta.RegularType
+		info.Size = uint64(hdr.Size)
+	case tar.TypeSymlink:
+		info.Type = meta.LinkType
+		info.LinkTarget = hdr.Linkname
+	case tar.TypeChar:
+		info.Type = meta.CharDeviceType
+		info.SpecialData = fmt.Sprintf("%d,%d", hdr.Devmajor, hdr.Devminor)
+	case tar.TypeBlock:
+		info.Type = meta.BlockDeviceType
+		info.SpecialData = fmt.Sprintf("%d,%d", hdr.Devmajor, hdr.Devminor)
+	case tar.TypeFifo:
+		info.Type = meta.FIFOType
+	default:
+		return info, false
+	}
+
+	for key, value := range hdr.PAXRecords {
+		if !strings.HasPrefix(key, xattrPAXPrefix) {
+			continue
+		}
+
+		if info.XAttrs == nil {
+			info.XAttrs = make(map[string][]byte)
+		}
+		info.XAttrs[strings.TrimPrefix(key, xattrPAXPrefix)] = []byte(value)
+	}
+
+	return info, true
+}
+
+// readTar reads all the entries of a layer tar and uploads the files content.
+// If keepWhiteouts is set, whiteouts are kept as entries, otherwise they are
+// collected in the layer whiteouts and opaques. files is used to resolve hard
+// links to files of the lower layers, it's updated with the layer regular files.
+func (b *Builder) readTar(r io.Reader, keepWhiteouts bool, files map[string]tarEntry) (*tarLayer, error) {
+	var layer tarLayer
+
+	reader := tar.NewReader(r)
+	for {
+		hdr, err := reader.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		p := cleanPath(hdr.Name)
+		name := path.Base(p)
+		if !keepWhiteouts && strings.HasPrefix(name, meta.WhiteoutPrefix) {
+			if name == meta.WhiteoutOpaque {
+				layer.opaques = append(layer.opaques, cleanPath(path.Dir(p)))
+			} else {
+				layer.whiteouts = append(layer.whiteouts, cleanPath(path.Join(path.Dir(p), strings.TrimPrefix(name, meta.WhiteoutPrefix))))
+			}
+			continue
+		}
+
+		if hdr.Typeflag == tar.TypeLink {
+			// hard links are added as a copy of the target file
+			target := cleanPath(hdr.Linkname)
+			var entry tarEntry
+			if found, ok := files[target]; ok {
+				entry = found
+			} else if info, blocks, ok := b.writer.Get(target); ok && info.Type == meta.RegularType {
+				entry = tarEntry{info: info, blocks: blocks}
+			} else {
+				log.Warningf("hard link '%s' target '%s' not found, skipping", p, target)
+				continue
+			}
+
+			entry.path = p
+			files[p] = entry
+			layer.entries = append(layer.entries, entry)
+			continue
+		}
+
+		info, ok := tarInfo(hdr)
+		if !ok {
+			log.Warningf("unsupported tar entry '%s' (type %c), skipping", p, hdr.Typeflag)
+			continue
+		}
+
+		entry := tarEntry{path: p, info: info}
+		if info.Type == meta.RegularType {
+			if entry.blocks, err = b.uploader.Upload(reader); err != nil {
+				return nil, err
+			}
+
+			entry.info.FileBlockSize = b.uploader.BlockSize()
+			files[p] = entry
+		}
+
+		layer.entries = append(layer.entries, entry)
+	}
+
+	return &layer, nil
+}
+
+// add adds the layer entries to the flist, an entry replaces the existing
+// one at the same path even if it's not of the same kind
+func (b *Builder) add(layer *tarLayer) error {
+	for _, entry := range layer.entries {
+		if info, _, ok := b.writer.Get(entry.path); ok {
+			if (info.Type == meta.DirType) != (entry.info.Type == meta.DirType) {
+				b.writer.Remove(entry.path)
+			}
+		}
+
+		if err := b.writer.Add(entry.path, entry.info, entry.blocks); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AddTar adds all the entries of the tar archive r to the flist as is. Whiteouts
+// are kept as entries, so the flist can be layered on top of other flists
+// (see meta.Layered) and delete entries from them
+func (b *Builder) AddTar(r io.Reader) error {
+	return b.addTar(r, make(map[string]tarEntry))
+}
+
+func (b *Builder) addTar(r io.Reader, files map[string]tarEntry) error {
+	layer, err := b.readTar(r, true, files)
+	if err != nil {
+		return err
+	}
+
+	return b.add(layer)
+}
+
+// ApplyLayer applies the layer tar archive r on top of the flist content. The
+// entries whited out by the layer are removed from the flist before the layer
+// entries are added. This is how a container image is flattened into one flist
+func (b *Builder) ApplyLayer(r io.Reader) error {
+	layer, err := b.readTar(r, false, make(map[string]tarEntry))
+	if err != nil {
+		return err
+	}
+
+	for _, p := range layer.whiteouts {
+		b.writer.Remove(p)
+	}
+
+	for _, p := range layer.opaques {
+		b.writer.Clear(p)
+	}
+
+	return b.add(layer)
+}
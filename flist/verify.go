@@ -0,0 +1,284 @@
+package flist
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/threefoldtech/0-fs/meta"
+	"github.com/threefoldtech/0-fs/rofs"
+	"github.com/threefoldtech/0-fs/storage"
+)
+
+const (
+	// DefaultVerifyWorkers is the default number of blocks checked in parallel
+	DefaultVerifyWorkers = 8
+
+	maxLinkHops = 40
+)
+
+var (
+	errBlockNotFound = errors.New("block not found")
+)
+
+// Problem is an integrity problem found in an flist
+type Problem struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// Report is the result of an flist verification. Warnings are issues that are
+// expected in some flists, like symlinks to paths that only exist at runtime
+type Report struct {
+	Entries  int       `json:"entries"`
+	Files    int       `json:"files"`
+	Links    int       `json:"links"`
+	Blocks   int       `json:"blocks"`
+	Problems []Problem `json:"problems"`
+	Warnings []Problem `json:"warnings"`
+
+	m sync.Mutex
+}
+
+func (r *Report) problem(p string, format string, args ...interface{}) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	log.Debugf("problem in '%s': %s", p, fmt.Sprintf(format, args...))
+	r.Problems = append(r.Problems, Problem{Path: p, Error: fmt.Sprintf(format, args...)})
+}
+
+func (r *Report) warning(p string, format string, args ...interface{}) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.Warnings = append(r.Warnings, Problem{Path: p, Error: fmt.Sprintf(format, args...)})
+}
+
+// OK returns true if no problems were found
+func (r *Report) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// exister is a storage that can check if a block exists without downloading it,
+// like the router
+type exister interface {
+	Exists(key []byte) (bool, error)
+}
+
+type blockJob struct {
+	path  string
+	index int
+	meta  meta.Meta
+}
+
+// blockCheck checks blocks, each block is only checked once
+type blockCheck struct {
+	storage  storage.Storage
+	download bool
+
+	checked map[string]error
+	m       sync.Mutex
+}
+
+func (c *blockCheck) check(job blockJob) error {
+	block := job.meta.Blocks()[job.index]
+
+	c.m.Lock()
+	err, ok := c.checked[string(block.Key)]
+	c.m.Unlock()
+	if ok {
+		return err
+	}
+
+	if c.download {
+		// downloads, decrypts and validates the block hash
+		_, err = rofs.NewDownloader(c.storage, job.meta).DownloadBlock(job.index)
+	} else if store, ok := c.storage.(exister); ok {
+		var found bool
+		if found, err = store.Exists(block.Key); err == nil && !found {
+			err = errBlockNotFound
+		}
+	} else {
+		var body io.ReadCloser
+		if body, err = c.storage.Get(block.Key); err == nil {
+			body.Close()
+		}
+	}
+
+	c.m.Lock()
+	c.checked[string(block.Key)] = err
+	c.m.Unlock()
+
+	return err
+}
+
+// Verify checks the integrity of the flist store. It reports the entries that can't be
+// read or that have no access information (if the store is a meta.Checker), the files
+// with inconsistent blocks, the relative symlinks that don't resolve inside the flist,
+// and the blocks that are not reachable in storage. Absolute symlinks that don't
+// resolve inside the flist are reported as warnings. If download is set, blocks are also
+// downloaded and their hash is validated. Up to workers blocks are checked in parallel
+func Verify(store meta.Walker, storage storage.Storage, download bool, workers int) (*Report, error) {
+	if workers <= 0 {
+		workers = DefaultVerifyWorkers
+	}
+
+	report := &Report{}
+	checker := &blockCheck{storage: storage, download: download, checked: make(map[string]error)}
+
+	jobs := make(chan blockJob)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				if err := checker.check(job); err != nil {
+					report.problem(job.path, "block %d (%x): %s", job.index, job.meta.Blocks()[job.index].Key, err)
+				}
+			}
+		}()
+	}
+
+	visit := func(p string, m meta.Meta, err error) error {
+		report.Entries++
+		if err != nil {
+			report.problem(p, "%s", err)
+		}
+
+		if m == nil {
+			return nil
+		}
+
+		info := m.Info()
+		switch info.Type {
+		case meta.RegularType:
+			report.Files++
+			verifyFile(report, p, m)
+			for i := range m.Blocks() {
+				report.Blocks++
+				jobs <- blockJob{path: p, index: i, meta: m}
+			}
+		case meta.LinkType:
+			report.Links++
+			if _, ok := resolve(store, path.Dir(p), info.LinkTarget, new(int)); ok {
+				break
+			}
+
+			// absolute links usually point to runtime filesystems (/proc, /run, ...)
+			if path.IsAbs(info.LinkTarget) {
+				report.warning(p, "symlink to '%s' outside of the flist", info.LinkTarget)
+			} else {
+				report.problem(p, "broken symlink to '%s'", info.LinkTarget)
+			}
+		case meta.UnknownType:
+			report.problem(p, "unknown entry type")
+		}
+
+		return nil
+	}
+
+	var err error
+	if checker, ok := store.(meta.Checker); ok {
+		err = checker.Check(visit)
+	} else {
+		err = store.Walk("", func(p string, m meta.Meta) error {
+			return visit(p, m, nil)
+		})
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	if err == meta.ErrNotFound {
+		report.problem("", "root directory not found")
+		err = nil
+	}
+
+	// blocks are checked in parallel
+	sort.SliceStable(report.Problems, func(i, j int) bool {
+		return report.Problems[i].Path < report.Problems[j].Path
+	})
+	sort.SliceStable(report.Warnings, func(i, j int) bool {
+		return report.Warnings[i].Path < report.Warnings[j].Path
+	})
+
+	return report, err
+}
+
+// verifyFile checks that the file blocks are consistent with its size
+func verifyFile(report *Report, p string, m meta.Meta) {
+	info := m.Info()
+	blocks := uint64(len(m.Blocks()))
+
+	switch {
+	case blocks == 0 && info.Size != 0:
+		report.problem(p, "file of size %d has no blocks", info.Size)
+	case blocks != 0 && info.FileBlockSize == 0:
+		report.problem(p, "file has blocks but no block size")
+	case blocks*info.FileBlockSize < info.Size:
+		report.problem(p, "%d blocks of size %d can't hold %d bytes", blocks, info.FileBlockSize, info.Size)
+	case blocks != 0 && (blocks-1)*info.FileBlockSize >= info.Size:
+		report.problem(p, "too many blocks (%d) for %d bytes", blocks, info.Size)
+	}
+}
+
+// resolve resolves the path p relative to the (already resolved) directory
+// cur inside the flist, following symlinks
+func resolve(store meta.Store, cur string, p string, hops *int) (string, bool) {
+	if path.IsAbs(p) {
+		cur = ""
+	}
+
+	if cur == "." {
+		cur = ""
+	}
+
+	for _, name := range strings.Split(p, "/") {
+		if name == "" {
+			continue
+		}
+
+		// only directories can be traversed
+		if m, ok := store.Get(cur); !ok || !m.IsDir() {
+			return "", false
+		}
+
+		switch name {
+		case ".":
+			continue
+		case "..":
+			if cur = path.Dir(cur); cur == "." {
+				cur = ""
+			}
+			continue
+		}
+
+		next := path.Join(cur, name)
+		m, ok := store.Get(next)
+		if !ok {
+			return "", false
+		}
+
+		info := m.Info()
+		if info.Type != meta.LinkType {
+			cur = next
+			continue
+		}
+
+		if *hops++; *hops > maxLinkHops {
+			return "", false
+		}
+
+		if cur, ok = resolve(store, cur, info.LinkTarget, hops); !ok {
+			return "", false
+		}
+	}
+
+	return cur, true
+}
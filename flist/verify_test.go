@@ -0,0 +1,115 @@
+package flist
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/threefoldtech/0-fs/internal/testutil"
+	"github.com/threefoldtech/0-fs/meta"
+)
+
+func TestVerify(t *testing.T) {
+	src, files, store, storage := extractSource(t)
+	defer store.Close()
+
+	report, err := Verify(store, storage, true, 2)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	assert.True(t, report.OK(), report.Problems)
+	assert.Equal(t, len(files), report.Files)
+	assert.Equal(t, 1, report.Links)
+	assert.Equal(t, 4, report.Blocks)
+
+	// a broken symlink
+	if err := os.Symlink("../nope", path.Join(src, "bin/dangling")); err != nil {
+		t.Fatal(err)
+	}
+	// a symlink through another symlink
+	if err := os.Symlink("bin/sub", path.Join(src, "sub")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("../sub/../../small", path.Join(src, "bin/through")); err != nil {
+		t.Fatal(err)
+	}
+	// a symlink through a file
+	if err := os.Symlink("link/../small", path.Join(src, "file")); err != nil {
+		t.Fatal(err)
+	}
+	// a symlink to a runtime filesystem
+	if err := os.Symlink("/proc/self/mounts", path.Join(src, "bin/mtab")); err != nil {
+		t.Fatal(err)
+	}
+
+	storage = testutil.Storage{}
+	var archive bytes.Buffer
+	if err := Create(src, &archive, storage, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	db := t.TempDir()
+	if err := meta.Unpack(&archive, db); err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := meta.NewStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer updated.Close()
+
+	small, _ := updated.Get("small")
+	big, _ := updated.Get("bin/big")
+	// missing block
+	delete(storage, string(small.Blocks()[0].Key))
+	// corrupt block
+	corrupt := big.Blocks()[1].Key
+	storage[string(corrupt)] = append([]byte{}, storage[string(corrupt)][:10]...)
+
+	// without download, only reachability is checked, without downloading
+	// the blocks if the storage can check them
+	report, err = Verify(updated.(meta.Walker), existStorage{storage}, false, 2)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	var paths []string
+	for _, problem := range report.Problems {
+		paths = append(paths, problem.Path)
+	}
+	assert.Equal(t, []string{"bin/dangling", "file", "small"}, paths)
+
+	if ok := assert.Len(t, report.Warnings, 1); ok {
+		assert.Equal(t, "bin/mtab", report.Warnings[0].Path)
+	}
+
+	report, err = Verify(updated.(meta.Walker), storage, true, 2)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	paths = nil
+	for _, problem := range report.Problems {
+		paths = append(paths, problem.Path)
+	}
+	assert.Equal(t, []string{"bin/big", "bin/dangling", "file", "small"}, paths)
+}
+
+// existStorage can only check if blocks exist
+type existStorage struct {
+	testutil.Storage
+}
+
+func (s existStorage) Get(key []byte) (io.ReadCloser, error) {
+	return nil, fmt.Errorf("unexpected download of block '%x'", key)
+}
+
+func (s existStorage) Exists(key []byte) (bool, error) {
+	_, ok := s.Storage[string(key)]
+	return ok, nil
+}
@@ -0,0 +1,199 @@
+package flistfs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sync"
+
+	"github.com/threefoldtech/0-fs/meta"
+	"github.com/threefoldtech/0-fs/rofs"
+)
+
+// content is the content of a regular file
+type content interface {
+	io.ReaderAt
+	io.Closer
+}
+
+// blocks reads the content of a file block by block with a downloader. The last
+// downloaded block is kept so sequential reads download each block once
+type blocks struct {
+	meta       meta.Meta
+	downloader *rofs.Downloader
+
+	index int
+	block []byte
+	m     sync.Mutex
+}
+
+func newBlocks(downloader *rofs.Downloader, m meta.Meta) *blocks {
+	return &blocks{meta: m, downloader: downloader, index: -1}
+}
+
+// get returns the decrypted block at index
+func (b *blocks) get(index int) ([]byte, error) {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	if index == b.index {
+		return b.block, nil
+	}
+
+	if index >= len(b.meta.Blocks()) {
+		return nil, fmt.Errorf("block %d is missing", index)
+	}
+
+	block, err := b.downloader.DownloadBlock(index)
+	if err != nil {
+		return nil, err
+	}
+
+	b.index, b.block = index, block
+	return block, nil
+}
+
+// ReadAt implements io.ReaderAt, it's safe to call from multiple routines
+func (b *blocks) ReadAt(p []byte, off int64) (int, error) {
+	size := int64(b.meta.Info().Size)
+	if off >= size {
+		return 0, io.EOF
+	}
+
+	end := off + int64(len(p))
+	if end > size {
+		end = size
+	}
+
+	bs := int64(b.meta.Info().FileBlockSize)
+	if bs == 0 {
+		return 0, errors.New("block size is not set")
+	}
+
+	for pos := off; pos < end; {
+		index := int(pos / bs)
+		block, err := b.get(index)
+		if err != nil {
+			return int(pos - off), err
+		}
+
+		start := pos - int64(index)*bs
+		if start >= int64(len(block)) {
+			return int(pos - off), io.ErrUnexpectedEOF
+		}
+
+		pos += int64(copy(p[pos-off:end-off], block[start:]))
+	}
+
+	n := int(end - off)
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+func (b *blocks) Close() error {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	b.index, b.block = -1, nil
+	return nil
+}
+
+// file is an open file. Special files have no content
+type file struct {
+	*fileInfo
+	path    string
+	content content
+
+	offset int64
+	closed bool
+}
+
+var (
+	_ io.ReaderAt = (*file)(nil)
+	_ io.Seeker   = (*file)(nil)
+)
+
+func (f *file) Stat() (fs.FileInfo, error) {
+	if f.closed {
+		return nil, &fs.PathError{Op: "stat", Path: f.path, Err: fs.ErrClosed}
+	}
+
+	return f.fileInfo, nil
+}
+
+func (f *file) Close() error {
+	if f.closed {
+		return &fs.PathError{Op: "close", Path: f.path, Err: fs.ErrClosed}
+	}
+
+	f.closed = true
+	if f.content == nil {
+		return nil
+	}
+
+	return f.content.Close()
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	if f.closed {
+		return 0, &fs.PathError{Op: "read", Path: f.path, Err: fs.ErrClosed}
+	}
+
+	n, err := f.ReadAt(p, f.offset)
+	f.offset += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+
+	return n, err
+}
+
+// Seek implements io.Seeker
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	if f.closed {
+		return 0, &fs.PathError{Op: "seek", Path: f.path, Err: fs.ErrClosed}
+	}
+
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.Size()
+	default:
+		return 0, &fs.PathError{Op: "seek", Path: f.path, Err: fs.ErrInvalid}
+	}
+
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.path, Err: fs.ErrInvalid}
+	}
+
+	f.offset = offset
+	return offset, nil
+}
+
+// ReadAt implements io.ReaderAt
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	if f.closed {
+		return 0, &fs.PathError{Op: "read", Path: f.path, Err: fs.ErrClosed}
+	}
+
+	if off < 0 {
+		return 0, &fs.PathError{Op: "read", Path: f.path, Err: fs.ErrInvalid}
+	}
+
+	if f.content == nil || off >= f.Size() {
+		return 0, io.EOF
+	}
+
+	n, err := f.content.ReadAt(p, off)
+	if err != nil && err != io.EOF {
+		return n, &fs.PathError{Op: "read", Path: f.path, Err: err}
+	}
+
+	return n, err
+}
@@ -0,0 +1,376 @@
+// Package flistfs exposes the content of an flist as an io/fs.FS, so flists can be
+// read without mounting them and used with the standard library helpers like
+// http.FileServer, template.ParseFS or fs.WalkDir
+package flistfs
+
+import (
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/op/go-logging"
+	"github.com/threefoldtech/0-fs/meta"
+	"github.com/threefoldtech/0-fs/rofs"
+	"github.com/threefoldtech/0-fs/storage"
+)
+
+var (
+	log = logging.MustGetLogger("flistfs")
+)
+
+const (
+	// maxLinks is the max number of symlinks followed to resolve a path
+	maxLinks = 40
+)
+
+// FS is a read only file system over the flist metadata in a meta store, files
+// content is downloaded from the storage when read. Symlinks are followed by
+// Open, Stat and ReadFile, absolute link targets are resolved from the root of
+// the flist and targets can never point outside of the flist
+type FS struct {
+	store   meta.Store
+	storage storage.Storage
+	cache   *rofs.Cache
+}
+
+var (
+	_ fs.ReadDirFS  = (*FS)(nil)
+	_ fs.ReadFileFS = (*FS)(nil)
+	_ fs.StatFS     = (*FS)(nil)
+)
+
+// New creates a file system over the flist in store, files blocks are downloaded
+// from storage
+func New(store meta.Store, storage storage.Storage) *FS {
+	return &FS{store: store, storage: storage}
+}
+
+// NewCached creates a file system over the flist in store, files are read
+// through cache so downloaded blocks are kept and served locally afterwards
+func NewCached(store meta.Store, cache *rofs.Cache) *FS {
+	return &FS{store: store, cache: cache}
+}
+
+// open opens the content of the regular file m
+func (f *FS) open(m meta.Meta) (content, error) {
+	if f.cache != nil {
+		return f.cache.Reader(m)
+	}
+
+	return newBlocks(rofs.NewDownloader(f.storage, m), m), nil
+}
+
+// FileMode converts the entry type and access mode to fs.FileMode
+func FileMode(info meta.Info) fs.FileMode {
+	mode := fs.FileMode(info.Access.Mode & 0777)
+	switch info.Type {
+	case meta.RegularType:
+	case meta.DirType:
+		mode |= fs.ModeDir
+	case meta.LinkType:
+		mode |= fs.ModeSymlink
+	case meta.BlockDeviceType:
+		mode |= fs.ModeDevice
+	case meta.CharDeviceType:
+		mode |= fs.ModeDevice | fs.ModeCharDevice
+	case meta.FIFOType:
+		mode |= fs.ModeNamedPipe
+	case meta.SocketType:
+		mode |= fs.ModeSocket
+	default:
+		mode |= fs.ModeIrregular
+	}
+
+	if info.Access.Mode&04000 != 0 {
+		mode |= fs.ModeSetuid
+	}
+	if info.Access.Mode&02000 != 0 {
+		mode |= fs.ModeSetgid
+	}
+	if info.Access.Mode&01000 != 0 {
+		mode |= fs.ModeSticky
+	}
+
+	return mode
+}
+
+// fileInfo implements fs.FileInfo and fs.DirEntry for an flist entry
+type fileInfo struct {
+	name string
+	meta meta.Meta
+}
+
+func (i *fileInfo) Name() string {
+	return i.name
+}
+
+// Size returns the file size for regular files and the target length for
+// symlinks, it's 0 for all other entries
+func (i *fileInfo) Size() int64 {
+	info := i.meta.Info()
+	switch info.Type {
+	case meta.RegularType:
+		return int64(info.Size)
+	case meta.LinkType:
+		return int64(len(info.LinkTarget))
+	default:
+		return 0
+	}
+}
+
+func (i *fileInfo) Mode() fs.FileMode {
+	return FileMode(i.meta.Info())
+}
+
+func (i *fileInfo) ModTime() time.Time {
+	return time.Unix(int64(i.meta.Info().ModificationTime), 0)
+}
+
+func (i *fileInfo) IsDir() bool {
+	return i.meta.Info().Type == meta.DirType
+}
+
+// Sys returns the meta.Meta of the entry
+func (i *fileInfo) Sys() interface{} {
+	return i.meta
+}
+
+func (i *fileInfo) Type() fs.FileMode {
+	return i.Mode().Type()
+}
+
+func (i *fileInfo) Info() (fs.FileInfo, error) {
+	return i, nil
+}
+
+func (i *fileInfo) String() string {
+	return fs.FormatFileInfo(i)
+}
+
+// lookup gets the entry name. Symlinks in the parent directories are always
+// followed, the entry itself is only followed if follow is set
+func (f *FS) lookup(op, name string, follow bool) (meta.Meta, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	var rest []string
+	if name != "." {
+		rest = strings.Split(name, "/")
+	}
+
+	// dir is the resolved path of the parent of the next element
+	dir, links := "", 0
+	m, ok := f.store.Get(dir)
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+
+	for len(rest) > 0 {
+		p := path.Join(dir, rest[0])
+		rest = rest[1:]
+
+		if m, ok = f.store.Get(p); !ok {
+			return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+
+		info := m.Info()
+		if info.Type != meta.LinkType || (len(rest) == 0 && !follow) {
+			dir = p
+			continue
+		}
+
+		if links++; links > maxLinks {
+			return nil, &fs.PathError{Op: op, Path: name, Err: syscall.ELOOP}
+		}
+
+		// resolve the target from the root, so it can't escape the flist
+		target := info.LinkTarget
+		if !path.IsAbs(target) {
+			target = path.Join(path.Dir(p), target)
+		}
+
+		if target = strings.TrimPrefix(path.Clean("/"+target), "/"); target != "" {
+			rest = append(strings.Split(target, "/"), rest...)
+		}
+
+		dir = ""
+		if m, ok = f.store.Get(dir); !ok {
+			return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+	}
+
+	return m, nil
+}
+
+// Open implements fs.FS
+func (f *FS) Open(name string) (fs.File, error) {
+	m, err := f.lookup("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &fileInfo{name: path.Base(name), meta: m}
+	switch m.Info().Type {
+	case meta.DirType:
+		return &dir{fileInfo: info, path: name}, nil
+	case meta.RegularType:
+		content, err := f.open(m)
+		if err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+
+		return &file{fileInfo: info, path: name, content: content}, nil
+	default:
+		// special files have no content
+		return &file{fileInfo: info, path: name}, nil
+	}
+}
+
+// Stat implements fs.StatFS
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	m, err := f.lookup("stat", name, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileInfo{name: path.Base(name), meta: m}, nil
+}
+
+// Lstat returns the info of the entry name without following it if it's a
+// symlink, together with ReadLink it implements fs.ReadLinkFS of go1.25
+func (f *FS) Lstat(name string) (fs.FileInfo, error) {
+	m, err := f.lookup("lstat", name, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileInfo{name: path.Base(name), meta: m}, nil
+}
+
+// ReadLink returns the target of the symlink name
+func (f *FS) ReadLink(name string) (string, error) {
+	m, err := f.lookup("readlink", name, false)
+	if err != nil {
+		return "", err
+	}
+
+	info := m.Info()
+	if info.Type != meta.LinkType {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+
+	return info.LinkTarget, nil
+}
+
+// ReadDir implements fs.ReadDirFS
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	m, err := f.lookup("readdir", name, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if !m.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: syscall.ENOTDIR}
+	}
+
+	return entries(m), nil
+}
+
+// ReadFile implements fs.ReadFileFS
+func (f *FS) ReadFile(name string) ([]byte, error) {
+	m, err := f.lookup("read", name, true)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &fileInfo{name: path.Base(name), meta: m}
+	switch m.Info().Type {
+	case meta.DirType:
+		return nil, &fs.PathError{Op: "read", Path: name, Err: syscall.EISDIR}
+	case meta.RegularType:
+	default:
+		return []byte{}, nil
+	}
+
+	content, err := f.open(m)
+	if err != nil {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: err}
+	}
+	defer content.Close()
+
+	data := make([]byte, info.Size())
+	n, err := content.ReadAt(data, 0)
+	if err != nil && err != io.EOF {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: err}
+	}
+
+	return data[:n], nil
+}
+
+// entries lists the children of the directory m sorted by name
+func entries(m meta.Meta) []fs.DirEntry {
+	children := m.Children()
+	list := make([]fs.DirEntry, 0, len(children))
+	for _, child := range children {
+		list = append(list, &fileInfo{name: child.Name(), meta: child})
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name() < list[j].Name()
+	})
+
+	return list
+}
+
+// dir is an open directory
+type dir struct {
+	*fileInfo
+	path string
+
+	// entries not yet returned by ReadDir, loaded on first call
+	entries []fs.DirEntry
+	loaded  bool
+}
+
+func (d *dir) Stat() (fs.FileInfo, error) {
+	return d.fileInfo, nil
+}
+
+func (d *dir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.path, Err: syscall.EISDIR}
+}
+
+func (d *dir) Close() error {
+	return nil
+}
+
+// ReadDir implements fs.ReadDirFile
+func (d *dir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.loaded {
+		d.entries = entries(d.meta)
+		d.loaded = true
+	}
+
+	if n <= 0 {
+		list := d.entries
+		d.entries = nil
+		return list, nil
+	}
+
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+
+	if n > len(d.entries) {
+		n = len(d.entries)
+	}
+
+	list := d.entries[:n]
+	d.entries = d.entries[n:]
+	return list, nil
+}
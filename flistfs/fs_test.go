@@ -0,0 +1,197 @@
+package flistfs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"syscall"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/threefoldtech/0-fs/flist"
+	"github.com/threefoldtech/0-fs/internal/testutil"
+	"github.com/threefoldtech/0-fs/meta"
+	"github.com/threefoldtech/0-fs/rofs"
+	"github.com/threefoldtech/0-fs/storage/router"
+)
+
+// testFS creates an flist with some files and links, and the extra links
+func testFS(t *testing.T, extra map[string]string) (*FS, map[string][]byte) {
+	src := t.TempDir()
+	if err := os.MkdirAll(path.Join(src, "bin/sub"), 0750); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string][]byte{
+		"small":   testutil.MakeFile(t, path.Join(src, "small"), 100),
+		"bin/big": testutil.MakeFile(t, path.Join(src, "bin/big"), 2*rofs.DefaultBlockSize*1024+10),
+		"empty":   testutil.MakeFile(t, path.Join(src, "empty"), 0),
+	}
+
+	links := map[string]string{
+		"link":        "bin/big",
+		"bin/abs":     "/small",
+		"bin/escape":  "../../../small",
+		"bin/sub/dir": "..",
+	}
+
+	for name, target := range extra {
+		links[name] = target
+	}
+
+	for name, target := range links {
+		if err := os.Symlink(target, path.Join(src, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mtime := time.Unix(1500000000, 0)
+	for name := range files {
+		if err := os.Chtimes(path.Join(src, name), mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	storage := testutil.Storage{}
+	config := &router.Config{
+		Pools:  map[string]router.PoolConfig{"local": {"00:FF": "zdb://localhost:9900"}},
+		Lookup: []string{"local"},
+	}
+
+	var archive bytes.Buffer
+	if err := flist.Create(src, &archive, storage, config); err != nil {
+		t.Fatal(err)
+	}
+
+	db := t.TempDir()
+	if err := meta.Unpack(&archive, db); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := meta.NewStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	return New(store, storage), files
+}
+
+func TestFS(t *testing.T) {
+	fsys, files := testFS(t, nil)
+
+	if err := fstest.TestFS(fsys, "small", "bin/big", "empty", "bin/sub"); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, data := range files {
+		content, err := fs.ReadFile(fsys, name)
+		if ok := assert.NoError(t, err, name); ok {
+			assert.Equal(t, data, content, name)
+		}
+
+		stat, err := fs.Stat(fsys, name)
+		if ok := assert.NoError(t, err, name); ok {
+			assert.Equal(t, fs.FileMode(0640), stat.Mode(), name)
+			assert.Equal(t, int64(len(data)), stat.Size(), name)
+			assert.Equal(t, int64(1500000000), stat.ModTime().Unix(), name)
+		}
+	}
+
+	stat, err := fs.Stat(fsys, "bin/sub")
+	if ok := assert.NoError(t, err); ok {
+		assert.Equal(t, fs.ModeDir|0750, stat.Mode())
+	}
+
+	_, err = fs.Stat(fsys, "missing")
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+	_, err = fs.ReadFile(fsys, "small/missing")
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+	_, err = fsys.Open("/small")
+	assert.True(t, errors.Is(err, fs.ErrInvalid))
+}
+
+func TestFSLinks(t *testing.T) {
+	fsys, files := testFS(t, map[string]string{"loop": "loop"})
+
+	for name, target := range map[string]string{
+		"link":            "bin/big",
+		"bin/abs":         "small",
+		"bin/escape":      "small",
+		"bin/sub/dir/abs": "small",
+		"bin/sub/dir/big": "bin/big",
+	} {
+		content, err := fs.ReadFile(fsys, name)
+		if ok := assert.NoError(t, err, name); ok {
+			assert.Equal(t, files[target], content, name)
+		}
+	}
+
+	stat, err := fsys.Lstat("link")
+	if ok := assert.NoError(t, err); ok {
+		assert.Equal(t, fs.ModeSymlink, stat.Mode().Type())
+		assert.Equal(t, int64(len("bin/big")), stat.Size())
+	}
+
+	target, err := fsys.ReadLink("bin/sub/dir")
+	assert.NoError(t, err)
+	assert.Equal(t, "..", target)
+
+	_, err = fsys.ReadLink("small")
+	assert.True(t, errors.Is(err, fs.ErrInvalid))
+
+	entries, err := fs.ReadDir(fsys, "bin/sub/dir")
+	if ok := assert.NoError(t, err); ok {
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		assert.Equal(t, []string{"abs", "big", "escape", "sub"}, names)
+	}
+
+	_, err = fs.Stat(fsys, "loop")
+	assert.True(t, errors.Is(err, syscall.ELOOP))
+}
+
+func TestFSSeek(t *testing.T) {
+	fsys, files := testFS(t, nil)
+	data := files["bin/big"]
+
+	file, err := fsys.Open("bin/big")
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	defer file.Close()
+
+	seeker := file.(io.ReadSeeker)
+	// read across the first two blocks
+	off := int64(rofs.DefaultBlockSize*1024 - 5)
+	pos, err := seeker.Seek(off, io.SeekStart)
+	assert.NoError(t, err)
+	assert.Equal(t, off, pos)
+
+	buf := make([]byte, 10)
+	_, err = io.ReadFull(seeker, buf)
+	assert.NoError(t, err)
+	assert.Equal(t, data[off:off+10], buf)
+
+	pos, err = seeker.Seek(-5, io.SeekEnd)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(data)-5), pos)
+
+	rest, err := io.ReadAll(seeker)
+	assert.NoError(t, err)
+	assert.Equal(t, data[len(data)-5:], rest)
+
+	n, err := file.(io.ReaderAt).ReadAt(buf, int64(len(data)-3))
+	assert.Equal(t, 3, n)
+	assert.Equal(t, io.EOF, err)
+
+	_, err = seeker.Seek(-1, io.SeekStart)
+	assert.Error(t, err)
+}
@@ -0,0 +1,140 @@
+package flistfs
+
+import (
+	"context"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/threefoldtech/0-fs/meta"
+	"golang.org/x/net/webdav"
+)
+
+// fsName converts a URL path to a file system name
+func fsName(p string) string {
+	if p = strings.TrimPrefix(path.Clean("/"+p), "/"); p == "" {
+		return "."
+	}
+
+	return p
+}
+
+// etag returns the ETag of the regular file m, it's derived from the file ID so
+// it only changes if the file content changes
+func etag(m meta.Meta) string {
+	return `"` + m.ID() + `"`
+}
+
+// ETag implements webdav.ETager
+func (i *fileInfo) ETag(ctx context.Context) (string, error) {
+	if i.meta.Info().Type != meta.RegularType {
+		return "", webdav.ErrNotImplemented
+	}
+
+	return etag(i.meta), nil
+}
+
+// Handler serves the file system over HTTP with directory listings and range
+// requests, only the blocks that cover the requested range are downloaded.
+// Regular files are served with an ETag, so conditional requests are answered
+// without reading the file. If dav is set, the WebDAV methods are served as
+// well so the flist can be browsed with WebDAV clients, all the WebDAV methods
+// that change content are rejected
+func Handler(fsys *FS, dav bool) http.Handler {
+	files := http.FileServer(http.FS(fsys))
+
+	var davHandler http.Handler
+	if dav {
+		davHandler = &webdav.Handler{
+			FileSystem: &davFS{fsys: fsys, files: http.FS(fsys)},
+			LockSystem: webdav.NewMemLS(),
+			Logger: func(r *http.Request, err error) {
+				if err != nil {
+					log.Debugf("webdav %s %s: %s", r.Method, r.URL.Path, err)
+				}
+			},
+		}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+		default:
+			if davHandler != nil {
+				davHandler.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		if info, err := fsys.Stat(fsName(r.URL.Path)); err == nil && info.Mode().IsRegular() {
+			w.Header().Set("ETag", etag(info.Sys().(meta.Meta)))
+		}
+
+		files.ServeHTTP(w, r)
+	})
+}
+
+// NewServer creates an http server for the Handler of fsys on addr. Slow clients
+// can't hold the connections open: the request headers and body must be read within
+// a timeout, and idle connections are closed. There is no write timeout, so large
+// files can be downloaded over slow links
+func NewServer(addr string, fsys *FS, dav bool) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           Handler(fsys, dav),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       time.Minute,
+		IdleTimeout:       2 * time.Minute,
+	}
+}
+
+// davFS is a read only webdav.FileSystem
+type davFS struct {
+	fsys  *FS
+	files http.FileSystem
+}
+
+func (d *davFS) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	return fs.ErrPermission
+}
+
+func (d *davFS) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 {
+		return nil, fs.ErrPermission
+	}
+
+	file, err := d.files.Open(fsName(name))
+	if err != nil {
+		return nil, err
+	}
+
+	return davFile{file}, nil
+}
+
+func (d *davFS) RemoveAll(ctx context.Context, name string) error {
+	return fs.ErrPermission
+}
+
+func (d *davFS) Rename(ctx context.Context, oldName, newName string) error {
+	return fs.ErrPermission
+}
+
+func (d *davFS) Stat(ctx context.Context, p string) (os.FileInfo, error) {
+	return d.fsys.Stat(fsName(p))
+}
+
+// davFile is a read only webdav.File
+type davFile struct {
+	http.File
+}
+
+func (f davFile) Write([]byte) (int, error) {
+	return 0, fs.ErrPermission
+}
@@ -0,0 +1,142 @@
+package flistfs
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/threefoldtech/0-fs/rofs"
+)
+
+func TestHandler(t *testing.T) {
+	fsys, files := testFS(t, nil)
+
+	// files are served through the cache
+	cache := rofs.NewCache(t.TempDir(), fsys.storage)
+	cache.SetMode(rofs.BlockCache)
+	server := httptest.NewServer(Handler(NewCached(fsys.store, cache), true))
+	defer server.Close()
+
+	do := func(method, p string, headers ...string) (*http.Response, []byte) {
+		req, err := http.NewRequest(method, server.URL+p, nil)
+		if ok := assert.NoError(t, err); !ok {
+			t.Fatal()
+		}
+
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if ok := assert.NoError(t, err); !ok {
+			t.Fatal()
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		return resp, body
+	}
+
+	resp, body := do("GET", "/small")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, files["small"], body)
+	etag := resp.Header.Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	resp, _ = do("GET", "/small", "If-None-Match", etag)
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+
+	// a range over the first two blocks
+	data := files["bin/big"]
+	start := rofs.DefaultBlockSize*1024 - 5
+	resp, body = do("GET", "/bin/big", "Range", fmt.Sprintf("bytes=%d-%d", start, start+9))
+	assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	assert.Equal(t, data[start:start+10], body)
+
+	// links are followed
+	resp, body = do("GET", "/link")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, data, body)
+
+	resp, body = do("GET", "/bin/")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	for _, entry := range []string{"big", "sub/", "abs"} {
+		assert.Contains(t, string(body), fmt.Sprintf(`href="%s"`, entry))
+	}
+
+	resp, body = do("GET", "/empty")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Len(t, body, 0)
+
+	resp, _ = do("GET", "/missing")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, body = do("PROPFIND", "/bin/", "Depth", "1")
+	assert.Equal(t, http.StatusMultiStatus, resp.StatusCode)
+	for _, href := range []string{"/bin/", "/bin/big", "/bin/sub/"} {
+		assert.Contains(t, string(body), fmt.Sprintf("<D:href>%s</D:href>", href))
+	}
+	assert.Contains(t, string(body), fmt.Sprintf("<D:getetag>%s</D:getetag>", etagOf(t, fsys, "bin/big")))
+
+	for _, method := range []string{"PUT", "DELETE", "MKCOL"} {
+		resp, _ = do(method, "/small")
+		assert.True(t, resp.StatusCode >= 400, "%s %d", method, resp.StatusCode)
+	}
+
+	content, err := fsys.ReadFile("small")
+	assert.NoError(t, err)
+	assert.Equal(t, files["small"], content)
+}
+
+func TestHandlerNoWebDAV(t *testing.T) {
+	fsys, _ := testFS(t, nil)
+	server := httptest.NewServer(Handler(fsys, false))
+	defer server.Close()
+
+	req, err := http.NewRequest("PROPFIND", server.URL+"/", nil)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func TestNewServer(t *testing.T) {
+	fsys, _ := testFS(t, nil)
+	server := NewServer("127.0.0.1:0", fsys, false)
+
+	assert.NotZero(t, server.ReadHeaderTimeout)
+	assert.NotZero(t, server.ReadTimeout)
+	assert.NotZero(t, server.IdleTimeout)
+
+	// the server serves the flist handler
+	test := httptest.NewServer(server.Handler)
+	defer test.Close()
+
+	resp, err := http.Get(test.URL + "/small")
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func etagOf(t *testing.T, fsys *FS, p string) string {
+	m, ok := fsys.store.Get(p)
+	if ok := assert.True(t, ok, p); !ok {
+		t.Fatal()
+	}
+
+	return etag(m)
+}
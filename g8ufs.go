@@ -1,7 +1,9 @@
 package g8ufs
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path"
@@ -13,9 +15,11 @@ import (
 	"github.com/hanwen/go-fuse/v2/fuse/nodefs"
 	"github.com/hanwen/go-fuse/v2/fuse/pathfs"
 	"github.com/op/go-logging"
+	"github.com/threefoldtech/0-fs/flist"
 	"github.com/threefoldtech/0-fs/meta"
 	"github.com/threefoldtech/0-fs/rofs"
 	"github.com/threefoldtech/0-fs/storage"
+	"github.com/threefoldtech/0-fs/storage/router"
 	"golang.org/x/sys/unix"
 )
 
@@ -40,6 +44,13 @@ type Options struct {
 	//Cache location where downloaded files are gonna be kept (optional). If not provided
 	//a cache directly will be created under the backend.
 	Cache string
+	//CacheSize (optional) max size of the cache in bytes, least recently used files
+	//are evicted once the cache grows beyond this size. Zero means no limit
+	CacheSize uint64
+	//CacheFiles (optional) max number of files (or blocks) in the cache. Zero means no limit
+	CacheFiles uint64
+	//CacheMode (optional) how downloaded data is kept in the cache, see rofs.CacheMode
+	CacheMode rofs.CacheMode
 	//Mount (required) is the mount point
 	Target string
 	//Store (optional), if not provided `Reset` flag will have no effect, and only the backend overlay
@@ -47,38 +58,59 @@ type Options struct {
 	Store meta.Store
 	//Storage (required) storage to download files from
 	Storage storage.Storage
+	//Layers (optional) storage to download the files of each layer of Store from,
+	//see rofs.Cache.SetLayerStorage. Files of other layers are downloaded from Storage
+	Layers map[meta.Store]storage.Storage
 	//Reset if set, will wipe up the backend clean before mounting.
 	Reset bool
 	//Mount fs read-only
 	ReadOnly bool
+	//Prefetch (optional) paths of files (or directories) that are downloaded to the
+	//cache in the background once the filesystem is mounted
+	Prefetch []string
+	//PrefetchWorkers (optional) number of files prefetched in parallel
+	PrefetchWorkers int
+	//Record (optional) if set, the path of each opened file is written to Record in
+	//the order of first access, the output can be used as Prefetch for the next mount
+	Record io.Writer
+	//Inodes (optional) how inode numbers are reported by the filesystem, see rofs.InodeMode
+	Inodes rofs.InodeMode
 }
 
 // G8ufs struct
 type G8ufs struct {
 	*rofs.Config
+	// rw is the overlay upper directory, empty if mounted read-only
+	rw     string
 	layers []string
 	w      sync.WaitGroup
+	cancel context.CancelFunc
 }
 
-func mountRO(name, target string, storage storage.Storage, meta meta.Store, cache string) (*G8ufs, error) {
+func mountRO(name, target, cache string, opt *Options) (*G8ufs, error) {
 	log.Debugf("ro: '%s' ca: %s", target, cache)
 
-	cfg := rofs.NewConfig(storage, meta, cache)
+	cfg := rofs.NewConfig(opt.Storage, opt.Store, cache)
+	cfg.SetInodeMode(opt.Inodes)
+	cfg.SetCacheMode(opt.CacheMode)
+	cfg.SetLayerStorage(opt.Layers)
 	fs := rofs.New(cfg)
 	// opts := nodefs.Options{Debug: true}
 	opts := nodefs.Options{}
 
 	server, err := fuse.NewServer(
 		nodefs.NewFileSystemConnector(
-			pathfs.NewPathNodeFs(fs, nil).Root(),
+			pathfs.NewPathNodeFs(fs, &pathfs.PathNodeFsOptions{
+				// files sharing an inode are the same node
+				ClientInodes: opt.Inodes == rofs.HardlinkInodes,
+			}).Root(),
 			&opts,
 		).RawFS(), target, &fuse.MountOptions{
 			// Debug:         true,
-			AllowOther:    true,
-			FsName:        name,
-			Name:          "g8ufs",
-			DisableXAttrs: true,
-			Options:       []string{"ro", "default_permissions"},
+			AllowOther: true,
+			FsName:     name,
+			Name:       "g8ufs",
+			Options:    []string{"ro", "default_permissions"},
 		})
 
 	if err != nil {
@@ -133,7 +165,7 @@ func Mount(opt *Options) (fs *G8ufs, err error) {
 		return
 	}
 
-	fs, err = mountRO(name, ro, opt.Storage, opt.Store, ca)
+	fs, err = mountRO(name, ro, ca, opt)
 	if err != nil {
 		err = fmt.Errorf("failed to do ro layer mount: %s", err)
 		return
@@ -141,6 +173,10 @@ func Mount(opt *Options) (fs *G8ufs, err error) {
 
 	log.Debugf("read-only layer mounted")
 
+	if opt.Record != nil {
+		fs.Record(opt.Record)
+	}
+
 	defer func() {
 		if err != nil {
 			if err := fs.Unmount(); err != nil {
@@ -151,6 +187,21 @@ func Mount(opt *Options) (fs *G8ufs, err error) {
 
 		fs.w.Add(1)
 		go fs.watch()
+
+		var ctx context.Context
+		ctx, fs.cancel = context.WithCancel(context.Background())
+
+		budget := rofs.Budget{Size: opt.CacheSize, Files: opt.CacheFiles}
+		if !budget.Unlimited() {
+			go fs.Evictor(ctx, budget)
+		}
+
+		if len(opt.Prefetch) != 0 {
+			go func() {
+				count := fs.Config.Prefetch(ctx, opt.Prefetch, opt.PrefetchWorkers)
+				log.Infof("prefetched %d files", count)
+			}()
+		}
 	}()
 
 	if opt.ReadOnly {
@@ -167,6 +218,9 @@ func Mount(opt *Options) (fs *G8ufs, err error) {
 		}
 	}
 
+	//writes go to the upper layer, so its free space is the free space of the mount
+	fs.SetSpacePath(rw)
+
 	info, err := os.Stat(ro)
 	if err == nil {
 		//this should not fail ever, because we already make sure that
@@ -195,6 +249,7 @@ func Mount(opt *Options) (fs *G8ufs, err error) {
 		return
 	}
 
+	fs.rw = rw
 	fs.layers = append(fs.layers, opt.Target)
 
 	mounted := false
@@ -218,6 +273,35 @@ func Mount(opt *Options) (fs *G8ufs, err error) {
 	return fs, nil
 }
 
+// Commit writes the changes made to the mounted filesystem (deleted entries
+// included) to w as an flist layer, the file blocks are uploaded to storage and
+// config is used as the layer routing table. The layer can be stacked on top of
+// the mounted flist with meta.Layered. Files that are still being written while
+// Commit runs may not be committed in full
+func (fs *G8ufs) Commit(w io.Writer, storage storage.Setter, config *router.Config) error {
+	if fs.rw == "" {
+		return fmt.Errorf("filesystem is mounted read-only, nothing to commit")
+	}
+
+	return flist.CommitOverlay(fs.rw, w, storage, config)
+}
+
+// Commit is like G8ufs.Commit but works on the backend directory of a mount that
+// is not (or no longer) mounted
+func Commit(backend string, w io.Writer, storage storage.Setter, config *router.Config) error {
+	rw := path.Join(backend, "rw")
+	info, err := os.Stat(rw)
+	if err != nil {
+		return fmt.Errorf("failed to find the overlay of backend '%s': %s", backend, err)
+	}
+
+	if !info.IsDir() {
+		return fmt.Errorf("'%s' is not a directory", rw)
+	}
+
+	return flist.CommitOverlay(rw, w, storage, config)
+}
+
 func (fs *G8ufs) watch() {
 	defer fs.w.Done()
 
@@ -263,6 +347,10 @@ func (e errors) Error() string {
 func (fs *G8ufs) Unmount() error {
 	var errs errors
 
+	if fs.cancel != nil {
+		fs.cancel()
+	}
+
 	for i := len(fs.layers) - 1; i >= 0; i-- {
 		if err := syscall.Unmount(fs.layers[i], syscall.MNT_FORCE|syscall.MNT_DETACH); err != nil {
 			errs = append(errs, err)
@@ -0,0 +1,42 @@
+// Package testutil holds the fixtures shared by the tests of the flist packages
+package testutil
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+)
+
+// Storage is an in memory block storage
+type Storage map[string][]byte
+
+// Get returns the block key
+func (s Storage) Get(key []byte) (io.ReadCloser, error) {
+	if data, ok := s[string(key)]; ok {
+		return io.NopCloser(bytes.NewBuffer(data)), nil
+	}
+	return nil, fmt.Errorf("not found")
+}
+
+// Set sets the block key to data
+func (s Storage) Set(key []byte, data []byte) error {
+	s[string(key)] = data
+	return nil
+}
+
+// MakeFile writes size random bytes to the file name and returns them
+func MakeFile(t *testing.T, name string, size int) []byte {
+	data := make([]byte, size)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(name, data, 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
@@ -0,0 +1,119 @@
+package meta
+
+import (
+	"fmt"
+	"path"
+
+	np "github.com/threefoldtech/0-fs/cap.np"
+)
+
+// CheckFn is called by Check for each entry of the flist. If the entry metadata is
+// broken, err describes the problem, and m is nil if the entry can't be read at all.
+// If fn returns an error, Check stops and returns this error
+type CheckFn func(path string, m Meta, err error) error
+
+// Checker interface, some stores can implement this interface
+type Checker interface {
+	Store
+	// Check walks over the full flist, like Walk, but reports the entries
+	// that can't be read (corrupt directories) or that are missing their
+	// access information (ACI) instead of silently skipping them
+	Check(fn CheckFn) error
+}
+
+// inode creates the meta of a non directory inode, if the inode ACI can't
+// be read, the inode gets the default access and the error is returned
+func (s *sqlStore) inode(inode np.Inode) (Meta, error) {
+	key, _ := inode.Aclkey()
+	access, err := s.getAccess(key)
+
+	attributes := inode.Attributes()
+	switch attributes.Which() {
+	case np.Inode_attributes_Which_file:
+		file, _ := attributes.File()
+		return &File{Inode: inode, file: file, access: access}, err
+	case np.Inode_attributes_Which_link:
+		link, _ := attributes.Link()
+		return &Link{Inode: inode, link: link, access: access}, err
+	case np.Inode_attributes_Which_special:
+		special, _ := attributes.Special()
+		return &Special{Inode: inode, special: special, access: access}, err
+	default:
+		return nil, fmt.Errorf("unknown inode type")
+	}
+}
+
+func checkError(err error, key string) error {
+	if err == errNoACI {
+		return fmt.Errorf("missing ACI '%s'", key)
+	}
+
+	return fmt.Errorf("corrupt ACI '%s': %s", key, err)
+}
+
+func (s *sqlStore) Check(fn CheckFn) error {
+	hash, err := s.hash("")
+	if err != nil {
+		return err
+	}
+
+	return s.checkDir("", hash, fn)
+}
+
+func (s *sqlStore) checkDir(p string, hash string, fn CheckFn) error {
+	dir, err := s.readDir(hash)
+	if err != nil && err != errNoACI {
+		return fn(p, nil, fmt.Errorf("corrupt or missing directory '%s': %s", hash, err))
+	} else if err == errNoACI {
+		key, _ := dir.Aclkey()
+		err = checkError(err, key)
+	}
+
+	if err := fn(p, dir, err); err != nil {
+		return err
+	}
+
+	if !dir.HasContents() {
+		return nil
+	}
+
+	contents, err := dir.Contents()
+	if err != nil {
+		return fn(p, nil, fmt.Errorf("corrupt directory content: %s", err))
+	}
+
+	for i := 0; i < contents.Len(); i++ {
+		inode := contents.At(i)
+		name, err := inode.Name()
+		if err != nil {
+			if err := fn(p, nil, fmt.Errorf("corrupt entry %d: %s", i, err)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		child := path.Join(p, name)
+		if attributes := inode.Attributes(); attributes.Which() == np.Inode_attributes_Which_dir {
+			sub, _ := attributes.Dir()
+			key, _ := sub.Key()
+			if err := s.checkDir(child, key, fn); err != nil {
+				return err
+			}
+			continue
+		}
+
+		m, err := s.inode(inode)
+		if m == nil {
+			err = fmt.Errorf("corrupt entry: %s", err)
+		} else if err != nil {
+			key, _ := inode.Aclkey()
+			err = checkError(err, key)
+		}
+
+		if err := fn(child, m, err); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
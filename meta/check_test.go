@@ -0,0 +1,86 @@
+package meta
+
+import (
+	"database/sql"
+	"fmt"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheck(t *testing.T) {
+	root := t.TempDir()
+	writer, err := NewWriter(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := Info{Type: RegularType, Access: Access{Mode: 0600, UID: 10}}
+	entries := map[string]Info{
+		"etc":        {Type: DirType, Access: Access{Mode: 0755}},
+		"etc/passwd": file,
+		"etc/group":  {Type: RegularType, Access: Access{Mode: 0644}},
+		"broken":     {Type: DirType, Access: Access{Mode: 0755}},
+		"broken/a":   {Type: RegularType, Access: Access{Mode: 0644}},
+	}
+
+	for p, info := range entries {
+		if err := writer.Add(p, info, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("sqlite3", path.Join(root, SQLiteDBName))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	aci, _ := hash(fmt.Sprintf("aci:%d:%d:%o", file.Access.UID, file.Access.GID, file.Access.Mode))
+	broken, _ := hash("broken")
+	if _, err := db.Exec("delete from entries where key = ?", aci); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("update entries set value = ? where key = ?", []byte("garbage"), broken); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	store, err := NewStore(root)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	defer store.Close()
+
+	checked := make(map[string]error)
+	err = store.(Checker).Check(func(p string, m Meta, err error) error {
+		checked[p] = err
+		if err == nil {
+			assert.NotNil(t, m, p)
+		}
+		return nil
+	})
+
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	assert.Len(t, checked, 5)
+	assert.NoError(t, checked[""])
+	assert.NoError(t, checked["etc"])
+	assert.NoError(t, checked["etc/group"])
+	if ok := assert.Error(t, checked["etc/passwd"]); ok {
+		assert.Contains(t, checked["etc/passwd"].Error(), "missing ACI")
+	}
+	if ok := assert.Error(t, checked["broken"]); ok {
+		assert.Contains(t, checked["broken"].Error(), "corrupt or missing directory")
+	}
+
+	// a broken directory is skipped by its parent
+	top, _ := store.Get("")
+	assert.Len(t, top.Children(), 1)
+}
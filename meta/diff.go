@@ -0,0 +1,208 @@
+package meta
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"sort"
+)
+
+// ChangeKind is the kind of a change between two flists
+type ChangeKind int
+
+// ChangeKind values
+const (
+	Added ChangeKind = iota + 1
+	Removed
+	Modified
+)
+
+// String implements fmt.Stringer interface
+func (k ChangeKind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Modified:
+		return "modified"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler interface
+func (k ChangeKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// Change is a difference of an entry between two flists
+type Change struct {
+	Path string
+	Kind ChangeKind
+	// Metadata is set if the entry access (mode, uid, gid), modification
+	// time or extended attributes changed
+	Metadata bool
+	// Content is set if the entry type or content changed, the content of
+	// a file is its size and blocks, of a link its target, and of a device
+	// its major and minor numbers
+	Content bool
+	// Old is the entry in the old flist, nil if added
+	Old Meta
+	// New is the entry in the new flist, nil if removed
+	New Meta
+}
+
+// DiffFn is called for each change found by Diff, if it returns an error
+// Diff stops and returns this error
+type DiffFn func(change Change) error
+
+// Diff walks over both stores in lockstep and calls fn for each added, removed or
+// modified entry of b compared to a. Entries are reported in path order, directories
+// before their content. The content of added and removed directories is reported too.
+func Diff(a, b Store, fn DiffFn) error {
+	from, ok := a.Get("")
+	if !ok {
+		return fmt.Errorf("old flist has no root directory")
+	}
+
+	to, ok := b.Get("")
+	if !ok {
+		return fmt.Errorf("new flist has no root directory")
+	}
+
+	return diffEntry("", from, to, fn)
+}
+
+func sorted(m Meta) []Meta {
+	children := append([]Meta{}, m.Children()...)
+	sort.Slice(children, func(i, j int) bool {
+		return children[i].Name() < children[j].Name()
+	})
+
+	return children
+}
+
+// diffEntry compares the entry p that exists in both flists
+func diffEntry(p string, from, to Meta, fn DiffFn) error {
+	fromInfo, toInfo := from.Info(), to.Info()
+
+	change := Change{
+		Path:     p,
+		Kind:     Modified,
+		Metadata: metadataChanged(fromInfo, toInfo),
+		Content:  contentChanged(from, to),
+		Old:      from,
+		New:      to,
+	}
+
+	if change.Metadata || change.Content {
+		if err := fn(change); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case from.IsDir() && to.IsDir():
+		return diffDir(p, from, to, fn)
+	case from.IsDir():
+		return walkChildren(p, from, Removed, fn)
+	case to.IsDir():
+		return walkChildren(p, to, Added, fn)
+	}
+
+	return nil
+}
+
+func diffDir(p string, from, to Meta, fn DiffFn) error {
+	fromChildren, toChildren := sorted(from), sorted(to)
+
+	i, j := 0, 0
+	for i < len(fromChildren) || j < len(toChildren) {
+		var err error
+		switch {
+		case j == len(toChildren) || i < len(fromChildren) && fromChildren[i].Name() < toChildren[j].Name():
+			err = walk(path.Join(p, fromChildren[i].Name()), fromChildren[i], Removed, fn)
+			i++
+		case i == len(fromChildren) || toChildren[j].Name() < fromChildren[i].Name():
+			err = walk(path.Join(p, toChildren[j].Name()), toChildren[j], Added, fn)
+			j++
+		default:
+			err = diffEntry(path.Join(p, fromChildren[i].Name()), fromChildren[i], toChildren[j], fn)
+			i++
+			j++
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// walk reports m and all its content with kind
+func walk(p string, m Meta, kind ChangeKind, fn DiffFn) error {
+	change := Change{Path: p, Kind: kind}
+	if kind == Added {
+		change.New = m
+	} else {
+		change.Old = m
+	}
+
+	if err := fn(change); err != nil {
+		return err
+	}
+
+	if !m.IsDir() {
+		return nil
+	}
+
+	return walkChildren(p, m, kind, fn)
+}
+
+func walkChildren(p string, m Meta, kind ChangeKind, fn DiffFn) error {
+	for _, child := range sorted(m) {
+		if err := walk(path.Join(p, child.Name()), child, kind, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func metadataChanged(from, to Info) bool {
+	if from.Access != to.Access || from.ModificationTime != to.ModificationTime {
+		return true
+	}
+
+	if len(from.XAttrs) != len(to.XAttrs) {
+		return true
+	}
+
+	for key, value := range from.XAttrs {
+		if other, ok := to.XAttrs[key]; !ok || !bytes.Equal(value, other) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func contentChanged(from, to Meta) bool {
+	fromInfo, toInfo := from.Info(), to.Info()
+	if fromInfo.Type != toInfo.Type {
+		return true
+	}
+
+	switch fromInfo.Type {
+	case RegularType:
+		return fromInfo.Size != toInfo.Size || from.ID() != to.ID()
+	case LinkType:
+		return fromInfo.LinkTarget != toInfo.LinkTarget
+	case CharDeviceType, BlockDeviceType:
+		return fromInfo.SpecialData != toInfo.SpecialData
+	}
+
+	return false
+}
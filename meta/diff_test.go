@@ -0,0 +1,117 @@
+package meta
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testEntry struct {
+	info   Info
+	blocks []BlockInfo
+}
+
+func newWriterStore(t *testing.T, entries map[string]testEntry) Store {
+	root := t.TempDir()
+	writer, err := NewWriter(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for p, entry := range entries {
+		if err := writer.Add(p, entry.info, entry.blocks); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := NewStore(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return store
+}
+
+func TestDiff(t *testing.T) {
+	file := func(mode uint32, size uint64, key string) testEntry {
+		return testEntry{
+			info: Info{
+				Type:          RegularType,
+				Access:        Access{Mode: mode},
+				Size:          size,
+				FileBlockSize: 512 * 1024,
+			},
+			blocks: []BlockInfo{{Key: []byte(key), Decipher: []byte(key)}},
+		}
+	}
+
+	dir := testEntry{info: Info{Type: DirType, Access: Access{Mode: 0755}}}
+	link := func(target string) testEntry {
+		return testEntry{info: Info{Type: LinkType, Access: Access{Mode: 0777}, LinkTarget: target}}
+	}
+
+	a := newWriterStore(t, map[string]testEntry{
+		"bin":         dir,
+		"bin/sh":      file(0755, 10, "sh"),
+		"bin/ls":      file(0755, 10, "ls"),
+		"etc":         dir,
+		"etc/passwd":  file(0644, 10, "passwd"),
+		"etc/link":    link("passwd"),
+		"old":         dir,
+		"old/a":       file(0644, 1, "a"),
+		"was-dir":     dir,
+		"was-dir/b":   file(0644, 1, "b"),
+		"unchanged":   file(0644, 1, "u"),
+		"bin/touched": file(0644, 1, "t"),
+	})
+	defer a.Close()
+
+	b := newWriterStore(t, map[string]testEntry{
+		"bin":         dir,
+		"bin/sh":      file(0700, 10, "sh"),
+		"bin/ls":      file(0755, 12, "ls2"),
+		"etc":         dir,
+		"etc/passwd":  file(0644, 10, "passwd"),
+		"etc/link":    link("shadow"),
+		"new":         dir,
+		"new/c":       file(0644, 1, "c"),
+		"was-dir":     file(0644, 1, "b"),
+		"unchanged":   file(0644, 1, "u"),
+		"bin/touched": {info: Info{Type: RegularType, Access: Access{Mode: 0644}, Size: 1, ModificationTime: 10, FileBlockSize: 512 * 1024}, blocks: []BlockInfo{{Key: []byte("t"), Decipher: []byte("t")}}},
+	})
+	defer b.Close()
+
+	var changes []string
+	err := Diff(a, b, func(change Change) error {
+		changes = append(changes, fmt.Sprintf("%s %s meta:%v content:%v", change.Kind, change.Path, change.Metadata, change.Content))
+		return nil
+	})
+
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	assert.Equal(t, []string{
+		"modified bin/ls meta:false content:true",
+		"modified bin/sh meta:true content:false",
+		"modified bin/touched meta:true content:false",
+		"modified etc/link meta:false content:true",
+		"added new meta:false content:false",
+		"added new/c meta:false content:false",
+		"removed old meta:false content:false",
+		"removed old/a meta:false content:false",
+		"modified was-dir meta:true content:true",
+		"removed was-dir/b meta:false content:false",
+	}, changes)
+
+	// no changes between the same flists
+	err = Diff(a, a, func(change Change) error {
+		return fmt.Errorf("unexpected change %s", change.Path)
+	})
+	assert.NoError(t, err)
+}
@@ -48,12 +48,14 @@ func (d *Dir) Blocks() []BlockInfo {
 // Info return meta info for this dir
 func (d *Dir) Info() Info {
 	d.iOnce.Do(func() {
+		attrs, _ := d.Xattrs()
 		d.info = Info{
 			CreationTime:     d.CreationTime(),
 			ModificationTime: d.ModificationTime(),
 			Size:             4096,
 			Type:             DirType,
 			Access:           d.access,
+			XAttrs:           xattrs(attrs),
 		}
 	})
 
@@ -85,27 +87,16 @@ func (d *Dir) getChildren() []Meta {
 		inode := contents.At(i)
 
 		var m Meta
-		attributes := inode.Attributes()
-		switch attributes.Which() {
-		case np.Inode_attributes_Which_dir:
+		if attributes := inode.Attributes(); attributes.Which() == np.Inode_attributes_Which_dir {
 			dir, _ := attributes.Dir()
 			subkey, _ := dir.Key()
-			sub, _ := d.store.getDirWithHash(subkey)
-			m = sub
-		case np.Inode_attributes_Which_file:
-			file, _ := attributes.File()
-			key, _ := inode.Aclkey()
-			access, _ := d.store.getAccess(key)
-			m = &File{Inode: inode, file: file, access: access}
-		case np.Inode_attributes_Which_link:
-			link, _ := attributes.Link()
-			key, _ := inode.Aclkey()
-			access, _ := d.store.getAccess(key)
-			m = &Link{Inode: inode, link: link, access: access}
-		case np.Inode_attributes_Which_special:
-		default:
-			continue
+			if sub, err := d.store.getDirWithHash(subkey); err == nil {
+				m = sub
+			}
+		} else {
+			m, _ = d.store.inode(inode)
 		}
+
 		if m != nil {
 			children = append(children, m)
 		}
@@ -54,12 +54,14 @@ func (f *File) Children() []Meta {
 // Info return meta info for this dir
 func (f *File) Info() Info {
 	f.iOnce.Do(func() {
+		attrs, _ := f.Xattrs()
 		f.info = Info{
 			CreationTime:     f.CreationTime(),
 			ModificationTime: f.ModificationTime(),
 			Size:             f.Size(),
 			Type:             RegularType,
 			Access:           f.access,
+			XAttrs:           xattrs(attrs),
 			FileBlockSize:    uint64(f.file.BlockSize()) * 4096,
 		}
 	})
@@ -1,8 +1,89 @@
 package meta
 
-import "sync"
+import (
+	"path"
+	"strings"
+	"sync"
 
-type stores []Store
+	lru "github.com/hashicorp/golang-lru"
+)
+
+const (
+	// WhiteoutPrefix is the name prefix of a whiteout entry. A `.wh.<name>` entry in
+	// a layer hides `<name>` (and all its content) from the layers below
+	WhiteoutPrefix = ".wh."
+	// WhiteoutOpaque is the name of the opaque directory marker. A directory that
+	// contains this entry hides the content of the same directory in the layers below
+	WhiteoutOpaque = WhiteoutPrefix + WhiteoutPrefix + ".opq"
+
+	// MarksCacheSize defines the size of the LRU cache for the whiteouts of the directories
+	MarksCacheSize = 4096
+)
+
+// layered is a store made of layers, the top most layer first
+type layered struct {
+	layers []Store
+
+	// marks of the directories of each layer that were looked up
+	marks *lru.Cache
+}
+
+type layerDir struct {
+	layer int
+	dir   string
+}
+
+// marks are the whiteouts of a directory of a layer
+type marks struct {
+	// found is true if the entry exists in the layer
+	found bool
+	// dir is true if the entry is a directory
+	dir bool
+	// opaque is true if the directory hides the same directory of the layers below
+	opaque bool
+	// whiteouts are the names hidden from the layers below
+	whiteouts map[string]struct{}
+}
+
+// layerEntry is an entry of a layered store, it remembers the layer it comes from
+type layerEntry struct {
+	Meta
+	layer Store
+}
+
+func (e *layerEntry) Children() []Meta {
+	children := e.Meta.Children()
+	entries := make([]Meta, 0, len(children))
+	for _, child := range children {
+		entries = append(entries, inLayer(child, e.layer))
+	}
+
+	return entries
+}
+
+// inLayer binds the entry m to the layer it comes from. Entries of nested
+// layered stores keep the innermost layer
+func inLayer(m Meta, layer Store) Meta {
+	if Layer(m) != nil {
+		return m
+	}
+
+	return &layerEntry{Meta: m, layer: layer}
+}
+
+// Layer returns the store (one of the stores given to Layered) the entry m comes
+// from. It returns nil if m is not an entry of a layered store. Directories are
+// merged from all layers, their layer is the top most one
+func Layer(m Meta) Store {
+	switch entry := m.(type) {
+	case *layerEntry:
+		return entry.layer
+	case *mergedDir:
+		return Layer(entry.Meta)
+	}
+
+	return nil
+}
 
 type mergedDir struct {
 	Meta
@@ -12,22 +93,45 @@ type mergedDir struct {
 	o      sync.Once
 }
 
+func isWhiteout(name string) bool {
+	return strings.HasPrefix(name, WhiteoutPrefix)
+}
+
 func (m *mergedDir) children() {
 	set := make(map[string]Meta)
-	for _, child := range m.Meta.Children() {
-		set[child.Name()] = child
-	}
+	// deleted are the entries whited out by the upper layers
+	deleted := make(map[string]struct{})
+	var names []string
+
+	for _, layer := range append([]Meta{m.Meta}, m.lower...) {
+		var whiteouts []string
+		for _, child := range layer.Children() {
+			name := child.Name()
+			if isWhiteout(name) {
+				if name != WhiteoutOpaque {
+					whiteouts = append(whiteouts, strings.TrimPrefix(name, WhiteoutPrefix))
+				}
+				continue
+			}
 
-	for _, l := range m.lower {
-		for _, child := range l.Children() {
-			if _, ok := set[child.Name()]; !ok {
-				set[child.Name()] = child
+			if _, ok := deleted[name]; ok {
+				continue
 			}
+
+			if _, ok := set[name]; !ok {
+				set[name] = child
+				names = append(names, name)
+			}
+		}
+
+		for _, name := range whiteouts {
+			deleted[name] = struct{}{}
 		}
 	}
+
 	m.merged = make([]Meta, 0, len(set))
-	for _, child := range set {
-		m.merged = append(m.merged, child)
+	for _, name := range names {
+		m.merged = append(m.merged, set[name])
 	}
 }
 
@@ -36,44 +140,188 @@ func (m *mergedDir) Children() []Meta {
 	return m.merged
 }
 
-func (s stores) getMerge(p string, top Meta, under []Store) Meta {
+// marksOf returns the marks of the directory p of the layer, they are read once
+// from the directory children and cached
+func (s *layered) marksOf(layer int, p string) *marks {
+	key := layerDir{layer: layer, dir: p}
+	cached, ok := s.marks.Get(key)
+	lruResult("marks", ok)
+	if ok {
+		return cached.(*marks)
+	}
+
+	mk := &marks{}
+	if m, ok := s.layers[layer].Get(p); ok {
+		mk.found = true
+		mk.dir = m.IsDir()
+		if mk.dir {
+			for _, child := range m.Children() {
+				name := child.Name()
+				if name == WhiteoutOpaque {
+					mk.opaque = true
+				} else if isWhiteout(name) {
+					if mk.whiteouts == nil {
+						mk.whiteouts = make(map[string]struct{})
+					}
+					mk.whiteouts[strings.TrimPrefix(name, WhiteoutPrefix)] = struct{}{}
+				}
+			}
+		}
+	}
+
+	s.marks.Add(key, mk)
+	return mk
+}
+
+// opaque checks if the directory p of the layer is marked as opaque
+func (s *layered) opaque(layer int, p string) bool {
+	return s.marksOf(layer, p).opaque
+}
+
+// hides checks if the layer hides the entry p from the layers below it. That's the
+// case if p or one of its parents is whited out, one of its parents is opaque,
+// or one of its parents is not a directory. The bottom layer hides nothing
+func (s *layered) hides(layer int, p string) bool {
+	if layer == len(s.layers)-1 {
+		return false
+	}
+
+	for q := p; q != ""; {
+		dir, name := path.Split(q)
+		dir = strings.TrimSuffix(dir, "/")
+
+		if _, ok := s.marksOf(layer, dir).whiteouts[name]; ok {
+			return true
+		}
+
+		if q != p {
+			if mk := s.marksOf(layer, q); mk.found && (!mk.dir || mk.opaque) {
+				return true
+			}
+		}
+
+		q = dir
+	}
+
+	return p != "" && s.opaque(layer, "")
+}
+
+// getMerge merges the directory top with the same directory of the layers
+// starting at under
+func (s *layered) getMerge(p string, top Meta, under int) Meta {
 	var lower []Meta
-	for _, store := range under {
-		if m, ok := store.Get(p); ok {
-			lower = append(lower, m)
+	for i := under; i < len(s.layers); i++ {
+		store := s.layers[i]
+		m, ok := store.Get(p)
+		if !ok {
+			if s.hides(i, p) {
+				break
+			}
+
+			continue
+		}
+
+		if !m.IsDir() {
+			// a file in a lower layer hides the layers below it
+			break
+		}
+
+		lower = append(lower, inLayer(m, store))
+		if s.opaque(i, p) {
+			break
 		}
 	}
 
 	return &mergedDir{Meta: top, lower: lower}
 }
 
-func (s stores) Get(p string) (Meta, bool) {
-	for i, store := range s {
+func (s *layered) Get(p string) (Meta, bool) {
+	if isWhiteout(path.Base(p)) {
+		// whiteouts are never visible
+		return nil, false
+	}
+
+	for i, store := range s.layers {
 		m, ok := store.Get(p)
 		if !ok {
+			if s.hides(i, p) {
+				return nil, false
+			}
+
 			continue
 		}
 
+		m = inLayer(m, store)
 		if !m.IsDir() {
 			//we hit a file, then we should return
 			return m, true
 		}
 
-		if i+1 == len(s) {
-			//no lower layers
-			return m, true
+		if s.opaque(i, p) {
+			//lower layers are hidden
+			return s.getMerge(p, m, len(s.layers)), true
 		}
 
 		//a directory
-		return s.getMerge(p, m, s[i+1:]), true
+		return s.getMerge(p, m, i+1), true
 	}
 
 	return nil, false
 }
 
-func (s stores) Close() error {
+// Walk walks over the merged content of the directory root, see Walker. Directories
+// are looked up in the layered store, so each level is merged from all the layers
+func (s *layered) Walk(root string, fn WalkFn) error {
+	m, ok := s.Get(root)
+	if !ok {
+		return ErrNotFound
+	}
+
+	if m.IsDir() {
+		return s.walkDir(root, m, fn)
+	}
+
+	return fn(root, m)
+}
+
+func (s *layered) walkDir(root string, dir Meta, fn WalkFn) error {
+	err := fn(root, dir)
+	if err == ErrSkipDir {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	for _, child := range dir.Children() {
+		p := path.Join(root, child.Name())
+		if child.IsDir() {
+			// the child is the directory of a single layer
+			merged, ok := s.Get(p)
+			if !ok {
+				continue
+			}
+
+			if err := s.walkDir(p, merged, fn); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		err := fn(p, child)
+		if err == ErrSkipDir {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *layered) Close() error {
 	// TODO: aggregate all the errors
-	for _, store := range s {
+	for _, store := range s.layers {
 		store.Close()
 	}
 
@@ -85,19 +333,23 @@ func (s stores) Close() error {
 //
 //	store = Layered(s1, s2)
 //	store.Get(p) will search s2 first, then s1
+//
+// Upper stores can delete entries from the lower stores with OCI style whiteouts. A
+// `.wh.<name>` entry hides `<name>` from all the stores below, and a directory that
+// contains a `.wh..wh..opq` entry hides the same directory content of the stores below.
+// Whiteout entries are never visible, even with a single store. The whiteouts of each
+// directory are read once and the ones of the last MarksCacheSize directories are kept
+// in memory. The layered store is a Walker that walks over the merged directories.
 func Layered(store ...Store) Store {
-	if len(store) == 1 {
-		return store[0]
-	}
-
-	var s stores
+	marks, _ := lru.New(MarksCacheSize)
+	s := &layered{marks: marks}
 	//reverse order
 	for i := len(store) - 1; i >= 0; i-- {
 		if store[i] == nil {
 			continue
 		}
 
-		s = append(s, store[i])
+		s.layers = append(s.layers, store[i])
 	}
 	return s
 }
@@ -0,0 +1,317 @@
+package meta
+
+import (
+	"path"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeMeta struct {
+	name     string
+	layer    string
+	dir      bool
+	children []Meta
+}
+
+func (m *fakeMeta) String() string      { return m.name }
+func (m *fakeMeta) ID() string          { return m.layer }
+func (m *fakeMeta) Name() string        { return m.name }
+func (m *fakeMeta) IsDir() bool         { return m.dir }
+func (m *fakeMeta) Blocks() []BlockInfo { return nil }
+func (m *fakeMeta) Children() []Meta    { return m.children }
+func (m *fakeMeta) Info() Info {
+	if m.dir {
+		return Info{Type: DirType}
+	}
+	return Info{Type: RegularType}
+}
+
+type fakeStore map[string]*fakeMeta
+
+func (s fakeStore) Get(p string) (Meta, bool) {
+	m, ok := s[p]
+	if !ok {
+		return nil, false
+	}
+	return m, true
+}
+
+func (s fakeStore) Close() error { return nil }
+
+// newFakeStore creates a store with the given entries, entries that
+// end with a / are directories. Parents are created automatically
+func newFakeStore(layer string, entries ...string) fakeStore {
+	s := fakeStore{"": &fakeMeta{layer: layer, dir: true}}
+
+	var add func(p string, dir bool) *fakeMeta
+	add = func(p string, dir bool) *fakeMeta {
+		if m, ok := s[p]; ok {
+			return m
+		}
+
+		parent := path.Dir(p)
+		if parent == "." {
+			parent = ""
+		}
+
+		m := &fakeMeta{name: path.Base(p), layer: layer, dir: dir}
+		up := add(parent, true)
+		up.children = append(up.children, m)
+		s[p] = m
+		return m
+	}
+
+	for _, entry := range entries {
+		add(strings.TrimSuffix(entry, "/"), strings.HasSuffix(entry, "/"))
+	}
+
+	return s
+}
+
+func names(m Meta) []string {
+	var names []string
+	for _, child := range m.Children() {
+		names = append(names, child.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestLayeredWhiteout(t *testing.T) {
+	base := newFakeStore("base",
+		"bin/sh",
+		"bin/ls",
+		"etc/passwd",
+		"etc/shadow",
+		"opt/app/lib/a.so",
+		"opt/app/config",
+		"var/cache/data",
+	)
+
+	middle := newFakeStore("middle",
+		"bin/.wh.ls",
+		"etc/group",
+		"opt/app/.wh..wh..opq",
+		"opt/app/new",
+		"var/.wh.cache",
+	)
+
+	top := newFakeStore("top",
+		"bin/ls",
+		"etc/.wh.passwd",
+		"var/cache/",
+	)
+
+	store := Layered(base, middle, top)
+
+	cases := []struct {
+		path  string
+		found bool
+		layer string
+	}{
+		{"bin/sh", true, "base"},
+		// deleted by middle, then added back by top
+		{"bin/ls", true, "top"},
+		{"bin/.wh.ls", false, ""},
+		{"etc/passwd", false, ""},
+		{"etc/shadow", true, "base"},
+		{"etc/group", true, "middle"},
+		// opaque directory in middle
+		{"opt/app/config", false, ""},
+		{"opt/app/lib", false, ""},
+		{"opt/app/lib/a.so", false, ""},
+		{"opt/app/new", true, "middle"},
+		{"opt/app/.wh..wh..opq", false, ""},
+		// whole directory deleted by middle, then recreated by top
+		{"var/cache", true, "top"},
+		{"var/cache/data", false, ""},
+	}
+
+	for _, c := range cases {
+		m, ok := store.Get(c.path)
+		if ok := assert.Equal(t, c.found, ok, c.path); !ok || !c.found {
+			continue
+		}
+
+		if !m.IsDir() {
+			assert.Equal(t, c.layer, m.ID(), c.path)
+		}
+	}
+
+	bin, _ := store.Get("bin")
+	assert.Equal(t, []string{"ls", "sh"}, names(bin))
+
+	etc, _ := store.Get("etc")
+	assert.Equal(t, []string{"group", "shadow"}, names(etc))
+
+	app, _ := store.Get("opt/app")
+	assert.Equal(t, []string{"new"}, names(app))
+
+	cache, _ := store.Get("var/cache")
+	assert.Empty(t, names(cache))
+}
+
+func TestLayeredOpaqueRoot(t *testing.T) {
+	base := newFakeStore("base", "a", "b/c")
+	top := newFakeStore("top", ".wh..wh..opq", "d")
+
+	store := Layered(base, top)
+
+	_, ok := store.Get("a")
+	assert.False(t, ok)
+	_, ok = store.Get("b/c")
+	assert.False(t, ok)
+
+	root, ok := store.Get("")
+	if ok := assert.True(t, ok); !ok {
+		t.Fatal()
+	}
+	assert.Equal(t, []string{"d"}, names(root))
+}
+
+func TestLayeredSingle(t *testing.T) {
+	base := newFakeStore("base", "bin/sh", "bin/.wh.ls", ".wh..wh..opq")
+	store := Layered(base)
+
+	_, ok := store.Get("bin/.wh.ls")
+	assert.False(t, ok)
+
+	bin, ok := store.Get("bin")
+	if ok := assert.True(t, ok); !ok {
+		t.Fatal()
+	}
+	assert.Equal(t, []string{"sh"}, names(bin))
+
+	root, _ := store.Get("")
+	assert.Equal(t, []string{"bin"}, names(root))
+
+	sh, _ := store.Get("bin/sh")
+	assert.Equal(t, base, Layer(sh))
+}
+
+// countingStore counts the Get calls
+type countingStore struct {
+	fakeStore
+	gets int
+}
+
+func (s *countingStore) Get(p string) (Meta, bool) {
+	s.gets++
+	return s.fakeStore.Get(p)
+}
+
+func TestLayeredMarksCache(t *testing.T) {
+	base := newFakeStore("base", "usr/share/doc/a", "usr/share/doc/b", "usr/share/doc/c")
+	top := &countingStore{fakeStore: newFakeStore("top", "usr/share/doc/.wh.b", "usr/share/x")}
+	store := Layered(base, top)
+
+	_, ok := store.Get("usr/share/doc/a")
+	assert.True(t, ok)
+	first := top.gets
+
+	// the whiteouts of the top directories are only read once
+	_, ok = store.Get("usr/share/doc/c")
+	assert.True(t, ok)
+	_, ok = store.Get("usr/share/doc/b")
+	assert.False(t, ok)
+	assert.Equal(t, 2, top.gets-first)
+}
+
+func TestLayeredFileHidesDir(t *testing.T) {
+	base := newFakeStore("base", "a/b")
+	top := newFakeStore("top", "a")
+
+	store := Layered(base, top)
+
+	m, ok := store.Get("a")
+	if ok := assert.True(t, ok); !ok {
+		t.Fatal()
+	}
+	assert.False(t, m.IsDir())
+
+	_, ok = store.Get("a/b")
+	assert.False(t, ok)
+}
+
+func TestLayer(t *testing.T) {
+	lower := newFakeStore("lower", "bin/a", "bin/b", "etc/")
+	upper := newFakeStore("upper", "bin/b", "usr/lib/c")
+	top := newFakeStore("top", "x")
+
+	get := func(store Store, p string) Meta {
+		m, ok := store.Get(p)
+		if ok := assert.True(t, ok, p); !ok {
+			t.Fatal()
+		}
+		return m
+	}
+
+	assert.Nil(t, Layer(get(lower, "bin/a")))
+
+	store := Layered(lower, upper)
+	assert.Equal(t, lower, Layer(get(store, "bin/a")))
+	assert.Equal(t, upper, Layer(get(store, "bin/b")))
+	assert.Equal(t, upper, Layer(get(store, "bin")))
+	assert.Equal(t, lower, Layer(get(store, "etc")))
+
+	for _, child := range get(store, "bin").Children() {
+		switch child.Name() {
+		case "a":
+			assert.Equal(t, lower, Layer(child))
+		case "b":
+			assert.Equal(t, upper, Layer(child))
+		}
+	}
+
+	// children of the children are bound to the same layer
+	for _, child := range get(store, "").Children() {
+		if child.Name() != "usr" {
+			continue
+		}
+
+		lib := child.Children()[0]
+		assert.Equal(t, upper, Layer(lib))
+		assert.Equal(t, upper, Layer(lib.Children()[0]))
+	}
+
+	// nested layered stores keep the innermost layer
+	nested := Layered(store, top)
+	assert.Equal(t, lower, Layer(get(nested, "bin/a")))
+	assert.Equal(t, upper, Layer(get(nested, "bin/b")))
+	assert.Equal(t, top, Layer(get(nested, "x")))
+}
+
+func TestLayeredWalk(t *testing.T) {
+	base := newFakeStore("base", "a/b/c/deep", "a/b/old", "a/skip/x", "z")
+	top := newFakeStore("top", "a/b/.wh.old", "a/new", ".wh.z")
+
+	store, ok := Layered(base, top).(Walker)
+	if ok := assert.True(t, ok); !ok {
+		t.Fatal()
+	}
+
+	var paths []string
+	err := store.Walk("", func(p string, m Meta) error {
+		paths = append(paths, p)
+		if p == "a/skip" {
+			return ErrSkipDir
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+
+	sort.Strings(paths)
+	assert.Equal(t, []string{"", "a", "a/b", "a/b/c", "a/b/c/deep", "a/new", "a/skip"}, paths)
+
+	// lower layer files keep their layer
+	err = store.Walk("a/b/c/deep", func(p string, m Meta) error {
+		assert.Equal(t, "base", m.ID())
+		return nil
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, ErrNotFound, store.Walk("z", func(string, Meta) error { return nil }))
+}
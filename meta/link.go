@@ -52,12 +52,14 @@ func (l *Link) Children() []Meta {
 func (l *Link) Info() Info {
 	l.iOnce.Do(func() {
 		target, _ := l.link.Target()
+		attrs, _ := l.Xattrs()
 		l.info = Info{
 			CreationTime:     l.CreationTime(),
 			ModificationTime: l.ModificationTime(),
 			Size:             l.Size(),
 			Type:             LinkType,
 			Access:           l.access,
+			XAttrs:           xattrs(attrs),
 			LinkTarget:       target,
 		}
 	})
@@ -6,6 +6,7 @@ import (
 	"syscall"
 
 	"github.com/op/go-logging"
+	np "github.com/threefoldtech/0-fs/cap.np"
 )
 
 var (
@@ -78,6 +79,30 @@ type Info struct {
 
 	//Special
 	SpecialData string
+
+	//XAttrs extended attributes, nil if the flist doesn't define any
+	XAttrs map[string][]byte
+}
+
+// xattrs converts the capnp list of extended attributes into a map
+func xattrs(list np.XAttr_List) map[string][]byte {
+	if list.Len() == 0 {
+		return nil
+	}
+
+	attrs := make(map[string][]byte, list.Len())
+	for i := 0; i < list.Len(); i++ {
+		attr := list.At(i)
+		name, err := attr.Name()
+		if err != nil || len(name) == 0 {
+			continue
+		}
+
+		value, _ := attr.Value()
+		attrs[name] = value
+	}
+
+	return attrs
 }
 
 // BlockInfo is the information needed to retrieve and decrypt a data block
@@ -0,0 +1,24 @@
+package meta
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	lruRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "zerofs",
+		Subsystem: "meta",
+		Name:      "lru_requests_total",
+		Help:      "Number of lookups in the meta store LRU caches by cache (dir, acl or marks) and result (hit or miss)",
+	}, []string{"cache", "result"})
+)
+
+func lruResult(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+
+	lruRequests.WithLabelValues(cache, result).Inc()
+}
@@ -71,6 +71,7 @@ func (s *Special) getInfo() Info {
 	}
 
 	data, _ := s.special.Data()
+	attrs, _ := s.Xattrs()
 	return Info{
 		CreationTime:     s.CreationTime(),
 		ModificationTime: s.ModificationTime(),
@@ -78,5 +79,6 @@ func (s *Special) getInfo() Info {
 		Type:             t,
 		Access:           s.access,
 		SpecialData:      string(data),
+		XAttrs:           xattrs(attrs),
 	}
 }
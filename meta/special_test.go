@@ -0,0 +1,133 @@
+package meta
+
+import (
+	"bytes"
+	"database/sql"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	np "github.com/threefoldtech/0-fs/cap.np"
+	capnp "zombiezen.com/go/capnproto2"
+)
+
+func testEncode(t *testing.T, msg *capnp.Message) []byte {
+	var buf bytes.Buffer
+	if err := capnp.NewEncoder(&buf).Encode(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+// newTestStore creates a meta store from the given raw db entries
+func newTestStore(t *testing.T, entries map[string][]byte) Store {
+	root, err := os.MkdirTemp("", "meta-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(root) })
+
+	db, err := sql.Open("sqlite3", path.Join(root, SQLiteDBName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("create table entries (key varchar(64) primary key, value blob)"); err != nil {
+		t.Fatal(err)
+	}
+
+	for key, value := range entries {
+		if _, err := db.Exec("insert into entries (key, value) values (?, ?)", key, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store, err := NewStore(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	return store
+}
+
+func TestSpecialChildren(t *testing.T) {
+	hasher := &sqlStore{}
+	rootKey, _ := hasher.hash("")
+
+	aciMsg, seg, _ := capnp.NewMessage(capnp.SingleSegment(nil))
+	aci, _ := np.NewRootACI(seg)
+	aci.SetUid(0)
+	aci.SetGid(0)
+	aci.SetMode(0666)
+
+	msg, seg, _ := capnp.NewMessage(capnp.SingleSegment(nil))
+	dir, _ := np.NewRootDir(seg)
+	dir.SetName("")
+	dir.SetLocation("")
+	dir.SetAclkey("aci")
+
+	specials := []struct {
+		name string
+		typ  np.Special_Type
+		data string
+		node NodeType
+	}{
+		{"socket", np.Special_Type_socket, "", SocketType},
+		{"block", np.Special_Type_block, "8,0", BlockDeviceType},
+		{"null", np.Special_Type_chardev, "1,3", CharDeviceType},
+		{"fifo", np.Special_Type_fifopipe, "", FIFOType},
+		{"unknown", np.Special_Type_unknown, "", UnknownType},
+	}
+
+	contents, _ := dir.NewContents(int32(len(specials)))
+	for i, s := range specials {
+		inode := contents.At(i)
+		inode.SetName(s.name)
+		inode.SetAclkey("aci")
+		inode.SetModificationTime(uint32(i))
+		special, _ := inode.Attributes().NewSpecial()
+		special.SetType(s.typ)
+		special.SetData([]byte(s.data))
+	}
+
+	store := newTestStore(t, map[string][]byte{
+		rootKey: testEncode(t, msg),
+		"aci":   testEncode(t, aciMsg),
+	})
+
+	root, ok := store.Get("")
+	if ok := assert.True(t, ok); !ok {
+		t.Fatal()
+	}
+
+	children := root.Children()
+	if ok := assert.Len(t, children, len(specials)); !ok {
+		t.Fatal()
+	}
+
+	for i, s := range specials {
+		child := children[i]
+		assert.Equal(t, s.name, child.Name())
+		assert.False(t, child.IsDir())
+		assert.Empty(t, child.ID())
+		assert.Empty(t, child.Blocks())
+
+		info := child.Info()
+		assert.Equal(t, s.node, info.Type)
+		assert.Equal(t, s.data, info.SpecialData)
+		assert.Equal(t, uint32(i), info.ModificationTime)
+		assert.Equal(t, Access{Mode: 0666}, info.Access)
+	}
+
+	null, ok := store.Get("null")
+	if ok := assert.True(t, ok); !ok {
+		t.Fatal()
+	}
+
+	assert.Equal(t, CharDeviceType, null.Info().Type)
+	assert.Equal(t, "1,3", null.Info().SpecialData)
+}
@@ -99,6 +99,11 @@ func (s *sqlStore) Close() error {
 }
 
 func (s *sqlStore) hash(path string) (string, error) {
+	return hash(path)
+}
+
+// hash returns the key of the directory with the given path in the flist db
+func hash(path string) (string, error) {
 	hasher, _ := blake2b.New(16, nil)
 	_, err := io.WriteString(hasher, path)
 	if err != nil {
@@ -110,8 +115,10 @@ func (s *sqlStore) hash(path string) (string, error) {
 
 // getACI gets aci object with key from db
 func (s *sqlStore) getACI(key string) (*np.ACI, error) {
-	if aci, ok := s.acl.Get(key); ok {
-		return aci.(*np.ACI), nil
+	cached, ok := s.acl.Get(key)
+	lruResult("acl", ok)
+	if ok {
+		return cached.(*np.ACI), nil
 	}
 
 	row := s.stmt.QueryRow(key)
@@ -219,8 +226,19 @@ func (s *sqlStore) getDir(path string) (*Dir, error) {
 	return s.getDirWithHash(hash)
 }
 
-// getDir gets dir entry from db
+// getDirWithHash gets dir entry from db, a dir with no ACI gets the default access
 func (s *sqlStore) getDirWithHash(hash string) (*Dir, error) {
+	dir, err := s.readDir(hash)
+	if err == errNoACI {
+		return dir, nil
+	}
+
+	return dir, err
+}
+
+// readDir reads dir entry from db, if the dir has no ACI, it's returned
+// with the default access and errNoACI
+func (s *sqlStore) readDir(hash string) (*Dir, error) {
 	row := s.stmt.QueryRow(hash)
 	var data []byte
 	if err := row.Scan(&data); err != nil {
@@ -252,11 +270,13 @@ func (s *sqlStore) getDirWithHash(hash string) (*Dir, error) {
 		return nil, err
 	}
 
-	return &Dir{Dir: dir, store: s, access: access}, nil
+	return &Dir{Dir: dir, store: s, access: access}, err
 }
 
 func (s *sqlStore) get(p string) (Meta, error) {
-	if m, ok := s.cache.Get(p); ok {
+	m, ok := s.cache.Get(p)
+	lruResult("dir", ok)
+	if ok {
 		return m.(Meta), nil
 	}
 
@@ -49,3 +49,57 @@ func Unpack(r io.Reader, dest string) error {
 
 	return err
 }
+
+// Pack creates a tgz (flist) archive from the files in the src folder (like the
+// flist db and router.yaml) and writes it to w. Sub directories are ignored
+func Pack(src string, w io.Writer) error {
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(w)
+	tw := tar.NewWriter(zw)
+
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+
+		if err := packFile(tw, path.Join(src, entry.Name())); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+func packFile(tw *tar.Writer, name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+
+	_, err = io.Copy(tw, f)
+	return err
+}
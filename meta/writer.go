@@ -0,0 +1,522 @@
+package meta
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	np "github.com/threefoldtech/0-fs/cap.np"
+	capnp "zombiezen.com/go/capnproto2"
+)
+
+var (
+	// DefaultDirAccess is used for directories that are created implicitly
+	// because they are the parent of an added entry
+	DefaultDirAccess = Access{
+		Mode: 0755,
+	}
+)
+
+type entry struct {
+	name   string
+	info   Info
+	blocks []BlockInfo
+}
+
+type dirEntry struct {
+	info    Info
+	entries map[string]*entry
+}
+
+// Writer creates the meta store (flistdb.sqlite3) of a new flist. Entries
+// are kept in memory and are only written to the db on Close, so the memory
+// used grows with the number of entries (and blocks) of the flist. The flist
+// format has no hard links, an entry added under several paths is stored once
+// per path
+type Writer struct {
+	db   *sql.DB
+	dirs map[string]*dirEntry
+	acis map[string]Access
+}
+
+// NewWriter creates a new empty flist db under root, root is created
+// if it doesn't exist
+func NewWriter(root string) (*Writer, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("sqlite3", path.Join(root, SQLiteDBName))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := db.Exec("create table if not exists entries (key varchar(64) primary key, value blob)"); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	w := &Writer{
+		db:   db,
+		dirs: make(map[string]*dirEntry),
+		acis: make(map[string]Access),
+	}
+
+	w.dirs[""] = &dirEntry{
+		info:    Info{Type: DirType, Access: DefaultDirAccess},
+		entries: make(map[string]*entry),
+	}
+
+	return w, nil
+}
+
+func clean(p string) string {
+	p = strings.Trim(path.Clean("/"+p), "/")
+	if p == "." {
+		return ""
+	}
+
+	return p
+}
+
+func parent(p string) string {
+	return clean(path.Dir(p))
+}
+
+// dir gets the directory with path p, creating it (and its parents) if needed
+func (w *Writer) dir(p string) (*dirEntry, error) {
+	if dir, ok := w.dirs[p]; ok {
+		return dir, nil
+	}
+
+	up, err := w.dir(parent(p))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := up.entries[path.Base(p)]; ok {
+		return nil, fmt.Errorf("'%s' exists and is not a directory", p)
+	}
+
+	dir := &dirEntry{
+		info:    Info{Type: DirType, Access: DefaultDirAccess},
+		entries: make(map[string]*entry),
+	}
+
+	w.dirs[p] = dir
+	return dir, nil
+}
+
+// Add adds an entry to the flist at path p with the given info. The blocks
+// are only used by regular files. Missing parent directories are created
+// with DefaultDirAccess. Adding an entry that already exists replaces it,
+// unless the old and the new entries are not of the same kind (dir or not)
+func (w *Writer) Add(p string, info Info, blocks []BlockInfo) error {
+	p = clean(p)
+
+	switch info.Type {
+	case DirType:
+		if p != "" {
+			if up, ok := w.dirs[parent(p)]; ok {
+				if _, ok := up.entries[path.Base(p)]; ok {
+					return fmt.Errorf("'%s' exists and is not a directory", p)
+				}
+			}
+		}
+
+		dir, err := w.dir(p)
+		if err != nil {
+			return err
+		}
+
+		dir.info = info
+		return nil
+	case RegularType, LinkType, BlockDeviceType, CharDeviceType, SocketType, FIFOType:
+	default:
+		return fmt.Errorf("'%s' has unsupported type: %s", p, info.Type)
+	}
+
+	if p == "" {
+		return fmt.Errorf("root must be a directory")
+	}
+
+	if _, ok := w.dirs[p]; ok {
+		return fmt.Errorf("'%s' exists and is a directory", p)
+	}
+
+	dir, err := w.dir(parent(p))
+	if err != nil {
+		return err
+	}
+
+	name := path.Base(p)
+	dir.entries[name] = &entry{name: name, info: info, blocks: blocks}
+
+	return nil
+}
+
+// Get returns the info and blocks of the entry at path p if it exists
+func (w *Writer) Get(p string) (Info, []BlockInfo, bool) {
+	p = clean(p)
+	if dir, ok := w.dirs[p]; ok {
+		return dir.info, nil, true
+	}
+
+	if p == "" {
+		return Info{}, nil, false
+	}
+
+	dir, ok := w.dirs[parent(p)]
+	if !ok {
+		return Info{}, nil, false
+	}
+
+	entry, ok := dir.entries[path.Base(p)]
+	if !ok {
+		return Info{}, nil, false
+	}
+
+	return entry.info, entry.blocks, true
+}
+
+// Remove removes the entry at path p, a directory is removed with all its
+// content. Removing an entry that doesn't exist is a no-op. The root can't
+// be removed, use Clear instead
+func (w *Writer) Remove(p string) {
+	p = clean(p)
+	if p == "" {
+		return
+	}
+
+	if _, ok := w.dirs[p]; ok {
+		w.Clear(p)
+		delete(w.dirs, p)
+		return
+	}
+
+	if dir, ok := w.dirs[parent(p)]; ok {
+		delete(dir.entries, path.Base(p))
+	}
+}
+
+// Clear removes all the content of the directory p, but keeps p itself
+func (w *Writer) Clear(p string) {
+	p = clean(p)
+	dir, ok := w.dirs[p]
+	if !ok {
+		return
+	}
+
+	dir.entries = make(map[string]*entry)
+
+	prefix := p + "/"
+	for sub := range w.dirs {
+		if sub != "" && (p == "" || strings.HasPrefix(sub, prefix)) {
+			delete(w.dirs, sub)
+		}
+	}
+}
+
+// aci returns the key of the ACI entry for this access
+func (w *Writer) aci(access Access) (string, error) {
+	key, err := hash(fmt.Sprintf("aci:%d:%d:%o", access.UID, access.GID, access.Mode))
+	if err != nil {
+		return "", err
+	}
+
+	w.acis[key] = access
+	return key, nil
+}
+
+func writeXAttrs(attrs map[string][]byte, create func(int32) (np.XAttr_List, error)) error {
+	if len(attrs) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	list, err := create(int32(len(names)))
+	if err != nil {
+		return err
+	}
+
+	for i, name := range names {
+		attr := list.At(i)
+		if err := attr.SetName(name); err != nil {
+			return err
+		}
+
+		if err := attr.SetValue(attrs[name]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *Writer) setInode(inode np.Inode, name string, info Info, blocks []BlockInfo) error {
+	key, err := w.aci(info.Access)
+	if err != nil {
+		return err
+	}
+
+	if err := inode.SetName(name); err != nil {
+		return err
+	}
+
+	if err := inode.SetAclkey(key); err != nil {
+		return err
+	}
+
+	inode.SetSize(info.Size)
+	inode.SetModificationTime(info.ModificationTime)
+	inode.SetCreationTime(info.CreationTime)
+
+	if err := writeXAttrs(info.XAttrs, inode.NewXattrs); err != nil {
+		return err
+	}
+
+	attributes := inode.Attributes()
+	switch info.Type {
+	case RegularType:
+		file, err := attributes.NewFile()
+		if err != nil {
+			return err
+		}
+
+		file.SetBlockSize(uint16(info.FileBlockSize / 4096))
+		list, err := file.NewBlocks(int32(len(blocks)))
+		if err != nil {
+			return err
+		}
+
+		for i, block := range blocks {
+			if err := list.At(i).SetHash(block.Key); err != nil {
+				return err
+			}
+
+			if err := list.At(i).SetKey(block.Decipher); err != nil {
+				return err
+			}
+		}
+	case LinkType:
+		link, err := attributes.NewLink()
+		if err != nil {
+			return err
+		}
+
+		return link.SetTarget(info.LinkTarget)
+	default:
+		special, err := attributes.NewSpecial()
+		if err != nil {
+			return err
+		}
+
+		switch info.Type {
+		case SocketType:
+			special.SetType(np.Special_Type_socket)
+		case BlockDeviceType:
+			special.SetType(np.Special_Type_block)
+		case CharDeviceType:
+			special.SetType(np.Special_Type_chardev)
+		case FIFOType:
+			special.SetType(np.Special_Type_fifopipe)
+		}
+
+		return special.SetData([]byte(info.SpecialData))
+	}
+
+	return nil
+}
+
+func (w *Writer) encodeDir(p string, dir *dirEntry, subdirs []string) ([]byte, error) {
+	msg, seg, err := capnp.NewMessage(capnp.SingleSegment(nil))
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := np.NewRootDir(seg)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := w.aci(dir.info.Access)
+	if err != nil {
+		return nil, err
+	}
+
+	name := ""
+	if p != "" {
+		name = path.Base(p)
+		up, err := hash(parent(p))
+		if err != nil {
+			return nil, err
+		}
+
+		if err := root.SetParent(up); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := root.SetName(name); err != nil {
+		return nil, err
+	}
+
+	if err := root.SetLocation(p); err != nil {
+		return nil, err
+	}
+
+	if err := root.SetAclkey(key); err != nil {
+		return nil, err
+	}
+
+	root.SetSize(4096)
+	root.SetModificationTime(dir.info.ModificationTime)
+	root.SetCreationTime(dir.info.CreationTime)
+
+	if err := writeXAttrs(dir.info.XAttrs, root.NewXattrs); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(dir.entries))
+	for name := range dir.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	contents, err := root.NewContents(int32(len(subdirs) + len(names)))
+	if err != nil {
+		return nil, err
+	}
+
+	for i, sub := range subdirs {
+		inode := contents.At(i)
+		if err := w.setInode(inode, path.Base(sub), w.dirs[sub].info, nil); err != nil {
+			return nil, err
+		}
+
+		inode.SetSize(4096)
+		subdir, err := inode.Attributes().NewDir()
+		if err != nil {
+			return nil, err
+		}
+
+		subkey, err := hash(sub)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := subdir.SetKey(subkey); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, name := range names {
+		child := dir.entries[name]
+		if err := w.setInode(contents.At(len(subdirs)+i), name, child.info, child.blocks); err != nil {
+			return nil, err
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := capnp.NewEncoder(&buf).Encode(msg); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func encodeACI(access Access) ([]byte, error) {
+	msg, seg, err := capnp.NewMessage(capnp.SingleSegment(nil))
+	if err != nil {
+		return nil, err
+	}
+
+	aci, err := np.NewRootACI(seg)
+	if err != nil {
+		return nil, err
+	}
+
+	aci.SetUid(int64(access.UID))
+	aci.SetGid(int64(access.GID))
+	aci.SetMode(uint16(access.Mode & 07777))
+
+	var buf bytes.Buffer
+	if err := capnp.NewEncoder(&buf).Encode(msg); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (w *Writer) write(tx *sql.Tx) error {
+	stmt, err := tx.Prepare("insert or replace into entries (key, value) values (?, ?)")
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	subdirs := make(map[string][]string)
+	for p := range w.dirs {
+		if p != "" {
+			up := parent(p)
+			subdirs[up] = append(subdirs[up], p)
+		}
+	}
+
+	for p, dir := range w.dirs {
+		sort.Strings(subdirs[p])
+		data, err := w.encodeDir(p, dir, subdirs[p])
+		if err != nil {
+			return err
+		}
+
+		key, err := hash(p)
+		if err != nil {
+			return err
+		}
+
+		if _, err := stmt.Exec(key, data); err != nil {
+			return err
+		}
+	}
+
+	for key, access := range w.acis {
+		data, err := encodeACI(access)
+		if err != nil {
+			return err
+		}
+
+		if _, err := stmt.Exec(key, data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close writes all the entries to the db and closes it
+func (w *Writer) Close() error {
+	defer w.db.Close()
+
+	tx, err := w.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := w.write(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
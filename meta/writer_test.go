@@ -0,0 +1,159 @@
+package meta
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriter(t *testing.T) {
+	root := t.TempDir()
+	writer, err := NewWriter(root)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	blocks := []BlockInfo{
+		{Key: []byte("key-1"), Decipher: []byte("decipher-1")},
+		{Key: []byte("key-2"), Decipher: []byte("decipher-2")},
+	}
+
+	entries := map[string]Info{
+		"": {
+			Type:   DirType,
+			Access: Access{UID: 0, GID: 0, Mode: 0755},
+		},
+		"etc": {
+			Type:             DirType,
+			Access:           Access{UID: 0, GID: 0, Mode: 0700},
+			ModificationTime: 1000,
+			CreationTime:     2000,
+		},
+		"etc/passwd": {
+			Type:             RegularType,
+			Access:           Access{UID: 0, GID: 0, Mode: 0644},
+			Size:             1024,
+			FileBlockSize:    512 * 1024,
+			ModificationTime: 1001,
+			CreationTime:     2001,
+			XAttrs:           map[string][]byte{"user.comment": []byte("users")},
+		},
+		"etc/link": {
+			Type:       LinkType,
+			Access:     Access{UID: 1000, GID: 1000, Mode: 0777},
+			LinkTarget: "passwd",
+		},
+		"dev/null": {
+			Type:        CharDeviceType,
+			Access:      Access{UID: 0, GID: 0, Mode: 0666},
+			SpecialData: "1,3",
+		},
+	}
+
+	for p, info := range entries {
+		var b []BlockInfo
+		if info.Type == RegularType {
+			b = blocks
+		}
+		if ok := assert.NoError(t, writer.Add(p, info, b)); !ok {
+			t.Fatal()
+		}
+	}
+
+	// a file can't replace a directory
+	assert.Error(t, writer.Add("etc", Info{Type: RegularType}, nil))
+	// a directory can't replace a file
+	assert.Error(t, writer.Add("etc/passwd", Info{Type: DirType}, nil))
+
+	if ok := assert.NoError(t, writer.Close()); !ok {
+		t.Fatal()
+	}
+
+	var archive bytes.Buffer
+	if ok := assert.NoError(t, Pack(root, &archive)); !ok {
+		t.Fatal()
+	}
+
+	unpacked := t.TempDir()
+	if ok := assert.NoError(t, Unpack(&archive, unpacked)); !ok {
+		t.Fatal()
+	}
+
+	store, err := NewStore(unpacked)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	defer store.Close()
+
+	for p, expected := range entries {
+		m, ok := store.Get(p)
+		if ok := assert.True(t, ok, p); !ok {
+			continue
+		}
+
+		info := m.Info()
+		if expected.Type == DirType {
+			expected.Size = 4096
+		}
+		assert.Equal(t, expected, info, p)
+	}
+
+	// dev is created implicitly
+	dev, ok := store.Get("dev")
+	if ok := assert.True(t, ok); !ok {
+		t.Fatal()
+	}
+	assert.Equal(t, DefaultDirAccess, dev.Info().Access)
+
+	passwd, _ := store.Get("etc/passwd")
+	assert.Equal(t, blocks, passwd.Blocks())
+
+	top, _ := store.Get("")
+	var names []string
+	for _, child := range top.Children() {
+		names = append(names, child.Name())
+	}
+	assert.Equal(t, []string{"dev", "etc"}, names)
+}
+
+func TestWriterRemove(t *testing.T) {
+	writer, err := NewWriter(t.TempDir())
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	defer writer.Close()
+
+	for _, p := range []string{"a/b/c", "a/d", "e"} {
+		if ok := assert.NoError(t, writer.Add(p, Info{Type: RegularType}, nil)); !ok {
+			t.Fatal()
+		}
+	}
+
+	_, _, ok := writer.Get("a/b/c")
+	assert.True(t, ok)
+
+	writer.Remove("a/b")
+	_, _, ok = writer.Get("a/b")
+	assert.False(t, ok)
+	_, _, ok = writer.Get("a/b/c")
+	assert.False(t, ok)
+	_, _, ok = writer.Get("a/d")
+	assert.True(t, ok)
+
+	// a file can replace a directory once it's removed
+	assert.NoError(t, writer.Add("a/b", Info{Type: RegularType}, nil))
+
+	writer.Clear("a")
+	info, _, ok := writer.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, DirType, info.Type)
+	_, _, ok = writer.Get("a/d")
+	assert.False(t, ok)
+
+	writer.Clear("")
+	_, _, ok = writer.Get("a")
+	assert.False(t, ok)
+	_, _, ok = writer.Get("e")
+	assert.False(t, ok)
+}
@@ -0,0 +1,80 @@
+package meta
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	np "github.com/threefoldtech/0-fs/cap.np"
+	capnp "zombiezen.com/go/capnproto2"
+)
+
+func setXAttrs(t *testing.T, list np.XAttr_List, attrs map[string]string) {
+	i := 0
+	for name, value := range attrs {
+		if err := list.At(i).SetName(name); err != nil {
+			t.Fatal(err)
+		}
+		if err := list.At(i).SetValue([]byte(value)); err != nil {
+			t.Fatal(err)
+		}
+		i++
+	}
+}
+
+func TestXAttrs(t *testing.T) {
+	hasher := &sqlStore{}
+	rootKey, _ := hasher.hash("")
+
+	msg, seg, _ := capnp.NewMessage(capnp.SingleSegment(nil))
+	dir, _ := np.NewRootDir(seg)
+	dir.SetName("")
+	dir.SetLocation("")
+	dirAttrs, _ := dir.NewXattrs(1)
+	setXAttrs(t, dirAttrs, map[string]string{"security.selinux": "system_u:object_r:root_t:s0"})
+
+	contents, _ := dir.NewContents(2)
+
+	ping := contents.At(0)
+	ping.SetName("ping")
+	ping.Attributes().NewFile()
+	pingAttrs, _ := ping.NewXattrs(2)
+	setXAttrs(t, pingAttrs, map[string]string{
+		"security.capability": "\x01\x00\x00\x02",
+		"user.comment":        "ping",
+	})
+
+	// an entry without extended attributes (like old flists)
+	plain := contents.At(1)
+	plain.SetName("plain")
+	plain.Attributes().NewFile()
+
+	store := newTestStore(t, map[string][]byte{
+		rootKey: testEncode(t, msg),
+	})
+
+	root, ok := store.Get("")
+	if ok := assert.True(t, ok); !ok {
+		t.Fatal()
+	}
+
+	assert.Equal(t, map[string][]byte{
+		"security.selinux": []byte("system_u:object_r:root_t:s0"),
+	}, root.Info().XAttrs)
+
+	m, ok := store.Get("ping")
+	if ok := assert.True(t, ok); !ok {
+		t.Fatal()
+	}
+
+	assert.Equal(t, map[string][]byte{
+		"security.capability": []byte("\x01\x00\x00\x02"),
+		"user.comment":        []byte("ping"),
+	}, m.Info().XAttrs)
+
+	m, ok = store.Get("plain")
+	if ok := assert.True(t, ok); !ok {
+		t.Fatal()
+	}
+
+	assert.Nil(t, m.Info().XAttrs)
+}
@@ -0,0 +1,377 @@
+package rofs
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/hanwen/go-fuse/v2/fuse/nodefs"
+	"github.com/threefoldtech/0-fs/meta"
+	"github.com/threefoldtech/0-fs/storage/router"
+	"golang.org/x/sys/unix"
+)
+
+// CacheMode defines how downloaded data is kept in the cache
+type CacheMode int
+
+// CacheMode values
+const (
+	// FileCache keeps each file in a (sparse) cache file named after the file ID
+	FileCache CacheMode = iota
+	// BlockCache keeps the decrypted blocks in cache files named after the block
+	// key, files are assembled from their blocks when read. Blocks are shared
+	// between all files, flists and mounts that use the same cache directory
+	BlockCache
+)
+
+const (
+	blocksDir = "blocks"
+)
+
+// String implements fmt.Stringer interface
+func (m CacheMode) String() string {
+	switch m {
+	case FileCache:
+		return "file"
+	case BlockCache:
+		return "block"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseCacheMode parses the cache mode name as returned by CacheMode.String
+func ParseCacheMode(s string) (CacheMode, error) {
+	for _, mode := range []CacheMode{FileCache, BlockCache} {
+		if strings.EqualFold(s, mode.String()) {
+			return mode, nil
+		}
+	}
+
+	return FileCache, fmt.Errorf("unknown cache mode '%s'", s)
+}
+
+// SetMode sets how data is kept in the cache, it must be called before the
+// cache is used. The default is FileCache
+func (c *Cache) SetMode(mode CacheMode) {
+	c.mode = mode
+}
+
+// Mode returns how data is kept in the cache
+func (c *Cache) Mode() CacheMode {
+	return c.mode
+}
+
+func (c *Cache) blockPath(key []byte) string {
+	return shard(filepath.Join(c.cache, blocksDir), hex.EncodeToString(key))
+}
+
+// readBlock reads the block at index of m into dest starting at off (relative
+// to the block), the block is downloaded and stored in the cache if needed
+func (c *Cache) readBlock(m meta.Meta, index int, dest []byte, off int64) (int, error) {
+	name := c.blockPath(m.Blocks()[index].Key)
+	file, err := os.Open(name)
+	if err == nil {
+		if intact(file, m, index) {
+			defer file.Close()
+			cacheResult(true)
+
+			n, err := file.ReadAt(dest, off)
+			if err == io.EOF {
+				err = nil
+			}
+
+			return n, err
+		}
+
+		// the block is downloaded again
+		file.Close()
+		log.Errorf("cached block '%s' is corrupt, removing it", name)
+		if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+			return 0, err
+		}
+	} else if !os.IsNotExist(err) {
+		return 0, err
+	}
+
+	cacheResult(false)
+	data, err := c.fetchBlock(m, index)
+	if err != nil {
+		return 0, err
+	}
+
+	if off >= int64(len(data)) {
+		return 0, nil
+	}
+
+	return copy(dest, data[off:]), nil
+}
+
+// intact checks the cached block at index of m. A block that doesn't have the
+// expected size is only intact if it encodes back to its key
+func intact(file *os.File, m meta.Meta, index int) bool {
+	stat, err := file.Stat()
+	if err != nil {
+		return false
+	}
+
+	if uint64(stat.Size()) == blockSize(m, index) {
+		return true
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return false
+	}
+
+	block, _, err := encodeBlock(data)
+	return err == nil && bytes.Equal(block.Key, m.Blocks()[index].Key)
+}
+
+// blockSize returns the size of the (decrypted) block at index of m
+func blockSize(m meta.Meta, index int) uint64 {
+	info := m.Info()
+	start := uint64(index) * info.FileBlockSize
+	if start >= info.Size {
+		return 0
+	}
+
+	if size := info.Size - start; size < info.FileBlockSize {
+		return size
+	}
+
+	return info.FileBlockSize
+}
+
+// fetchBlock downloads the block at index of m and stores it in the cache.
+// Concurrent fetches of the same block are only downloaded once
+func (c *Cache) fetchBlock(m meta.Meta, index int) ([]byte, error) {
+	name := c.blockPath(m.Blocks()[index].Key)
+	data, err, _ := c.inflight.Do(name, func() (interface{}, error) {
+		data, err := NewDownloader(c.storageOf(m), m).DownloadBlock(index)
+		if err != nil {
+			return nil, err
+		}
+
+		// the block is still returned if it can't be cached
+		if err := storeBlock(name, data); err != nil {
+			log.Errorf("failed to cache block '%s': %s", name, err)
+		}
+
+		return data, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return data.([]byte), nil
+}
+
+// fetchBlocks makes sure all the blocks of m are in the cache
+func (c *Cache) fetchBlocks(m meta.Meta) error {
+	for index, block := range m.Blocks() {
+		if _, err := os.Stat(c.blockPath(block.Key)); err == nil {
+			cacheResult(true)
+			continue
+		}
+
+		cacheResult(false)
+		if _, err := c.fetchBlock(m, index); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// storeBlock writes the block to a temporary file that is renamed once complete,
+// so a block in the cache is never partial
+func storeBlock(name string, data []byte) error {
+	dir := filepath.Dir(name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(dir, "."+filepath.Base(name)+"-")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	if _, err := file.Write(data); err != nil {
+		return err
+	}
+
+	if err := file.Sync(); err != nil {
+		return err
+	}
+
+	if err := file.Chmod(0444); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), name)
+}
+
+// touch updates the access time of a cache file, which is used to pick
+// eviction candidates
+func touch(name string) {
+	times := []unix.Timespec{{Nsec: unix.UTIME_NOW}, {Nsec: unix.UTIME_OMIT}}
+	if err := unix.UtimesNano(name, times); err != nil && !os.IsNotExist(err) {
+		log.Errorf("failed to update cache file '%s' access time: %s", name, err)
+	}
+}
+
+// blockFile is a read only nodefs.File that reads the file content from the
+// blocks in the cache, missing blocks are downloaded when they are read
+type blockFile struct {
+	nodefs.File
+	cache     *Cache
+	meta      meta.Meta
+	size      uint64
+	blockSize uint64
+
+	// blocks that had their access time updated
+	touched []bool
+	m       sync.Mutex
+}
+
+func newBlockFile(cache *Cache, m meta.Meta) *blockFile {
+	return &blockFile{
+		File:      nodefs.NewDefaultFile(),
+		cache:     cache,
+		meta:      m,
+		size:      m.Info().Size,
+		blockSize: m.Info().FileBlockSize,
+		touched:   make([]bool, len(m.Blocks())),
+	}
+}
+
+func (f *blockFile) String() string {
+	return fmt.Sprintf("blockFile(%s)", f.meta.ID())
+}
+
+func (f *blockFile) touch(index int) {
+	f.m.Lock()
+	touched := f.touched[index]
+	f.touched[index] = true
+	f.m.Unlock()
+
+	if !touched {
+		touch(f.cache.blockPath(f.meta.Blocks()[index].Key))
+	}
+}
+
+func (f *blockFile) Read(dest []byte, off int64) (fuse.ReadResult, fuse.Status) {
+	size := int64(f.size)
+	if off >= size || len(dest) == 0 {
+		return fuse.ReadResultData(nil), fuse.OK
+	}
+
+	if f.blockSize == 0 {
+		log.Errorf("failed to read %s: block size is not set", f)
+		return nil, fuse.EIO
+	}
+
+	end := off + int64(len(dest))
+	if end > size {
+		end = size
+	}
+
+	bs := int64(f.blockSize)
+	for pos := off; pos < end; {
+		index := int(pos / bs)
+		if index >= len(f.touched) {
+			log.Errorf("failed to read %s: offset %d is out of blocks", f, pos)
+			return nil, fuse.EIO
+		}
+
+		start := int64(index) * bs
+		want := start + bs
+		if want > end {
+			want = end
+		}
+
+		n, err := f.cache.readBlock(f.meta, index, dest[pos-off:want-off], pos-start)
+		if err != nil {
+			log.Errorf("failed to read block %d of %s: %s", index, f, err)
+			return nil, fuse.EIO
+		}
+
+		if int64(n) != want-pos {
+			log.Errorf("failed to read block %d of %s: block is too short", index, f)
+			return nil, fuse.EIO
+		}
+
+		f.touch(index)
+		pos = want
+	}
+
+	return fuse.ReadResultData(dest[:end-off]), fuse.OK
+}
+
+// BlockStore exposes the blocks of a block cache in the storage format, so the
+// cache can be served to other nodes (see router.Server). The cache keeps the
+// blocks decrypted, they are compressed and encrypted again when read, which
+// gives the stored block back for blocks uploaded by the Uploader. Blocks that
+// were encoded differently (e.g. by other tools) don't match their key once encoded
+// again, so they are not served. New blocks can't be stored since the key to decrypt
+// them is not known
+type BlockStore struct {
+	cache *Cache
+}
+
+// NewBlockStore creates a block store over the blocks in cache
+func NewBlockStore(cache *Cache) *BlockStore {
+	return &BlockStore{cache: cache}
+}
+
+// Get returns the block key in the storage format, or router.ErrNotFound if
+// the block is not in the cache or can't be encoded back to the block key
+func (s *BlockStore) Get(key []byte) ([]byte, error) {
+	name := s.cache.blockPath(key)
+	data, err := os.ReadFile(name)
+	if os.IsNotExist(err) {
+		return nil, router.ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	block, encrypted, err := encodeBlock(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(block.Key, key) {
+		log.Debugf("cached block '%x' is encoded as '%x'", key, block.Key)
+		return nil, router.ErrNotFound
+	}
+
+	touch(name)
+	return encrypted, nil
+}
+
+// Exists checks if the block key is in the cache and can be served by Get
+func (s *BlockStore) Exists(key []byte) (bool, error) {
+	_, err := s.Get(key)
+	if err == router.ErrNotFound {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// Set always fails, blocks are only added to the cache when they are downloaded
+func (s *BlockStore) Set(key, data []byte) error {
+	return errors.New("the block cache is read only")
+}
@@ -0,0 +1,217 @@
+package rofs
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/stretchr/testify/assert"
+	"github.com/threefoldtech/0-fs/meta"
+	"github.com/threefoldtech/0-fs/storage/router"
+)
+
+// countBlocks returns the number of blocks in the block cache
+func countBlocks(t *testing.T, cache *Cache) int {
+	count := 0
+	err := filepath.Walk(filepath.Join(cache.cache, blocksDir), func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			count++
+		}
+		return nil
+	})
+
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	return count
+}
+
+func readAll(t *testing.T, file *blockFile, size int, off int64) []byte {
+	buf := make([]byte, size)
+	result, status := file.Read(buf, off)
+	if ok := assert.Equal(t, fuse.OK, status); !ok {
+		t.Fatal()
+	}
+
+	data, status := result.Bytes(buf)
+	if ok := assert.Equal(t, fuse.OK, status); !ok {
+		t.Fatal()
+	}
+
+	return data
+}
+
+func TestBlockCache(t *testing.T) {
+	storage, blocks, err := MakeStorage(4)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	var content []byte
+	for _, block := range blocks {
+		content = append(content, plain(t, storage, block)...)
+	}
+
+	// a and b share blocks 1 and 2
+	a := &TestMeta{id: "a", blocks: blocks[0:3], size: 3*ChunkSize - 10}
+	b := &TestMeta{id: "b", blocks: blocks[1:4], size: 3 * ChunkSize}
+
+	cache := NewCache(t.TempDir(), storage)
+	cache.SetMode(BlockCache)
+
+	fileA := newBlockFile(cache, a)
+	// a read that spans over blocks 1 and 2
+	assert.Equal(t, content[ChunkSize+100:2*ChunkSize+100], readAll(t, fileA, ChunkSize, ChunkSize+100))
+	assert.Equal(t, 2, countBlocks(t, cache))
+
+	// reading past the end of the file
+	assert.Len(t, readAll(t, fileA, ChunkSize, int64(a.size)), 0)
+	assert.Equal(t, content[:a.size], readAll(t, fileA, 4*ChunkSize, 0))
+	assert.Equal(t, 3, countBlocks(t, cache))
+
+	// shared blocks are not downloaded again
+	delete(storage.data, string(blocks[1].Key))
+	delete(storage.data, string(blocks[2].Key))
+
+	fileB := newBlockFile(cache, b)
+	assert.Equal(t, content[ChunkSize:], readAll(t, fileB, int(b.size), 0))
+	assert.Equal(t, 4, countBlocks(t, cache))
+
+	// no whole file is cached
+	_, err = os.Stat(cache.path(a.ID()))
+	assert.True(t, os.IsNotExist(err))
+
+	// missing blocks fail the read
+	c := &TestMeta{id: "c", blocks: []meta.BlockInfo{{Key: []byte("missing")}}, size: 10}
+	_, status := newBlockFile(cache, c).Read(make([]byte, 10), 0)
+	assert.Equal(t, fuse.EIO, status)
+}
+
+func TestBlockCachePrefetch(t *testing.T) {
+	storage, blocks, err := MakeStorage(4)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	a := &TestMeta{id: "a", blocks: blocks[0:3], size: 3 * ChunkSize}
+	b := &TestMeta{id: "b", blocks: blocks[1:4], size: 3 * ChunkSize}
+	root := &testDir{children: []meta.Meta{a, b}}
+	store := TestStore{"": root, "a": a, "b": b}
+
+	cache := NewCache(t.TempDir(), storage)
+	cache.SetMode(BlockCache)
+
+	assert.Equal(t, 2, cache.Prefetch(context.Background(), store, []string{""}, 2))
+	assert.Equal(t, 4, countBlocks(t, cache))
+
+	for _, block := range blocks {
+		data, err := os.ReadFile(cache.blockPath(block.Key))
+		if ok := assert.NoError(t, err); ok {
+			assert.Equal(t, plain(t, storage, block), data)
+		}
+	}
+}
+
+func TestBlockCacheCorrupt(t *testing.T) {
+	storage, blocks, err := MakeStorage(2)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	content := append(plain(t, storage, blocks[0]), plain(t, storage, blocks[1])...)
+	a := &TestMeta{id: "a", blocks: blocks, size: 2 * ChunkSize}
+
+	cache := NewCache(t.TempDir(), storage)
+	cache.SetMode(BlockCache)
+
+	// a truncated block in the cache is downloaded again
+	name := cache.blockPath(blocks[1].Key)
+	if err := storeBlock(name, content[ChunkSize:ChunkSize+100]); err != nil {
+		t.Fatal(err)
+	}
+
+	file := newBlockFile(cache, a)
+	assert.Equal(t, content, readAll(t, file, len(content), 0))
+
+	data, err := os.ReadFile(name)
+	if ok := assert.NoError(t, err); ok {
+		assert.Equal(t, content[ChunkSize:], data)
+	}
+}
+
+func TestBlockStore(t *testing.T) {
+	storage := &TestStorage{data: make(map[string][]byte)}
+	uploader := NewUploader(storage)
+
+	data := make([]byte, uploader.BlockSize()+100)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+
+	blocks, err := uploader.Upload(bytes.NewReader(data))
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	a := &TestMeta{id: "a", blocks: blocks, size: uint64(len(data))}
+	cache := NewCache(t.TempDir(), storage)
+	cache.SetMode(BlockCache)
+
+	store := NewBlockStore(cache)
+	ok, err := store.Exists(blocks[0].Key)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	_, err = store.Get(blocks[0].Key)
+	assert.Equal(t, router.ErrNotFound, err)
+
+	if ok := assert.NoError(t, cache.fetchBlocks(a)); !ok {
+		t.Fatal()
+	}
+
+	// the blocks are served as they were uploaded
+	for i, block := range blocks {
+		ok, err := store.Exists(block.Key)
+		assert.NoError(t, err)
+		assert.True(t, ok, "block %d", i)
+
+		raw, err := store.Get(block.Key)
+		if ok := assert.NoError(t, err, "block %d", i); ok {
+			assert.Equal(t, storage.data[string(block.Key)], raw, "block %d", i)
+		}
+	}
+
+	assert.Error(t, store.Set(blocks[0].Key, storage.data[string(blocks[0].Key)]))
+
+	// a block encoded differently than the uploader does is not served, since
+	// its content doesn't match its key once encoded again
+	other := []byte("other")
+	if err := storeBlock(cache.blockPath(other), data[:100]); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = store.Get(other)
+	assert.Equal(t, router.ErrNotFound, err)
+	ok, err = store.Exists(other)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestParseCacheMode(t *testing.T) {
+	for _, mode := range []CacheMode{FileCache, BlockCache} {
+		parsed, err := ParseCacheMode(mode.String())
+		if ok := assert.NoError(t, err); ok {
+			assert.Equal(t, mode, parsed)
+		}
+	}
+
+	_, err := ParseCacheMode("random")
+	assert.Error(t, err)
+}
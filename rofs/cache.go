@@ -5,26 +5,76 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/threefoldtech/0-fs/meta"
 	"github.com/threefoldtech/0-fs/storage"
+	"golang.org/x/sync/singleflight"
+	"golang.org/x/sys/unix"
 )
 
 type Cache struct {
-	cache   string
-	storage storage.Storage
+	cache    string
+	storage  atomic.Pointer[storage.Storage]
+	layers   atomic.Pointer[map[meta.Store]storage.Storage]
+	mode     CacheMode
+	inflight singleflight.Group
 }
 
-func NewCache(path string, storage storage.Storage) Cache {
-	return Cache{
-		cache:   path,
-		storage: storage,
+func NewCache(path string, storage storage.Storage) *Cache {
+	cache := &Cache{
+		cache: path,
 	}
+
+	cache.SetStorage(storage)
+	return cache
+}
+
+// Storage returns the storage used to download files
+func (c *Cache) Storage() storage.Storage {
+	return *c.storage.Load()
+}
+
+// SetStorage sets the storage used to download files
+func (c *Cache) SetStorage(storage storage.Storage) {
+	c.storage.Store(&storage)
+}
+
+// SetLayerStorage sets the storage used to download the blocks of the entries of
+// each layer of a layered meta store (see meta.Layer), so blocks are only looked up
+// in the storage of the flist they belong to. The keys are the stores given to
+// meta.Layered, they must be comparable. Entries of other layers, or not coming from
+// a layered store, are downloaded from the default storage (see SetStorage)
+func (c *Cache) SetLayerStorage(layers map[meta.Store]storage.Storage) {
+	c.layers.Store(&layers)
+}
+
+// storageOf returns the storage to download the blocks of m from
+func (c *Cache) storageOf(m meta.Meta) storage.Storage {
+	if m, ok := m.(*bound); ok {
+		return m.storage
+	}
+
+	if layers := c.layers.Load(); layers != nil {
+		if layer := meta.Layer(m); layer != nil {
+			if storage, ok := (*layers)[layer]; ok {
+				return storage
+			}
+		}
+	}
+
+	return c.Storage()
 }
 
 func (c *Cache) path(hash string) string {
-	base := c.cache
+	return shard(c.cache, hash)
+}
+
+// shard returns the path of a cache file named hash under base, files are
+// spread over sub directories named after the first bytes of the hash
+func shard(base, hash string) string {
 	// these checks are here to avoid panicing
 	// in case a bad name (hash) was provided
 	// it will still return a valid filepath
@@ -70,11 +120,41 @@ func (c *Cache) ensure(name string) (*os.File, error) {
 	}
 }
 
+// openCached opens (or creates) the cache file name, the file is marked as in use (see use)
+// before it's returned, so it can't be evicted while it's being opened or read
+func (c *Cache) openCached(name string) (*os.File, error) {
+	for {
+		f, err := c.ensure(name)
+		if err != nil {
+			return nil, err
+		}
+
+		c.use(f)
+		// the file could have been evicted before it was marked as in use
+		if current(f, name) {
+			return f, nil
+		}
+
+		f.Close()
+	}
+}
+
+// current checks if the file name is still the open file f
+func current(f *os.File, name string) bool {
+	opened, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	stat, err := os.Stat(name)
+	return err == nil && os.SameFile(opened, stat)
+}
+
 // CheckAndGet makes sure the file exists in cache and makes sure the file content is downloaded safely
 func (c *Cache) CheckAndGet(m meta.Meta) (*os.File, error) {
 	//atomic check and download a file
 	name := c.path(m.ID())
-	f, err := c.ensure(name)
+	f, err := c.openCached(name)
 	if err != nil {
 		return nil, err
 	}
@@ -95,7 +175,9 @@ func (c *Cache) CheckAndGet(m meta.Meta) (*os.File, error) {
 	}
 
 	info := m.Info()
-	if fstat.Size() == int64(info.Size) {
+	hit := fstat.Size() == int64(info.Size) && !c.partial(name)
+	cacheResult(hit)
+	if hit {
 		log.Debug("cache hit for file with hash", m.ID())
 		return f, nil
 	}
@@ -103,6 +185,7 @@ func (c *Cache) CheckAndGet(m meta.Meta) (*os.File, error) {
 	if err := c.download(f, m); err != nil {
 		f.Close()
 		os.Remove(name)
+		os.Remove(c.mapPath(name))
 		return nil, err
 	}
 
@@ -110,6 +193,12 @@ func (c *Cache) CheckAndGet(m meta.Meta) (*os.File, error) {
 		return nil, err
 	}
 
+	// the file is now complete, drop the block map
+	// left by a previous sparse open if any
+	if err := os.Remove(c.mapPath(name)); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
 	if _, err := f.Seek(0, io.SeekStart); err != nil {
 		return nil, err
 	}
@@ -117,10 +206,95 @@ func (c *Cache) CheckAndGet(m meta.Meta) (*os.File, error) {
 	return f, nil
 }
 
+// Sparse makes sure the file exists in cache with its full size but without
+// downloading any of its content. If the file is already completely downloaded
+// the returned block map is nil, otherwise the block map tracks which blocks of
+// the (sparse) cache file are already fetched.
+func (c *Cache) Sparse(m meta.Meta) (*os.File, *BlockMap, error) {
+	name := c.path(m.ID())
+	f, err := c.openCached(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+
+	defer func() {
+		if err := syscall.Flock(int(f.Fd()), syscall.LOCK_UN); err != nil {
+			log.Error("failed to release file", err)
+		}
+	}()
+
+	fstat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+
+	info := m.Info()
+	hit := fstat.Size() == int64(info.Size) && !c.partial(name)
+	cacheResult(hit)
+	if hit {
+		log.Debug("cache hit for file with hash", m.ID())
+		return f, nil, nil
+	}
+
+	// the block map is created before the file is extended to its full
+	// size so a concurrent CheckAndGet never sees a sparse file as complete
+	blocks, err := openBlockMap(c.mapPath(name), len(m.Blocks()), fstat.Size() != int64(info.Size))
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+
+	if err := f.Truncate(int64(info.Size)); err != nil {
+		f.Close()
+		blocks.Close()
+		return nil, nil, err
+	}
+
+	return f, blocks, nil
+}
+
+// use marks the cache file as in use, so it's never evicted as long as it's
+// open, and updates its access time which is used to pick eviction candidates.
+func (c *Cache) use(f *os.File) {
+	// an open file description lock is released automatically once the file
+	// is closed, and unlike flock it doesn't conflict with the download lock
+	lock := unix.Flock_t{Type: unix.F_RDLCK, Whence: io.SeekStart}
+	if err := unix.FcntlFlock(f.Fd(), unix.F_OFD_SETLK, &lock); err != nil {
+		log.Errorf("failed to mark cache file '%s' as in use: %s", f.Name(), err)
+	}
+
+	stat, err := f.Stat()
+	if err != nil {
+		log.Errorf("failed to stat cache file '%s': %s", f.Name(), err)
+		return
+	}
+
+	if err := os.Chtimes(f.Name(), time.Now(), stat.ModTime()); err != nil {
+		log.Errorf("failed to update cache file '%s' access time: %s", f.Name(), err)
+	}
+}
+
+// mapPath returns the path of the block map of a sparse cache file
+func (c *Cache) mapPath(name string) string {
+	return name + ".blocks"
+}
+
+// partial checks if the cache file is a sparse file that is not fully downloaded yet
+func (c *Cache) partial(name string) bool {
+	_, err := os.Stat(c.mapPath(name))
+	return err == nil
+}
+
 // download file from storage
 func (c *Cache) download(file *os.File, m meta.Meta) error {
 	downloader := Downloader{
-		storage:   c.storage,
+		storage:   c.storageOf(m),
 		blockSize: m.Info().FileBlockSize,
 		blocks:    m.Blocks(),
 	}
@@ -0,0 +1,84 @@
+package rofs
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/threefoldtech/0-fs/meta"
+	"github.com/threefoldtech/0-fs/storage"
+)
+
+// layerStore is a comparable meta store, so it can be used as a layer
+type layerStore struct {
+	TestStore
+}
+
+func TestLayerStorage(t *testing.T) {
+	// both storages have the same keys but different data
+	lowerStorage, lowerBlocks, err := MakeStorage(1)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	upperStorage, upperBlocks, err := MakeStorage(1)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	a := &TestMeta{id: "a", blocks: lowerBlocks, size: ChunkSize}
+	b := &TestMeta{id: "b", blocks: upperBlocks, size: ChunkSize}
+	lower := &layerStore{TestStore{"": &testDir{children: []meta.Meta{a}}, "a": a}}
+	upper := &layerStore{TestStore{"": &testDir{children: []meta.Meta{b}}, "b": b}}
+	store := meta.Layered(lower, upper)
+
+	get := func(p string) meta.Meta {
+		m, ok := store.Get(p)
+		if ok := assert.True(t, ok, p); !ok {
+			t.Fatal()
+		}
+		return m
+	}
+
+	for _, mode := range []CacheMode{FileCache, BlockCache} {
+		// blocks are never found in the default storage
+		newCache := func() *Cache {
+			cache := NewCache(t.TempDir(), &TestStorage{data: map[string][]byte{}})
+			cache.SetMode(mode)
+			return cache
+		}
+
+		assert.Error(t, newCache().fetch(get("a")), mode.String())
+
+		for _, test := range []struct {
+			name    string
+			storage *TestStorage
+			block   meta.BlockInfo
+		}{
+			{"a", lowerStorage, lowerBlocks[0]},
+			{"b", upperStorage, upperBlocks[0]},
+		} {
+			// the test blocks of both layers have the same keys
+			cache := newCache()
+			cache.SetLayerStorage(map[meta.Store]storage.Storage{lower: lowerStorage, upper: upperStorage})
+			if ok := assert.NoError(t, cache.fetch(get(test.name)), mode.String()); !ok {
+				continue
+			}
+
+			var data []byte
+			if mode == BlockCache {
+				data = readAll(t, newBlockFile(cache, get(test.name)), ChunkSize, 0)
+			} else {
+				file, err := cache.CheckAndGet(get(test.name))
+				if ok := assert.NoError(t, err); !ok {
+					continue
+				}
+				data, err = io.ReadAll(file)
+				file.Close()
+				assert.NoError(t, err)
+			}
+
+			assert.Equal(t, plain(t, test.storage, test.block), data, "%s %s", mode, test.name)
+		}
+	}
+}
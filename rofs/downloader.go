@@ -91,21 +91,39 @@ func (d *Downloader) downloadBlock(block meta.BlockInfo) ([]byte, error) {
 		return nil, err
 	}
 
+	if err := checkBlock(block, data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// checkBlock validates the hash of the decrypted block data
+func checkBlock(block meta.BlockInfo, data []byte) error {
 	hasher, err := blake2b.New(16, nil)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	if _, err := hasher.Write(data); err != nil {
-		return nil, err
+		return err
 	}
 
 	hash := hasher.Sum(nil)
 	if !bytes.Equal(hash, block.Decipher) {
-		return nil, fmt.Errorf("block key(%x), cypher(%x) hash is wrong hash(%x)", block.Key, block.Decipher, hash)
+		return fmt.Errorf("block key(%x), cypher(%x) hash is wrong hash(%x)", block.Key, block.Decipher, hash)
 	}
 
-	return data, nil
+	return nil
+}
+
+// DownloadBlock downloads and decrypts the block at index
+func (d *Downloader) DownloadBlock(index int) ([]byte, error) {
+	if index < 0 || index >= len(d.blocks) {
+		return nil, fmt.Errorf("block index %d out of range", index)
+	}
+
+	return d.downloadBlock(d.blocks[index])
 }
 
 func (d *Downloader) worker(ctx context.Context, feed <-chan int, out chan<- *OutputBlock) error {
@@ -0,0 +1,208 @@
+package rofs
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// DefaultEvictInterval is how often the cache is checked against its budget
+	DefaultEvictInterval = time.Minute
+)
+
+// Budget defines the limits of the cache, a zero value means no limit
+type Budget struct {
+	// Size max number of bytes used by the cache
+	Size uint64
+	// Files max number of files in the cache
+	Files uint64
+}
+
+// Unlimited returns true if budget sets no limits
+func (b Budget) Unlimited() bool {
+	return b.Size == 0 && b.Files == 0
+}
+
+func (b Budget) fits(size, files uint64) bool {
+	return (b.Size == 0 || size <= b.Size) && (b.Files == 0 || files <= b.Files)
+}
+
+type cacheEntry struct {
+	name string
+	// size of the cache file, including its block map
+	size  uint64
+	atime time.Time
+}
+
+// usage lists all files in cache, and compute the total used space. Block maps
+// are counted with their cache files, and temporary files (being written) are
+// ignored
+func (c *Cache) usage() ([]cacheEntry, uint64, error) {
+	var entries []cacheEntry
+	// maps are the sizes of the block maps
+	maps := make(map[string]uint64)
+	err := filepath.Walk(c.cache, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				// removed while walking
+				return nil
+			}
+			return err
+		}
+
+		if !info.Mode().IsRegular() || strings.HasPrefix(info.Name(), ".") {
+			return nil
+		}
+
+		// files are sparse so we count the actual used blocks
+		// instead of the file size
+		var size uint64
+		atime := info.ModTime()
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok && stat != nil {
+			size = uint64(stat.Blocks) * 512
+			atime = time.Unix(stat.Atim.Unix())
+		}
+
+		if strings.HasSuffix(name, ".blocks") {
+			// block maps are evicted with their cache files
+			maps[name] = size
+			return nil
+		}
+
+		entries = append(entries, cacheEntry{name: name, size: size, atime: atime})
+		return nil
+	})
+
+	var total uint64
+	for i := range entries {
+		entries[i].size += maps[c.mapPath(entries[i].name)]
+		total += entries[i].size
+	}
+
+	return entries, total, err
+}
+
+// evict removes the cache file only if it's not open
+// or being downloaded
+func (c *Cache) evict(name string) (bool, error) {
+	f, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	defer f.Close()
+
+	// a download in progress
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		return false, nil
+	}
+
+	defer func() {
+		if err := syscall.Flock(int(f.Fd()), syscall.LOCK_UN); err != nil {
+			log.Error("failed to release file", err)
+		}
+	}()
+
+	// file is open (see Cache.use). Holding the flock above guarantees
+	// the file is not opened by the cache in the meantime
+	lock := unix.Flock_t{Type: unix.F_WRLCK, Whence: io.SeekStart}
+	if err := unix.FcntlFlock(f.Fd(), unix.F_OFD_GETLK, &lock); err != nil {
+		return false, err
+	}
+
+	if lock.Type != unix.F_UNLCK {
+		return false, nil
+	}
+
+	if err := os.Remove(c.mapPath(name)); err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+
+	if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Evict removes the least recently accessed files from the cache until
+// it fits in the given budget. Files that are currently open or being
+// downloaded are never evicted.
+func (c *Cache) Evict(budget Budget) error {
+	if budget.Unlimited() {
+		return nil
+	}
+
+	entries, total, err := c.usage()
+	if err != nil {
+		return err
+	}
+
+	files := uint64(len(entries))
+	if budget.fits(total, files) {
+		return nil
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].atime.Before(entries[j].atime)
+	})
+
+	for _, entry := range entries {
+		if budget.fits(total, files) {
+			break
+		}
+
+		evicted, err := c.evict(entry.name)
+		if err != nil {
+			log.Errorf("failed to evict cache file '%s': %s", entry.name, err)
+			continue
+		}
+
+		if !evicted {
+			log.Debugf("cache file '%s' is in use", entry.name)
+			continue
+		}
+
+		log.Debugf("evicted cache file '%s'", entry.name)
+		total -= entry.size
+		files--
+	}
+
+	if !budget.fits(total, files) {
+		log.Warningf("cache is still over budget (%d bytes, %d files)", total, files)
+	}
+
+	return nil
+}
+
+// Evictor runs Evict every interval until ctx is canceled
+func (c *Cache) Evictor(ctx context.Context, budget Budget, interval time.Duration) {
+	if budget.Unlimited() {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.Evict(budget); err != nil {
+			log.Errorf("failed to evict cache: %s", err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
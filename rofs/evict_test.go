@@ -0,0 +1,174 @@
+package rofs
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func makeCacheFile(t *testing.T, cache *Cache, hash string, atime time.Time) string {
+	name := cache.path(hash)
+	f, err := cache.ensure(name)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	defer f.Close()
+
+	if _, err := f.Write(make([]byte, 8192)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Chtimes(name, atime, atime); err != nil {
+		t.Fatal(err)
+	}
+
+	return name
+}
+
+func exists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
+
+func TestEvictFiles(t *testing.T) {
+	cache := NewCache(t.TempDir(), nil)
+
+	now := time.Now()
+	a := makeCacheFile(t, cache, "aaaaaa", now.Add(-3*time.Hour))
+	b := makeCacheFile(t, cache, "bbbbbb", now.Add(-2*time.Hour))
+	c := makeCacheFile(t, cache, "cccccc", now.Add(-1*time.Hour))
+
+	//block map of a partially downloaded file is evicted with it
+	if err := os.WriteFile(cache.mapPath(a), []byte{1, 0}, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok := assert.NoError(t, cache.Evict(Budget{Files: 2})); !ok {
+		t.Fatal()
+	}
+
+	assert.False(t, exists(a))
+	assert.False(t, exists(cache.mapPath(a)))
+	assert.True(t, exists(b))
+	assert.True(t, exists(c))
+
+	//b is open, so it can't be evicted
+	f, err := os.Open(b)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	cache.use(f)
+
+	if ok := assert.NoError(t, cache.Evict(Budget{Files: 1})); !ok {
+		t.Fatal()
+	}
+
+	assert.True(t, exists(b))
+	assert.False(t, exists(c))
+
+	//once closed it can be evicted
+	f.Close()
+	if ok := assert.NoError(t, cache.Evict(Budget{Size: 1})); !ok {
+		t.Fatal()
+	}
+
+	assert.False(t, exists(b))
+}
+
+func TestEvictBlockMaps(t *testing.T) {
+	cache := NewCache(t.TempDir(), nil)
+
+	now := time.Now()
+	a := makeCacheFile(t, cache, "aaaaaa", now.Add(-2*time.Hour))
+	b := makeCacheFile(t, cache, "bbbbbb", now.Add(-1*time.Hour))
+
+	if err := os.WriteFile(cache.mapPath(a), make([]byte, 8192), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stat syscall.Stat_t
+	if err := syscall.Stat(b, &stat); err != nil {
+		t.Fatal(err)
+	}
+
+	//evicting a and its block map is enough to only keep b
+	if ok := assert.NoError(t, cache.Evict(Budget{Size: uint64(stat.Blocks) * 512})); !ok {
+		t.Fatal()
+	}
+
+	assert.False(t, exists(a))
+	assert.False(t, exists(cache.mapPath(a)))
+	assert.True(t, exists(b))
+}
+
+func TestEvictUnlimited(t *testing.T) {
+	cache := NewCache(t.TempDir(), nil)
+
+	a := makeCacheFile(t, cache, "aaaaaa", time.Now())
+
+	if ok := assert.NoError(t, cache.Evict(Budget{})); !ok {
+		t.Fatal()
+	}
+
+	assert.True(t, exists(a))
+}
+
+func TestEvictDownloading(t *testing.T) {
+	cache := NewCache(t.TempDir(), nil)
+
+	a := makeCacheFile(t, cache, "aaaaaa", time.Now())
+
+	f, err := os.Open(a)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	defer f.Close()
+
+	// simulate a download in progress
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok := assert.NoError(t, cache.Evict(Budget{Size: 1})); !ok {
+		t.Fatal()
+	}
+
+	assert.True(t, exists(a))
+}
+
+func TestEvictIgnoresSidecars(t *testing.T) {
+	cache := NewCache(t.TempDir(), nil)
+
+	a := makeCacheFile(t, cache, "aaaaaa", time.Now())
+
+	//a block being written and a block map left without its file are not cached entries
+	temp := filepath.Join(filepath.Dir(a), ".bbbbbb-123")
+	if err := os.WriteFile(temp, make([]byte, 8192), 0644); err != nil {
+		t.Fatal(err)
+	}
+	orphan := filepath.Join(filepath.Dir(a), "aacccc.blocks")
+	if err := os.WriteFile(orphan, make([]byte, 8192), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, total, err := cache.usage()
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, a, entries[0].name)
+		assert.Equal(t, entries[0].size, total)
+	}
+
+	if ok := assert.NoError(t, cache.Evict(Budget{Files: 1})); !ok {
+		t.Fatal()
+	}
+
+	assert.True(t, exists(a))
+	assert.True(t, exists(temp))
+}
@@ -0,0 +1,306 @@
+package rofs
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/hanwen/go-fuse/v2/fuse/nodefs"
+	"github.com/threefoldtech/0-fs/meta"
+	"golang.org/x/sync/singleflight"
+)
+
+// BlockMap keeps track of the blocks that are already downloaded in a sparse
+// cache file. It's persisted next to the cache file as one byte per block, so
+// other mounts that share the same cache can reuse the fetched blocks.
+type BlockMap struct {
+	file    *os.File
+	present []bool
+	missing int
+}
+
+func openBlockMap(name string, count int, reset bool) (*BlockMap, error) {
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	if reset {
+		if err := file.Truncate(0); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	if err := file.Truncate(int64(count)); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	blocks := &BlockMap{
+		file:    file,
+		present: make([]bool, count),
+	}
+
+	if err := blocks.Load(); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return blocks, nil
+}
+
+// Load reloads the block map from disk
+func (b *BlockMap) Load() error {
+	buf := make([]byte, len(b.present))
+	if _, err := b.file.ReadAt(buf, 0); err != nil && err != io.EOF {
+		return err
+	}
+
+	b.missing = 0
+	for i, v := range buf {
+		b.present[i] = v != 0
+		if !b.present[i] {
+			b.missing++
+		}
+	}
+
+	return nil
+}
+
+// Has checks if block at index is downloaded
+func (b *BlockMap) Has(index int) bool {
+	return b.present[index]
+}
+
+// Set marks block at index as downloaded
+func (b *BlockMap) Set(index int) error {
+	if b.present[index] {
+		return nil
+	}
+
+	if _, err := b.file.WriteAt([]byte{1}, int64(index)); err != nil {
+		return err
+	}
+
+	b.present[index] = true
+	b.missing--
+	return nil
+}
+
+// Complete returns true if all blocks are downloaded
+func (b *BlockMap) Complete() bool {
+	return b.missing == 0
+}
+
+// Close closes the underlying block map file
+func (b *BlockMap) Close() error {
+	return b.file.Close()
+}
+
+// sparseFile is a read only nodefs.File that only downloads the blocks
+// that are actually read and stores them in a sparse cache file.
+type sparseFile struct {
+	nodefs.File
+	file       *os.File
+	blocks     *BlockMap
+	downloader *Downloader
+	size       uint64
+	blockSize  uint64
+
+	// inflight makes sure a block is only downloaded once, even if it's
+	// read concurrently (by this file or other opens of the same file)
+	inflight *singleflight.Group
+
+	// m protects the block map, it's never held during a download
+	m sync.Mutex
+}
+
+func newSparseFile(file *os.File, blocks *BlockMap, downloader *Downloader, inflight *singleflight.Group, m meta.Meta) *sparseFile {
+	return &sparseFile{
+		File:       nodefs.NewDefaultFile(),
+		file:       file,
+		blocks:     blocks,
+		downloader: downloader,
+		size:       m.Info().Size,
+		blockSize:  m.Info().FileBlockSize,
+		inflight:   inflight,
+	}
+}
+
+func (f *sparseFile) String() string {
+	return fmt.Sprintf("sparseFile(%s)", f.file.Name())
+}
+
+// missing returns the blocks that covers the range [off, end) and are
+// not downloaded yet
+func (f *sparseFile) missing(off, end int64) ([]int, error) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	if f.blocks == nil {
+		//file is complete
+		return nil, nil
+	}
+
+	if f.blockSize == 0 {
+		return nil, fmt.Errorf("block size is not set")
+	}
+
+	var missing []int
+	bs := int64(f.blockSize)
+	for index := int(off / bs); index <= int((end-1)/bs); index++ {
+		if !f.blocks.Has(index) {
+			missing = append(missing, index)
+		}
+	}
+
+	return missing, nil
+}
+
+// download downloads the block at index, concurrent downloads of the
+// same block of the same cache file are only done once
+func (f *sparseFile) download(index int) ([]byte, error) {
+	key := fmt.Sprintf("%s:%d", f.file.Name(), index)
+	data, err, _ := f.inflight.Do(key, func() (interface{}, error) {
+		log.Debugf("fetching block %d of %s", index, f.file.Name())
+		return f.downloader.DownloadBlock(index)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return data.([]byte), nil
+}
+
+// fetch makes sure all blocks that covers the range [off, end) are
+// downloaded into the cache file
+func (f *sparseFile) fetch(off, end int64) error {
+	missing, err := f.missing(off, end)
+	if err != nil || len(missing) == 0 {
+		return err
+	}
+
+	blocks := make(map[int][]byte, len(missing))
+	for _, index := range missing {
+		data, err := f.download(index)
+		if err != nil {
+			return err
+		}
+
+		blocks[index] = data
+	}
+
+	return f.store(blocks)
+}
+
+// store writes the downloaded blocks to the cache file and marks them as present
+func (f *sparseFile) store(blocks map[int][]byte) error {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	if f.blocks == nil {
+		//file was completed by a concurrent read
+		return nil
+	}
+
+	if err := syscall.Flock(int(f.file.Fd()), syscall.LOCK_EX); err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := syscall.Flock(int(f.file.Fd()), syscall.LOCK_UN); err != nil {
+			log.Error("failed to release file", err)
+		}
+	}()
+
+	// other mounts sharing the same cache may have fetched
+	// some of the blocks in the meantime
+	if err := f.blocks.Load(); err != nil {
+		return err
+	}
+
+	bs := int64(f.blockSize)
+	var fetched []int
+	for index, data := range blocks {
+		if f.blocks.Has(index) {
+			continue
+		}
+
+		if _, err := f.file.WriteAt(data, int64(index)*bs); err != nil {
+			return err
+		}
+
+		fetched = append(fetched, index)
+	}
+
+	if len(fetched) == 0 {
+		return nil
+	}
+
+	// make sure data hits the disk before the blocks are marked as present
+	if err := f.file.Sync(); err != nil {
+		return err
+	}
+
+	for _, index := range fetched {
+		if err := f.blocks.Set(index); err != nil {
+			return err
+		}
+	}
+
+	if !f.blocks.Complete() {
+		return nil
+	}
+
+	log.Debugf("all blocks of %s are downloaded", f.file.Name())
+	// the file is fully downloaded, dropping the block map
+	// turns it into a normal complete cache file
+	if err := os.Remove(f.blocks.file.Name()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	f.blocks.Close()
+	f.blocks = nil
+
+	return nil
+}
+
+func (f *sparseFile) Read(dest []byte, off int64) (fuse.ReadResult, fuse.Status) {
+	size := int64(f.size)
+	if off >= size || len(dest) == 0 {
+		return fuse.ReadResultData(nil), fuse.OK
+	}
+
+	end := off + int64(len(dest))
+	if end > size {
+		end = size
+	}
+
+	if err := f.fetch(off, end); err != nil {
+		log.Errorf("failed to fetch blocks of %s: %s", f.file.Name(), err)
+		return nil, fuse.EIO
+	}
+
+	n, err := f.file.ReadAt(dest[:end-off], off)
+	if err != nil && err != io.EOF {
+		return nil, fuse.ToStatus(err)
+	}
+
+	return fuse.ReadResultData(dest[:n]), fuse.OK
+}
+
+func (f *sparseFile) Release() {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	if f.blocks != nil {
+		f.blocks.Close()
+	}
+
+	f.file.Close()
+}
@@ -0,0 +1,277 @@
+package rofs
+
+import (
+	"crypto/md5"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/stretchr/testify/assert"
+	"github.com/threefoldtech/0-fs/meta"
+	"github.com/xxtea/xxtea-go/xxtea"
+)
+
+type TestMeta struct {
+	id     string
+	blocks []meta.BlockInfo
+	size   uint64
+	info   *meta.Info
+}
+
+func (m *TestMeta) String() string           { return m.id }
+func (m *TestMeta) ID() string               { return m.id }
+func (m *TestMeta) Name() string             { return m.id }
+func (m *TestMeta) IsDir() bool              { return false }
+func (m *TestMeta) Blocks() []meta.BlockInfo { return m.blocks }
+func (m *TestMeta) Children() []meta.Meta    { return nil }
+func (m *TestMeta) Info() meta.Info {
+	if m.info != nil {
+		return *m.info
+	}
+
+	return meta.Info{
+		Type:          meta.RegularType,
+		Size:          m.size,
+		FileBlockSize: ChunkSize,
+	}
+}
+
+func plain(t *testing.T, storage *TestStorage, block meta.BlockInfo) []byte {
+	data, err := snappy.Decode(nil, xxtea.Decrypt(storage.data[string(block.Key)], block.Decipher))
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	return data
+}
+
+func TestSparseRead(t *testing.T) {
+	storage, blocks, err := MakeStorage(20)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	root, err := os.MkdirTemp("", "cache-")
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	defer os.RemoveAll(root)
+
+	m := &TestMeta{id: "abcdef", blocks: blocks, size: 20 * ChunkSize}
+	cache := NewCache(root, storage)
+
+	f, blocksMap, err := cache.Sparse(m)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	if ok := assert.NotNil(t, blocksMap); !ok {
+		t.Fatal()
+	}
+
+	stat, err := f.Stat()
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	assert.EqualValues(t, m.size, stat.Size())
+
+	file := newSparseFile(f, blocksMap, NewDownloader(storage, m), &cache.inflight, m)
+
+	//read block 2 and part of block 3
+	expected := append(plain(t, storage, blocks[2]), plain(t, storage, blocks[3])...)[100 : 100+ChunkSize]
+
+	//all other blocks are not needed to serve this read
+	saved := make(map[string][]byte)
+	for k, v := range storage.data {
+		saved[k] = v
+	}
+	for i := range blocks {
+		if i != 2 && i != 3 {
+			delete(storage.data, fmt.Sprintf("block-%d", i))
+		}
+	}
+
+	buf := make([]byte, ChunkSize)
+	result, status := file.Read(buf, 2*ChunkSize+100)
+	if ok := assert.Equal(t, fuse.OK, status); !ok {
+		t.Fatal()
+	}
+	data, status := result.Bytes(buf)
+	if ok := assert.Equal(t, fuse.OK, status); !ok {
+		t.Fatal()
+	}
+	assert.Equal(t, expected, data)
+
+	assert.True(t, blocksMap.Has(2))
+	assert.True(t, blocksMap.Has(3))
+	assert.False(t, blocksMap.Has(4))
+	assert.True(t, cache.partial(cache.path(m.ID())))
+
+	//reading past the end of the file
+	result, status = file.Read(buf, int64(m.size))
+	if ok := assert.Equal(t, fuse.OK, status); !ok {
+		t.Fatal()
+	}
+	assert.Equal(t, 0, result.Size())
+
+	//now read everything
+	storage.data = saved
+	buf = make([]byte, m.size)
+	result, status = file.Read(buf, 0)
+	if ok := assert.Equal(t, fuse.OK, status); !ok {
+		t.Fatal()
+	}
+	data, _ = result.Bytes(buf)
+
+	hash := md5.Sum(data)
+	assert.Equal(t, storage.hash, hash[:])
+	assert.False(t, cache.partial(cache.path(m.ID())))
+	file.Release()
+
+	//file is complete now, it should be served from cache
+	storage.data = map[string][]byte{}
+	f, blocksMap, err = cache.Sparse(m)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	assert.Nil(t, blocksMap)
+	f.Close()
+
+	f, err = cache.CheckAndGet(m)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	defer f.Close()
+
+	h := md5.New()
+	_, err = io.Copy(h, f)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	assert.Equal(t, storage.hash, h.Sum(nil))
+}
+
+func TestSparseCheckAndGet(t *testing.T) {
+	storage, blocks, err := MakeStorage(5)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	root, err := os.MkdirTemp("", "cache-")
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	defer os.RemoveAll(root)
+
+	m := &TestMeta{id: "abcdef", blocks: blocks, size: 5 * ChunkSize}
+	cache := NewCache(root, storage)
+
+	f, blocksMap, err := cache.Sparse(m)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	if ok := assert.NotNil(t, blocksMap); !ok {
+		t.Fatal()
+	}
+	blocksMap.Close()
+	f.Close()
+
+	//the sparse file has the right size but it must not
+	//be considered complete
+	f, err = cache.CheckAndGet(m)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	defer f.Close()
+
+	assert.False(t, cache.partial(cache.path(m.ID())))
+
+	h := md5.New()
+	_, err = io.Copy(h, f)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	assert.Equal(t, storage.hash, h.Sum(nil))
+}
+
+// slowStorage blocks the downloads of the block key until release is closed
+type slowStorage struct {
+	*TestStorage
+	key     string
+	release chan struct{}
+
+	m     sync.Mutex
+	count map[string]int
+}
+
+func (s *slowStorage) Get(key []byte) (io.ReadCloser, error) {
+	s.m.Lock()
+	s.count[string(key)]++
+	s.m.Unlock()
+
+	if string(key) == s.key {
+		<-s.release
+	}
+
+	return s.TestStorage.Get(key)
+}
+
+func TestSparseConcurrentRead(t *testing.T) {
+	data, blocks, err := MakeStorage(10)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	storage := &slowStorage{
+		TestStorage: data,
+		key:         "block-0",
+		release:     make(chan struct{}),
+		count:       make(map[string]int),
+	}
+
+	m := &TestMeta{id: "abcdef", blocks: blocks, size: 10 * ChunkSize}
+	cache := NewCache(t.TempDir(), storage)
+
+	f, blocksMap, err := cache.Sparse(m)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	file := newSparseFile(f, blocksMap, NewDownloader(storage, m), &cache.inflight, m)
+	defer file.Release()
+
+	read := func(off int64) fuse.Status {
+		_, status := file.Read(make([]byte, 100), off)
+		return status
+	}
+
+	// two reads of the blocked first block
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Equal(t, fuse.OK, read(0))
+		}()
+	}
+
+	// a read of another block is not held by the pending download
+	done := make(chan fuse.Status)
+	go func() { done <- read(5 * ChunkSize) }()
+
+	select {
+	case status := <-done:
+		assert.Equal(t, fuse.OK, status)
+	case <-time.After(5 * time.Second):
+		t.Fatal("read waits for the download of another block")
+	}
+
+	close(storage.release)
+	wg.Wait()
+
+	assert.Equal(t, 1, storage.count["block-0"])
+	assert.Equal(t, 1, storage.count["block-5"])
+}
@@ -0,0 +1,177 @@
+package rofs
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/threefoldtech/0-fs/meta"
+)
+
+// InodeMode defines how inode numbers are reported by the filesystem
+type InodeMode int
+
+// InodeMode values
+const (
+	// CacheInodes reports the inode of the cache file for regular files and
+	// lets fuse pick the inode of all other entries. Inode numbers change
+	// between mounts and each path is a separate file
+	CacheInodes InodeMode = iota
+	// StableInodes derives the inode number from the entry path, so it is
+	// the same across mounts (and cache resets)
+	StableInodes
+	// HardlinkInodes is like StableInodes, but files with the same content
+	// (same blocks), access and modification time are reported as hard
+	// links of the same inode
+	HardlinkInodes
+)
+
+// String implements fmt.Stringer interface
+func (m InodeMode) String() string {
+	switch m {
+	case CacheInodes:
+		return "cache"
+	case StableInodes:
+		return "stable"
+	case HardlinkInodes:
+		return "hardlink"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseInodeMode parses the inode mode name as returned by InodeMode.String
+func ParseInodeMode(s string) (InodeMode, error) {
+	for _, mode := range []InodeMode{CacheInodes, StableInodes, HardlinkInodes} {
+		if strings.EqualFold(s, mode.String()) {
+			return mode, nil
+		}
+	}
+
+	return CacheInodes, fmt.Errorf("unknown inode mode '%s'", s)
+}
+
+const (
+	rootInode = 1
+)
+
+func hashInode(kind, key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(kind))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	ino := h.Sum64()
+	if ino <= rootInode {
+		// 0 means no inode for fuse, and 1 is the root
+		ino += rootInode + 1
+	}
+
+	return ino
+}
+
+// group returns the key of the hard link group of the file m
+func group(m meta.Meta) string {
+	info := m.Info()
+	return fmt.Sprintf("%s:%d:%d:%o:%d", m.ID(), info.Access.UID, info.Access.GID, info.Access.Mode, info.ModificationTime)
+}
+
+// linked returns true if the file can share its inode with other files
+// of the same content. Empty files all have the same ID, so they are
+// never linked
+func linked(m meta.Meta) bool {
+	return m.Info().Type == meta.RegularType && len(m.Blocks()) != 0
+}
+
+// errStopped stops the links count of a retired store
+var errStopped = errors.New("stopped")
+
+// links counts the files of each hard link group. The counts are computed in
+// the background by walking the whole store once it is set (see Config.countLinks),
+// files are reported with a single link until the counts are ready
+type links struct {
+	counts  atomic.Pointer[map[string]uint32]
+	stopped atomic.Bool
+	// done is closed once the count is finished
+	done chan struct{}
+}
+
+func (l *links) build(store meta.Store) {
+	defer close(l.done)
+
+	counts := make(map[string]uint32)
+	err := walkFiles(store, "", func(m meta.Meta) error {
+		if l.stopped.Load() {
+			return errStopped
+		}
+
+		if linked(m) {
+			counts[group(m)]++
+		}
+		return nil
+	})
+
+	if err == errStopped {
+		return
+	} else if err != nil {
+		log.Errorf("failed to count hard links: %s", err)
+		return
+	}
+
+	l.counts.Store(&counts)
+}
+
+// stop stops the count if it's still running
+func (l *links) stop() {
+	l.stopped.Store(true)
+}
+
+func (l *links) count(m meta.Meta) uint32 {
+	if counts := l.counts.Load(); counts != nil {
+		if count := (*counts)[group(m)]; count != 0 {
+			return count
+		}
+	}
+
+	return 1
+}
+
+// countLinks starts counting the hard links of the store in the background, the
+// store is not closed until the count is done or stopped
+func (c *Config) countLinks(store *storeRef) {
+	store.links.done = make(chan struct{})
+	store.leases.Add(1)
+	go func() {
+		defer store.leases.Done()
+		store.links.build(store.Store)
+	}()
+}
+
+// inode returns the inode number and links count of the entry at path p
+// in the stable modes
+func (c *Config) inode(store *storeRef, p string, m meta.Meta) (ino uint64, nlink uint32) {
+	if m.IsDir() {
+		// . and the entry in the parent, plus .. of each sub directory
+		nlink = 2
+		for _, child := range m.Children() {
+			if child.IsDir() {
+				nlink++
+			}
+		}
+	} else {
+		nlink = 1
+	}
+
+	switch {
+	case p == "":
+		ino = rootInode
+	case c.inodes == HardlinkInodes && linked(m):
+		ino = hashInode("file", group(m))
+		nlink = store.links.count(m)
+	default:
+		ino = hashInode("path", p)
+	}
+
+	return
+}
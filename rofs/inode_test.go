@@ -0,0 +1,186 @@
+package rofs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/stretchr/testify/assert"
+	"github.com/threefoldtech/0-fs/meta"
+)
+
+func TestInodes(t *testing.T) {
+	_, blocks, err := MakeStorage(2)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	// a and b have the same content, c is different
+	a := &TestMeta{id: "same", blocks: blocks[0:1], size: ChunkSize}
+	b := &TestMeta{id: "same", blocks: blocks[0:1], size: ChunkSize}
+	c := &TestMeta{id: "other", blocks: blocks[1:2], size: ChunkSize}
+	// empty files are never linked
+	e1 := &TestMeta{id: "empty"}
+	e2 := &TestMeta{id: "empty"}
+	link := &TestMeta{id: "link", info: &meta.Info{Type: meta.LinkType, LinkTarget: "a"}}
+
+	sub := &testDir{TestMeta: TestMeta{id: "sub"}, children: []meta.Meta{b, c, e2}}
+	bin := &testDir{TestMeta: TestMeta{id: "bin"}}
+	root := &testDir{children: []meta.Meta{sub, bin, a, e1, link}}
+
+	store := TestStore{
+		"": root, "sub": sub, "bin": bin, "a": a, "e1": e1, "link": link,
+		"sub/b": b, "sub/c": c, "sub/e2": e2,
+	}
+
+	getattr := func(fs *filesystem, name string) *fuse.Attr {
+		attr, status := fs.GetAttr(name, nil)
+		if ok := assert.Equal(t, fuse.OK, status, name); !ok {
+			t.Fatal()
+		}
+		return attr
+	}
+
+	stable := &filesystem{Config: NewConfig(nil, store, t.TempDir())}
+	stable.SetInodeMode(StableInodes)
+
+	seen := make(map[uint64]string)
+	for name := range store {
+		ino := getattr(stable, name).Ino
+		assert.NotZero(t, ino, name)
+		if other, ok := seen[ino]; ok {
+			t.Errorf("'%s' and '%s' have the same inode", name, other)
+		}
+		seen[ino] = name
+	}
+
+	assert.Equal(t, uint64(1), getattr(stable, "").Ino)
+	assert.Equal(t, uint32(4), getattr(stable, "").Nlink)
+	assert.Equal(t, uint32(2), getattr(stable, "sub").Nlink)
+	assert.Equal(t, uint32(1), getattr(stable, "a").Nlink)
+
+	// inodes are the same across mounts
+	again := &filesystem{Config: NewConfig(nil, store, t.TempDir())}
+	again.SetInodeMode(StableInodes)
+	for name := range store {
+		assert.Equal(t, getattr(stable, name).Ino, getattr(again, name).Ino, name)
+	}
+
+	hardlink := &filesystem{Config: NewConfig(nil, store, t.TempDir())}
+	hardlink.SetInodeMode(HardlinkInodes)
+	<-hardlink.store.Load().links.done
+
+	attrA, attrB := getattr(hardlink, "a"), getattr(hardlink, "sub/b")
+	assert.Equal(t, attrA.Ino, attrB.Ino)
+	assert.Equal(t, uint32(2), attrA.Nlink)
+	assert.Equal(t, uint32(2), attrB.Nlink)
+
+	attrC := getattr(hardlink, "sub/c")
+	assert.NotEqual(t, attrA.Ino, attrC.Ino)
+	assert.Equal(t, uint32(1), attrC.Nlink)
+
+	assert.NotEqual(t, getattr(hardlink, "e1").Ino, getattr(hardlink, "sub/e2").Ino)
+	assert.Equal(t, uint32(1), getattr(hardlink, "e1").Nlink)
+	assert.Equal(t, getattr(stable, "link").Ino, getattr(hardlink, "link").Ino)
+
+	// files with the same content but different metadata are not linked
+	modified := &TestMeta{id: "same", blocks: blocks[0:1], info: &meta.Info{
+		Type:             meta.RegularType,
+		Size:             ChunkSize,
+		FileBlockSize:    ChunkSize,
+		ModificationTime: 10,
+	}}
+	hardlink.SetMetaStore(TestStore{"": root, "a": a, "sub/b": modified})
+	assert.NotEqual(t, getattr(hardlink, "a").Ino, getattr(hardlink, "sub/b").Ino)
+}
+
+func TestLinksBackground(t *testing.T) {
+	_, blocks, err := MakeStorage(1)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	a := &TestMeta{id: "same", blocks: blocks, size: ChunkSize}
+	b := &TestMeta{id: "same", blocks: blocks, size: ChunkSize}
+	root := &testDir{children: []meta.Meta{a, b}}
+	store := &ClosableStore{
+		TestStore: TestStore{"": root, "a": a, "b": b},
+		closed:    make(chan struct{}),
+	}
+
+	fs := &filesystem{Config: NewConfig(nil, store, t.TempDir())}
+	ref := fs.store.Load()
+
+	// a single link is reported until the links are counted
+	assert.Equal(t, uint32(1), ref.links.count(a))
+
+	fs.SetInodeMode(HardlinkInodes)
+	<-ref.links.done
+	assert.Equal(t, uint32(2), ref.links.count(a))
+
+	// the links of the new store are counted on swap
+	fs.SetMetaStore(TestStore{"": &testDir{children: []meta.Meta{a}}, "a": a})
+	next := fs.store.Load()
+	<-next.links.done
+	assert.Equal(t, uint32(1), next.links.count(a))
+
+	select {
+	case <-store.closed:
+	case <-time.After(time.Second):
+		t.Fatal("old store was not closed")
+	}
+}
+
+func TestParseInodeMode(t *testing.T) {
+	for _, mode := range []InodeMode{CacheInodes, StableInodes, HardlinkInodes} {
+		parsed, err := ParseInodeMode(mode.String())
+		if ok := assert.NoError(t, err); ok {
+			assert.Equal(t, mode, parsed)
+		}
+	}
+
+	_, err := ParseInodeMode("random")
+	assert.Error(t, err)
+}
+
+// namedMeta is a file with a name other than its id
+type namedMeta struct {
+	*TestMeta
+	name string
+}
+
+func (m *namedMeta) Name() string { return m.name }
+
+func TestLinksLayered(t *testing.T) {
+	_, blocks, err := MakeStorage(1)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	// deep and b have the same content, x too but it's deleted by the upper layer
+	same := func(name string) meta.Meta {
+		return &namedMeta{TestMeta: &TestMeta{id: "same", blocks: blocks, size: ChunkSize}, name: name}
+	}
+
+	lower := newTestStore(map[string]meta.Meta{"usr/lib/sub/deep": same("deep"), "usr/lib/x": same("x")})
+	upper := newTestStore(map[string]meta.Meta{"usr/lib/b": same("b"), "usr/lib/.wh.x": &TestMeta{id: ".wh.x"}})
+
+	for _, store := range []meta.Store{meta.Layered(lower, upper), struct{ meta.Store }{meta.Layered(lower, upper)}} {
+		fs := &filesystem{Config: NewConfig(nil, store, t.TempDir())}
+		fs.SetInodeMode(HardlinkInodes)
+		<-fs.store.Load().links.done
+
+		deep, status := fs.GetAttr("usr/lib/sub/deep", nil)
+		if ok := assert.Equal(t, fuse.OK, status); !ok {
+			t.Fatal()
+		}
+		b, status := fs.GetAttr("usr/lib/b", nil)
+		if ok := assert.Equal(t, fuse.OK, status); !ok {
+			t.Fatal()
+		}
+
+		assert.Equal(t, deep.Ino, b.Ino)
+		assert.Equal(t, uint32(2), deep.Nlink)
+		assert.Equal(t, uint32(2), b.Nlink)
+	}
+}
@@ -0,0 +1,51 @@
+package rofs
+
+import (
+	"syscall"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"golang.org/x/sys/unix"
+)
+
+var (
+	operations = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "zerofs",
+		Subsystem: "fuse",
+		Name:      "operation_duration_seconds",
+		Help:      "Latency of the fuse operations by operation and returned status",
+		Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 10),
+	}, []string{"operation", "status"})
+
+	cacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "zerofs",
+		Subsystem: "cache",
+		Name:      "requests_total",
+		Help:      "Number of files requested from the local cache by result (hit or miss)",
+	}, []string{"result"})
+)
+
+// observe records the latency and status of a fuse operation, it must be
+// deferred at the start of the operation with a pointer to the returned status
+func observe(operation string, start time.Time, status *fuse.Status) {
+	name := "OK"
+	if !status.Ok() {
+		name = unix.ErrnoName(syscall.Errno(*status))
+		if len(name) == 0 {
+			name = status.String()
+		}
+	}
+
+	operations.WithLabelValues(operation, name).Observe(time.Since(start).Seconds())
+}
+
+func cacheResult(hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+
+	cacheRequests.WithLabelValues(result).Inc()
+}
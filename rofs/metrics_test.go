@@ -0,0 +1,43 @@
+package rofs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObserve(t *testing.T) {
+	operations.Reset()
+
+	status := fuse.ENOENT
+	observe("GetAttr", time.Now(), &status)
+	status = fuse.OK
+	observe("GetAttr", time.Now(), &status)
+	observe("GetAttr", time.Now(), &status)
+
+	// one series per operation and status
+	assert.Equal(t, 2, testutil.CollectAndCount(operations))
+
+	var metric dto.Metric
+	if err := operations.WithLabelValues("GetAttr", "ENOENT").(prometheus.Histogram).Write(&metric); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, uint64(1), metric.GetHistogram().GetSampleCount())
+}
+
+func TestCacheResult(t *testing.T) {
+	hits := testutil.ToFloat64(cacheRequests.WithLabelValues("hit"))
+	misses := testutil.ToFloat64(cacheRequests.WithLabelValues("miss"))
+
+	cacheResult(true)
+	cacheResult(false)
+	cacheResult(false)
+
+	assert.Equal(t, hits+1, testutil.ToFloat64(cacheRequests.WithLabelValues("hit")))
+	assert.Equal(t, misses+2, testutil.ToFloat64(cacheRequests.WithLabelValues("miss")))
+}
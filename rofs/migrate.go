@@ -0,0 +1,146 @@
+package rofs
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+
+	"github.com/threefoldtech/0-fs/meta"
+)
+
+// Migrate copies the files of store that are kept in the cache with the file
+// layout (see FileCache) to the block layout (see BlockCache). Only the blocks
+// that are already downloaded are copied, and their hash is validated. If remove
+// is set, the migrated cache files are removed unless they are in use. It returns
+// the number of migrated files
+func (c *Cache) Migrate(store meta.Store, remove bool) (int, error) {
+	seen := make(map[string]struct{})
+	count := 0
+
+	err := walkFiles(store, "", func(m meta.Meta) error {
+		if len(m.Blocks()) == 0 {
+			return nil
+		}
+
+		id := m.ID()
+		if _, ok := seen[id]; ok {
+			return nil
+		}
+		seen[id] = struct{}{}
+
+		migrated, err := c.migrate(m)
+		if err != nil {
+			log.Errorf("failed to migrate '%s': %s", m.Name(), err)
+			return nil
+		}
+
+		if !migrated {
+			return nil
+		}
+
+		log.Debugf("migrated cache file of '%s'", m.Name())
+		count++
+
+		if !remove {
+			return nil
+		}
+
+		if evicted, err := c.evict(c.path(id)); err != nil {
+			log.Errorf("failed to remove cache file of '%s': %s", m.Name(), err)
+		} else if !evicted {
+			log.Warningf("cache file of '%s' is in use", m.Name())
+		}
+
+		return nil
+	})
+
+	return count, err
+}
+
+// migrate copies the downloaded blocks of the cache file of m to the block
+// cache. It returns false if m has no (valid) cache file
+func (c *Cache) migrate(m meta.Meta) (bool, error) {
+	name := c.path(m.ID())
+	file, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	defer file.Close()
+
+	// wait for a download in progress
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_SH); err != nil {
+		return false, err
+	}
+
+	defer func() {
+		if err := syscall.Flock(int(file.Fd()), syscall.LOCK_UN); err != nil {
+			log.Error("failed to release file", err)
+		}
+	}()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	info := m.Info()
+	if stat.Size() != int64(info.Size) {
+		// not (or partially) downloaded by CheckAndGet
+		return false, nil
+	}
+
+	if info.FileBlockSize == 0 {
+		return false, fmt.Errorf("block size is not set")
+	}
+
+	present := func(int) bool { return true }
+	if c.partial(name) {
+		blocks, err := openBlockMap(c.mapPath(name), len(m.Blocks()), false)
+		if err != nil {
+			return false, err
+		}
+
+		defer blocks.Close()
+		present = blocks.Has
+	}
+
+	bs := int64(info.FileBlockSize)
+	for index, block := range m.Blocks() {
+		if !present(index) {
+			continue
+		}
+
+		dest := c.blockPath(block.Key)
+		if _, err := os.Stat(dest); err == nil {
+			continue
+		}
+
+		start := int64(index) * bs
+		length := int64(info.Size) - start
+		if length > bs {
+			length = bs
+		}
+
+		if length <= 0 {
+			return false, fmt.Errorf("block %d is out of the file", index)
+		}
+
+		data := make([]byte, length)
+		if _, err := file.ReadAt(data, start); err != nil {
+			return false, err
+		}
+
+		if err := checkBlock(block, data); err != nil {
+			return false, err
+		}
+
+		if err := storeBlock(dest, data); err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
@@ -0,0 +1,110 @@
+package rofs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/threefoldtech/0-fs/meta"
+)
+
+func TestMigrate(t *testing.T) {
+	storage, blocks, err := MakeStorage(5)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	// a is fully downloaded, only block 3 of b is downloaded
+	// and c is not in the cache
+	a := &TestMeta{id: "a", blocks: blocks[0:2], size: 2 * ChunkSize}
+	b := &TestMeta{id: "b", blocks: blocks[2:4], size: 2 * ChunkSize}
+	c := &TestMeta{id: "c", blocks: blocks[4:5], size: ChunkSize}
+	root := &testDir{children: []meta.Meta{a, b, c}}
+	store := TestStore{"": root, "a": a, "b": b, "c": c}
+
+	dir := t.TempDir()
+	cache := NewCache(dir, storage)
+
+	file, err := cache.CheckAndGet(a)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	file.Close()
+
+	file, blockMap, err := cache.Sparse(b)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	sparse := newSparseFile(file, blockMap, NewDownloader(storage, b), &cache.inflight, b)
+	if ok := assert.NoError(t, sparse.fetch(ChunkSize, ChunkSize+1)); !ok {
+		t.Fatal()
+	}
+	sparse.Release()
+
+	blockCache := NewCache(dir, storage)
+	blockCache.SetMode(BlockCache)
+
+	count, err := blockCache.Migrate(store, true)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	assert.Equal(t, 2, count)
+
+	for i, block := range blocks {
+		data, err := os.ReadFile(blockCache.blockPath(block.Key))
+		if i == 0 || i == 1 || i == 3 {
+			if ok := assert.NoError(t, err, "block %d", i); ok {
+				assert.Equal(t, plain(t, storage, block), data, "block %d", i)
+			}
+		} else {
+			assert.True(t, os.IsNotExist(err), "block %d", i)
+		}
+	}
+
+	for _, m := range []meta.Meta{a, b} {
+		_, err = os.Stat(cache.path(m.ID()))
+		assert.True(t, os.IsNotExist(err))
+		assert.False(t, cache.partial(cache.path(m.ID())))
+	}
+
+	// the migrated blocks are used without downloading them again
+	delete(storage.data, string(blocks[0].Key))
+	delete(storage.data, string(blocks[1].Key))
+	data := readAll(t, newBlockFile(blockCache, a), int(a.size), 0)
+	assert.Len(t, data, int(a.size))
+}
+
+func TestMigrateCorrupt(t *testing.T) {
+	storage, blocks, err := MakeStorage(1)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	a := &TestMeta{id: "a", blocks: blocks, size: ChunkSize}
+	store := TestStore{"": &testDir{children: []meta.Meta{a}}, "a": a}
+
+	dir := t.TempDir()
+	cache := NewCache(dir, storage)
+	cache.SetMode(BlockCache)
+
+	name := cache.path(a.ID())
+	if ok := assert.NoError(t, os.MkdirAll(filepath.Dir(name), 0755)); !ok {
+		t.Fatal()
+	}
+	if ok := assert.NoError(t, os.WriteFile(name, make([]byte, ChunkSize), 0644)); !ok {
+		t.Fatal()
+	}
+
+	count, err := cache.Migrate(store, true)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	assert.Equal(t, 0, count)
+
+	// corrupt files are kept
+	_, err = os.Stat(name)
+	assert.NoError(t, err)
+	_, err = os.Stat(cache.blockPath(blocks[0].Key))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,250 @@
+package rofs
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/threefoldtech/0-fs/meta"
+)
+
+const (
+	// DefaultPrefetchWorkers is the default number of files prefetched in parallel
+	DefaultPrefetchWorkers = 4
+)
+
+// ReadPathList reads a list of paths, one path per line. Empty lines and
+// lines starting with # are ignored
+func ReadPathList(r io.Reader) ([]string, error) {
+	var paths []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		paths = append(paths, strings.Trim(line, "/"))
+	}
+
+	return paths, scanner.Err()
+}
+
+// walk calls fn for the entry p and all its content, directories are
+// walked recursively. Sub directories are looked up in the store, so the
+// directories of layered stores are merged at each level
+func walk(store meta.Store, p string, fn func(m meta.Meta) error) error {
+	m, ok := store.Get(p)
+	if !ok {
+		return fmt.Errorf("'%s' not found", p)
+	}
+
+	if !m.IsDir() {
+		return fn(m)
+	}
+
+	if walker, ok := store.(meta.Walker); ok {
+		return walker.Walk(p, func(_ string, m meta.Meta) error {
+			return fn(m)
+		})
+	}
+
+	var walk func(p string, m meta.Meta) error
+	walk = func(p string, m meta.Meta) error {
+		if err := fn(m); err != nil {
+			return err
+		}
+
+		for _, child := range m.Children() {
+			if !child.IsDir() {
+				if err := fn(child); err != nil {
+					return err
+				}
+				continue
+			}
+
+			q := path.Join(p, child.Name())
+			dir, ok := store.Get(q)
+			if !ok {
+				continue
+			}
+
+			if err := walk(q, dir); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return walk(p, m)
+}
+
+// walkFiles calls fn for all the regular files of the entry p
+func walkFiles(store meta.Store, p string, fn func(m meta.Meta) error) error {
+	return walk(store, p, func(m meta.Meta) error {
+		if m.Info().Type != meta.RegularType {
+			return nil
+		}
+
+		return fn(m)
+	})
+}
+
+// resolve returns the regular files of the entries listed in paths, in order and
+// without duplicates. Paths that are not found are logged and skipped
+func resolve(ctx context.Context, store meta.Store, paths []string) []meta.Meta {
+	var list []meta.Meta
+	seen := make(map[string]struct{})
+	for _, p := range paths {
+		err := walkFiles(store, p, func(m meta.Meta) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			if _, ok := seen[m.ID()]; !ok {
+				seen[m.ID()] = struct{}{}
+				list = append(list, m)
+			}
+
+			return nil
+		})
+
+		if err == context.Canceled || err == context.DeadlineExceeded {
+			return nil
+		} else if err != nil {
+			log.Warningf("failed to prefetch '%s': %s", p, err)
+		}
+	}
+
+	return list
+}
+
+// fetch makes sure the file content is in the cache
+func (c *Cache) fetch(m meta.Meta) error {
+	if c.mode == BlockCache {
+		return c.fetchBlocks(m)
+	}
+
+	file, err := c.CheckAndGet(m)
+	if err != nil {
+		return err
+	}
+
+	return file.Close()
+}
+
+// Prefetch downloads the files of store listed in paths to the cache, in the given
+// order. If a path is a directory its whole content is prefetched. Up to workers
+// files are downloaded in parallel. Files that fail to download or paths that are
+// not found are logged and skipped. It returns the number of prefetched files
+func (c *Cache) Prefetch(ctx context.Context, store meta.Store, paths []string, workers int) int {
+	return c.prefetch(ctx, resolve(ctx, store, paths), workers)
+}
+
+// prefetch downloads the files in list to the cache, see Prefetch
+func (c *Cache) prefetch(ctx context.Context, list []meta.Meta, workers int) int {
+	if workers <= 0 {
+		workers = DefaultPrefetchWorkers
+	}
+
+	feed := make(chan meta.Meta)
+	var (
+		wg    sync.WaitGroup
+		m     sync.Mutex
+		count int
+	)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for entry := range feed {
+				if err := c.fetch(entry); err != nil {
+					log.Errorf("failed to prefetch '%s': %s", entry.Name(), err)
+					continue
+				}
+
+				m.Lock()
+				count++
+				m.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for _, entry := range list {
+		select {
+		case feed <- entry:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+
+	close(feed)
+	wg.Wait()
+
+	return count
+}
+
+// Prefetch downloads the files listed in paths from the current meta store to the
+// cache in the background (see Cache.Prefetch), it blocks until all files are
+// prefetched or ctx is canceled. The meta store is only held while the paths are
+// resolved, so a store swap never waits for the downloads. The replaced storages
+// are closed once the prefetch is done
+func (c *Config) Prefetch(ctx context.Context, paths []string, workers int) int {
+	store := c.acquire()
+	list := resolve(ctx, store.Store, paths)
+	entries := make([]*bound, len(list))
+	for i, m := range list {
+		entries[i] = store.bind(m)
+		list[i] = entries[i]
+	}
+	store.release()
+
+	// the storages of the store are kept open until the prefetch is done
+	defer func() {
+		for _, entry := range entries {
+			entry.release()
+		}
+	}()
+
+	return c.cache.prefetch(ctx, list, workers)
+}
+
+// recorder writes the paths of the opened files, each path is written once
+type recorder struct {
+	w    io.Writer
+	m    sync.Mutex
+	seen map[string]struct{}
+}
+
+func (r *recorder) record(name string) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if _, ok := r.seen[name]; ok {
+		return
+	}
+
+	r.seen[name] = struct{}{}
+	if _, err := fmt.Fprintln(r.w, name); err != nil {
+		log.Errorf("failed to record access to '%s': %s", name, err)
+	}
+}
+
+// Record starts writing the path of each file opened through the filesystem to w,
+// in the order of first access, so the list can be used later to prefetch the files
+// (see ReadPathList). A nil w stops recording
+func (c *Config) Record(w io.Writer) {
+	if w == nil {
+		c.recorder.Store(nil)
+		return
+	}
+
+	c.recorder.Store(&recorder{w: w, seen: make(map[string]struct{})})
+}
@@ -0,0 +1,265 @@
+package rofs
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/threefoldtech/0-fs/meta"
+)
+
+type testDir struct {
+	TestMeta
+	children []meta.Meta
+}
+
+func (d *testDir) IsDir() bool              { return true }
+func (d *testDir) Children() []meta.Meta    { return d.children }
+func (d *testDir) Info() meta.Info          { return meta.Info{Type: meta.DirType} }
+func (d *testDir) Blocks() []meta.BlockInfo { return nil }
+
+func TestPrefetch(t *testing.T) {
+	storage, blocks, err := MakeStorage(6)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	a := &TestMeta{id: "a", blocks: blocks[0:2], size: 2 * ChunkSize}
+	b := &TestMeta{id: "b", blocks: blocks[2:4], size: 2 * ChunkSize}
+	c := &TestMeta{id: "c", blocks: blocks[4:6], size: 2 * ChunkSize}
+	bin := &testDir{TestMeta: TestMeta{id: "bin"}, children: []meta.Meta{a, b}}
+	root := &testDir{children: []meta.Meta{bin, c}}
+
+	store := TestStore{"": root, "bin": bin, "bin/a": a, "bin/b": b, "c": c}
+	cache := NewCache(t.TempDir(), storage)
+
+	count := cache.Prefetch(context.Background(), store, []string{"bin", "missing", "bin/a"}, 2)
+	assert.Equal(t, 2, count)
+
+	for _, m := range []*TestMeta{a, b} {
+		stat, err := os.Stat(cache.path(m.ID()))
+		if ok := assert.NoError(t, err, m.ID()); ok {
+			assert.Equal(t, int64(m.size), stat.Size(), m.ID())
+		}
+		assert.False(t, cache.partial(cache.path(m.ID())))
+	}
+
+	_, err = os.Stat(cache.path(c.ID()))
+	assert.True(t, os.IsNotExist(err))
+
+	// a canceled prefetch stops
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, 0, cache.Prefetch(ctx, store, []string{"c"}, 2))
+}
+
+// newTestStore creates a store with the given files, the parent directories are
+// created automatically
+func newTestStore(files map[string]meta.Meta) TestStore {
+	store := TestStore{"": &testDir{}}
+
+	var dir func(p string) *testDir
+	dir = func(p string) *testDir {
+		if m, ok := store[p]; ok {
+			return m.(*testDir)
+		}
+
+		d := &testDir{TestMeta: TestMeta{id: path.Base(p)}}
+		parent := dir(strings.TrimSuffix(path.Dir(p), "."))
+		parent.children = append(parent.children, d)
+		store[p] = d
+		return d
+	}
+
+	for p, m := range files {
+		parent := dir(strings.TrimSuffix(path.Dir(p), "."))
+		parent.children = append(parent.children, m)
+		store[p] = m
+	}
+
+	return store
+}
+
+// newLayeredStore creates a store of two layers, both layers have files in
+// usr/lib, the lower one has a file in a sub directory of usr/lib, and the
+// upper one deletes a file of the lower one
+func newLayeredStore(t *testing.T) (store meta.Store, storage *TestStorage, visible []*TestMeta, deleted *TestMeta) {
+	storage, blocks, err := MakeStorage(4)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	deep := &TestMeta{id: "deep", blocks: blocks[0:1], size: ChunkSize}
+	x := &TestMeta{id: "x", blocks: blocks[1:2], size: ChunkSize}
+	b := &TestMeta{id: "b", blocks: blocks[2:3], size: ChunkSize}
+	whiteout := &TestMeta{id: meta.WhiteoutPrefix + "x", blocks: blocks[3:4], size: ChunkSize}
+
+	lower := newTestStore(map[string]meta.Meta{"usr/lib/sub/deep": deep, "usr/lib/x": x})
+	upper := newTestStore(map[string]meta.Meta{"usr/lib/b": b, "usr/lib/" + whiteout.id: whiteout})
+
+	return meta.Layered(lower, upper), storage, []*TestMeta{deep, b}, x
+}
+
+func TestPrefetchLayered(t *testing.T) {
+	layered, storage, visible, deleted := newLayeredStore(t)
+
+	// stores that can't be walked are walked one merged directory at a time
+	for _, store := range []meta.Store{layered, struct{ meta.Store }{layered}} {
+		cache := NewCache(t.TempDir(), storage)
+		assert.Equal(t, len(visible), cache.Prefetch(context.Background(), store, []string{"usr"}, 2))
+
+		for _, m := range visible {
+			assert.False(t, cache.partial(cache.path(m.ID())), m.ID())
+			_, err := os.Stat(cache.path(m.ID()))
+			assert.NoError(t, err, m.ID())
+		}
+
+		_, err := os.Stat(cache.path(deleted.ID()))
+		assert.True(t, os.IsNotExist(err))
+	}
+}
+
+// walkerStore is a store that implements meta.Walker, the walked entries
+// are the ones of the store with the walked path as prefix
+type walkerStore struct {
+	TestStore
+	walked []string
+}
+
+func (s *walkerStore) Walk(p string, fn meta.WalkFn) error {
+	s.walked = append(s.walked, p)
+	for name, m := range s.TestStore {
+		if name == p || strings.HasPrefix(name, p+"/") {
+			if err := fn(name, m); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func TestConfigPrefetchWalker(t *testing.T) {
+	storage, blocks, err := MakeStorage(2)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	a := &TestMeta{id: "a", blocks: blocks[0:1], size: ChunkSize}
+	b := &TestMeta{id: "b", blocks: blocks[1:2], size: ChunkSize}
+	// the directory has no children, its files are only found by walking the store
+	bin := &testDir{TestMeta: TestMeta{id: "bin"}}
+	store := &walkerStore{TestStore: TestStore{"bin": bin, "bin/a": a, "bin/b": b}}
+
+	cfg := NewConfig(storage, store, t.TempDir())
+	assert.Equal(t, 2, cfg.Prefetch(context.Background(), []string{"bin"}, 1))
+	assert.Equal(t, []string{"bin"}, store.walked)
+}
+
+// blockingStorage blocks all downloads until release is closed
+type blockingStorage struct {
+	*TestStorage
+	started chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (s *blockingStorage) Get(key []byte) (io.ReadCloser, error) {
+	s.once.Do(func() { close(s.started) })
+	<-s.release
+	return s.TestStorage.Get(key)
+}
+
+func TestConfigPrefetchSwap(t *testing.T) {
+	storage, blocks, err := MakeStorage(1)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	a := &TestMeta{id: "a", blocks: blocks, size: ChunkSize}
+	old := &ClosableStore{TestStore: TestStore{"a": a}, closed: make(chan struct{})}
+	blocking := &blockingStorage{
+		TestStorage: storage,
+		started:     make(chan struct{}),
+		release:     make(chan struct{}),
+	}
+
+	cfg := NewConfig(blocking, old, t.TempDir())
+	done := make(chan int)
+	go func() {
+		done <- cfg.Prefetch(context.Background(), []string{"a"}, 1)
+	}()
+
+	<-blocking.started
+
+	// the store is not held while the files are downloaded, but it's
+	// only closed once the downloads are done
+	cfg.SetMetaStore(TestStore{"a": a})
+	acquired := make(chan struct{})
+	go func() {
+		current := cfg.acquire()
+		current.release()
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("new store can't be acquired during the prefetch")
+	}
+
+	select {
+	case <-old.closed:
+		t.Fatal("old store closed during the prefetch")
+	default:
+	}
+
+	close(blocking.release)
+	assert.Equal(t, 1, <-done)
+
+	select {
+	case <-old.closed:
+	case <-time.After(time.Second):
+		t.Fatal("old store was not closed after the prefetch")
+	}
+}
+
+func TestRecord(t *testing.T) {
+	storage, blocks, err := MakeStorage(2)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	a := &TestMeta{id: "a", blocks: blocks[0:1], size: ChunkSize}
+	b := &TestMeta{id: "b", blocks: blocks[1:2], size: ChunkSize}
+	store := TestStore{"bin/a": a, "b": b}
+
+	fs := &filesystem{Config: NewConfig(storage, store, t.TempDir())}
+
+	var recorded bytes.Buffer
+	fs.Record(&recorded)
+
+	for _, name := range []string{"bin/a", "b", "bin/a", "missing"} {
+		if file, status := fs.Open(name, 0, nil); file != nil && status.Ok() {
+			file.Release()
+		}
+	}
+
+	fs.Record(nil)
+	if file, _ := fs.Open("b", 0, nil); file != nil {
+		file.Release()
+	}
+
+	assert.Equal(t, "bin/a\nb\n", recorded.String())
+
+	paths, err := ReadPathList(strings.NewReader("# boot profile\n/bin/a\n\nb\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"bin/a", "b"}, paths)
+}
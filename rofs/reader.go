@@ -0,0 +1,83 @@
+package rofs
+
+import (
+	"io"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/hanwen/go-fuse/v2/fuse/nodefs"
+	"github.com/threefoldtech/0-fs/meta"
+)
+
+// open opens the regular file m for reading through the cache, only the blocks
+// that are read are downloaded
+func (c *Cache) open(m meta.Meta) (nodefs.File, error) {
+	if c.Mode() == BlockCache {
+		// file content is read from the shared blocks
+		return newBlockFile(c, m), nil
+	}
+
+	f, blocks, err := c.Sparse(m)
+	if err != nil {
+		return nil, err
+	}
+
+	if blocks == nil {
+		//file is fully downloaded
+		return nodefs.NewLoopbackFile(f), nil
+	}
+
+	return newSparseFile(f, blocks, NewDownloader(c.storageOf(m), m), &c.inflight, m), nil
+}
+
+// Reader reads the content of a file through the cache, blocks are downloaded
+// when they are read and kept in the cache so later reads (from the mount or
+// other readers) don't download them again
+type Reader struct {
+	file nodefs.File
+	size int64
+}
+
+var _ io.ReaderAt = (*Reader)(nil)
+
+// Reader opens the regular file m for reading, the reader must be closed
+// to release the cache file
+func (c *Cache) Reader(m meta.Meta) (*Reader, error) {
+	file, err := c.open(m)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Reader{file: file, size: int64(m.Info().Size)}, nil
+}
+
+// ReadAt implements io.ReaderAt
+func (r *Reader) ReadAt(p []byte, off int64) (int, error) {
+	if off >= r.size {
+		return 0, io.EOF
+	}
+
+	result, status := r.file.Read(p, off)
+	if status != fuse.OK {
+		return 0, syscall.Errno(status)
+	}
+
+	data, status := result.Bytes(p)
+	n := copy(p, data)
+	result.Done()
+	if status != fuse.OK {
+		return 0, syscall.Errno(status)
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+// Close releases the cache file
+func (r *Reader) Close() error {
+	r.file.Release()
+	return nil
+}
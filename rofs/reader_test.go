@@ -0,0 +1,62 @@
+package rofs
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReader(t *testing.T) {
+	storage, blocks, err := MakeStorage(3)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	var content []byte
+	for _, block := range blocks {
+		content = append(content, plain(t, storage, block)...)
+	}
+
+	for _, mode := range []CacheMode{FileCache, BlockCache} {
+		// test blocks are full, so the file size must be a multiple of the chunk size
+		// for the file cache to consider the cache file complete
+		a := &TestMeta{id: "a", blocks: blocks, size: 3 * ChunkSize}
+		cache := NewCache(t.TempDir(), storage)
+		cache.SetMode(mode)
+
+		reader, err := cache.Reader(a)
+		if ok := assert.NoError(t, err, mode.String()); !ok {
+			continue
+		}
+
+		// a read that spans over blocks 0 and 1
+		buf := make([]byte, ChunkSize)
+		n, err := reader.ReadAt(buf, 100)
+		assert.NoError(t, err, mode.String())
+		assert.Equal(t, content[100:ChunkSize+100], buf[:n], mode.String())
+
+		// a read past the end of the file
+		n, err = reader.ReadAt(buf, 2*ChunkSize+100)
+		assert.Equal(t, io.EOF, err, mode.String())
+		assert.Equal(t, content[2*ChunkSize+100:a.size], buf[:n], mode.String())
+
+		n, err = reader.ReadAt(buf, int64(a.size))
+		assert.Equal(t, io.EOF, err, mode.String())
+		assert.Equal(t, 0, n, mode.String())
+		assert.NoError(t, reader.Close())
+
+		// the blocks are read from the cache
+		missing := NewCache(cache.cache, &TestStorage{data: map[string][]byte{}})
+		missing.SetMode(mode)
+		reader, err = missing.Reader(a)
+		if ok := assert.NoError(t, err, mode.String()); !ok {
+			continue
+		}
+
+		n, err = reader.ReadAt(buf, 100)
+		assert.NoError(t, err, mode.String())
+		assert.Equal(t, content[100:ChunkSize+100], buf[:n], mode.String())
+		assert.NoError(t, reader.Close())
+	}
+}
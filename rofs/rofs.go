@@ -1,8 +1,14 @@
 package rofs
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/hanwen/go-fuse/v2/fuse"
 	"github.com/hanwen/go-fuse/v2/fuse/nodefs"
@@ -10,6 +16,7 @@ import (
 	"github.com/op/go-logging"
 	"github.com/threefoldtech/0-fs/meta"
 	"github.com/threefoldtech/0-fs/storage"
+	"golang.org/x/sys/unix"
 )
 
 const (
@@ -23,14 +30,110 @@ var (
 // Config represents a filesystem configuration object
 // Configuration objects can be used to manipulate some filesystem flags in runtime
 type Config struct {
-	store meta.Store
-	cache Cache
+	store    atomic.Pointer[storeRef]
+	nodeFs   atomic.Pointer[pathfs.PathNodeFs]
+	cache    *Cache
+	recorder atomic.Pointer[recorder]
+	inodes   InodeMode
+	space    atomic.Pointer[string]
+	// m serializes the store swaps
+	m sync.Mutex
 }
 
-// SetMetaStore sets the filesystem meta store in runtime.
+// SetMetaStore sets the filesystem meta store in runtime. The swap is atomic, operations
+// in progress finish against the old store, which is then closed. Entries cached by the
+// kernel that are changed in the new store are invalidated.
 func (c *Config) SetMetaStore(store meta.Store) {
-	//TODO: should this be done atomically in a way that is synched ?
-	c.store = store
+	c.Reload(store, nil, nil)
+}
+
+// Reload sets the filesystem meta store, data storage and layers storage (see
+// SetLayerStorage) in runtime, in one atomic swap. Operations in progress, and files
+// that are open, keep using the old store and storages, which are closed once they are
+// all done (storages are closed if they implement io.Closer). A nil storage and layers keep the current ones. Entries
+// cached by the kernel that are changed in the new store are invalidated.
+func (c *Config) Reload(store meta.Store, storage storage.Storage, layers map[meta.Store]storage.Storage) {
+	var prev, current *storeRef
+	c.swap(func(next *storeRef) {
+		// both stores are read by invalidate after the swap, the leases keep
+		// them open until it's done
+		prev, current = next.prev, next
+		prev.leases.Add(1)
+		current.leases.Add(1)
+
+		next.Store = store
+		if storage != nil {
+			next.storage = storage
+		}
+		if layers != nil {
+			next.layers = layers
+		}
+	}, func(ref *storeRef) []io.Closer {
+		closers := []io.Closer{ref.Store}
+		if storage != nil || layers != nil {
+			closers = append(closers, ref.storages()...)
+		}
+		return closers
+	})
+
+	if storage != nil {
+		c.cache.SetStorage(storage)
+	}
+	if layers != nil {
+		c.cache.SetLayerStorage(layers)
+	}
+
+	c.invalidate(prev.Store, current.Store)
+	prev.leases.Done()
+	current.leases.Done()
+
+	if nodeFs := c.nodeFs.Load(); nodeFs != nil && c.inodes == HardlinkInodes {
+		// hard link groups of the old store are not valid anymore
+		nodeFs.ForgetClientInodes()
+	}
+}
+
+// SetCacheMode sets how data is kept in the cache, it must be called before the
+// filesystem is mounted. The default is FileCache
+func (c *Config) SetCacheMode(mode CacheMode) {
+	c.cache.SetMode(mode)
+}
+
+// SetStorage sets the filesystem data storage in runtime, see Reload to replace
+// the meta store and the storage at once
+func (c *Config) SetStorage(storage storage.Storage) {
+	c.swap(func(next *storeRef) {
+		next.storage = storage
+	}, func(*storeRef) []io.Closer { return nil })
+	c.cache.SetStorage(storage)
+}
+
+// SetInodeMode sets how inode numbers are reported, it must be called before the
+// filesystem is mounted. The default is CacheInodes
+func (c *Config) SetInodeMode(mode InodeMode) {
+	c.inodes = mode
+	if mode == HardlinkInodes {
+		c.countLinks(c.store.Load())
+	}
+}
+
+// InodeMode returns how inode numbers are reported
+func (c *Config) InodeMode() InodeMode {
+	return c.inodes
+}
+
+// SetLayerStorage sets the storage of each layer of the meta store in runtime,
+// see Cache.SetLayerStorage and Reload
+func (c *Config) SetLayerStorage(layers map[meta.Store]storage.Storage) {
+	c.swap(func(next *storeRef) {
+		next.layers = layers
+	}, func(*storeRef) []io.Closer { return nil })
+	c.cache.SetLayerStorage(layers)
+}
+
+// Evictor keeps the cache within the given budget, it blocks until ctx is canceled
+func (c *Config) Evictor(ctx context.Context, budget Budget) {
+	c.cache.Evictor(ctx, budget, DefaultEvictInterval)
 }
 
 type filesystem struct {
@@ -40,10 +143,14 @@ type filesystem struct {
 
 // NewConfig creates a new filesystem config object with given meta store, and data storage and local cache directory
 func NewConfig(storage storage.Storage, store meta.Store, cache string) *Config {
-	return &Config{
-		store: store,
+	cfg := &Config{
 		cache: NewCache(cache, storage),
 	}
+
+	ref := newStoreRef(store, storage, nil)
+	cfg.store.Store(ref)
+	cfg.countUsage(ref)
+	return cfg
 }
 
 // New creates a new filesystem object with given configuration
@@ -56,21 +163,36 @@ func New(cfg *Config) pathfs.FileSystem {
 	return pathfs.NewReadonlyFileSystem(fs)
 }
 
-func (fs *filesystem) GetAttr(name string, context *fuse.Context) (*fuse.Attr, fuse.Status) {
+func (fs *filesystem) OnMount(nodeFs *pathfs.PathNodeFs) {
+	fs.mounted(nodeFs)
+}
+
+func (fs *filesystem) GetAttr(name string, context *fuse.Context) (attr *fuse.Attr, status fuse.Status) {
 	log.Debugf("GetAttr %s", name)
-	m, ok := fs.store.Get(name)
+	defer observe("GetAttr", time.Now(), &status)
+	store := fs.acquire()
+	defer store.release()
+
+	m, ok := store.Get(name)
 	if !ok {
 		return nil, fuse.ENOENT
 	}
 
+	return fs.attr(store, name, m)
+}
+
+func (fs *filesystem) attr(store *storeRef, name string, m meta.Meta) (*fuse.Attr, fuse.Status) {
 	info := m.Info()
 	if info.Type == meta.UnknownType {
 		return nil, fuse.EIO
 	}
 
 	var ino uint64 = 0
+	var nlink uint32 = 0
 
-	if info.Type == meta.RegularType {
+	if fs.inodes != CacheInodes {
+		ino, nlink = fs.inode(store, name, m)
+	} else if info.Type == meta.RegularType && fs.cache.Mode() == FileCache {
 		stat, err := fs.cache.check(m)
 		if err != nil {
 			return nil, fuse.EIO
@@ -103,6 +225,7 @@ func (fs *filesystem) GetAttr(name string, context *fuse.Context) (*fuse.Attr, f
 	return &fuse.Attr{
 		Ino:    ino,
 		Size:   size,
+		Nlink:  nlink,
 		Atime:  uint64(info.ModificationTime),
 		Mtime:  uint64(info.ModificationTime),
 		Ctime:  uint64(info.CreationTime),
@@ -112,50 +235,69 @@ func (fs *filesystem) GetAttr(name string, context *fuse.Context) (*fuse.Attr, f
 			Uid: access.UID,
 			Gid: access.GID,
 		},
-		Rdev:    major<<8 | minor,
+		Rdev:    uint32(unix.Mkdev(major, minor)),
 		Blksize: blkSize, //4K blocks
 	}, fuse.OK
 }
 
-func (fs *filesystem) Open(name string, flags uint32, context *fuse.Context) (nodefs.File, fuse.Status) {
+func (fs *filesystem) Open(name string, flags uint32, context *fuse.Context) (file nodefs.File, status fuse.Status) {
 	log.Debugf("Open %s", name)
+	defer observe("Open", time.Now(), &status)
 	if flags&fuse.O_ANYWRITE != 0 {
 		return nil, fuse.EPERM
 	}
-	m, ok := fs.store.Get(name)
+	store := fs.acquire()
+	defer store.release()
+
+	m, ok := store.Get(name)
 	if !ok {
 		return nil, fuse.ENOENT
 	}
-	f, err := fs.cache.CheckAndGet(m)
-	if err != nil {
-		log.Errorf("Failed to open/download the file: %s", err)
+
+	if m.Info().Type != meta.RegularType {
+		// special files (devices, fifos) are opened by the kernel
+		// directly and never reach us, anything else can't be opened
+		return nil, fuse.EINVAL
+	}
+
+	if recorder := fs.recorder.Load(); recorder != nil {
+		recorder.record(name)
 	}
 
 	// fetch original attr and store them to reuse
 	// for fd in cache later (no new GetAttr will be done
 	// if the file is already open and it will forward
 	// local cache file attrs)
-	attr, ferr := fs.GetAttr(name, context)
+	attr, ferr := fs.attr(store, name, m)
 	if ferr != fuse.OK {
 		log.Errorf("Failed to fetch original attr: %s", ferr)
 		return nil, ferr
 	}
 
-	file := &WithAttr{
-		File:   nodefs.NewLoopbackFile(f),
-		Source: attr,
+	entry := store.bind(m)
+	inner, err := fs.cache.open(entry)
+	if err != nil {
+		entry.release()
+		log.Errorf("Failed to open the file: %s", err)
+		return nil, fuse.EIO
 	}
 
-	return nodefs.NewReadOnlyFile(file), fuse.OK
+	return nodefs.NewReadOnlyFile(&WithAttr{
+		File:   &leased{File: inner, entry: entry},
+		Source: attr,
+	}), fuse.OK
 }
 
-func (fs *filesystem) OpenDir(name string, context *fuse.Context) ([]fuse.DirEntry, fuse.Status) {
+func (fs *filesystem) OpenDir(name string, context *fuse.Context) (entries []fuse.DirEntry, status fuse.Status) {
 	log.Debugf("OpenDir %s", name)
-	m, ok := fs.store.Get(name)
+	defer observe("OpenDir", time.Now(), &status)
+	store := fs.acquire()
+	defer store.release()
+
+	m, ok := store.Get(name)
 	if !ok {
 		return nil, fuse.ENOENT
 	}
-	var entries []fuse.DirEntry
 	for _, child := range m.Children() {
 		info := child.Info()
 		log.Debugf("child '%s', type: %s", child.Name(), info.Type)
@@ -176,9 +318,13 @@ func (fs *filesystem) Access(name string, mode uint32, context *fuse.Context) fu
 	return fuse.OK
 }
 
-func (fs *filesystem) Readlink(name string, context *fuse.Context) (string, fuse.Status) {
+func (fs *filesystem) Readlink(name string, context *fuse.Context) (target string, status fuse.Status) {
 	log.Debugf("Readlink %s", name)
-	m, ok := fs.store.Get(name)
+	defer observe("Readlink", time.Now(), &status)
+	store := fs.acquire()
+	defer store.release()
+
+	m, ok := store.Get(name)
 	if !ok {
 		return "", fuse.ENOENT
 	}
@@ -189,17 +335,53 @@ func (fs *filesystem) Readlink(name string, context *fuse.Context) (string, fuse
 }
 
 func (fs *filesystem) GetXAttr(name string, attr string, context *fuse.Context) ([]byte, fuse.Status) {
-	log.Debugf("GetXAttr %s", name)
-	return nil, fuse.ENOSYS
+	log.Debugf("GetXAttr %s: %s", name, attr)
+	store := fs.acquire()
+	defer store.release()
+
+	m, ok := store.Get(name)
+	if !ok {
+		return nil, fuse.ENOENT
+	}
+
+	value, ok := m.Info().XAttrs[attr]
+	if !ok {
+		return nil, fuse.ENOATTR
+	}
+
+	return value, fuse.OK
 }
 
 func (fs *filesystem) ListXAttr(name string, context *fuse.Context) ([]string, fuse.Status) {
 	log.Debugf("ListXAttr %s", name)
-	return nil, fuse.ENOSYS
+	store := fs.acquire()
+	defer store.release()
+
+	m, ok := store.Get(name)
+	if !ok {
+		return nil, fuse.ENOENT
+	}
+
+	attrs := m.Info().XAttrs
+	names := make([]string, 0, len(attrs))
+	for attr := range attrs {
+		names = append(names, attr)
+	}
+	sort.Strings(names)
+
+	return names, fuse.OK
+}
+
+// leased is an open file that releases its bound entry once released by the
+// kernel, so the storage the file reads from is kept open as long as the file is
+type leased struct {
+	nodefs.File
+	entry *bound
 }
 
-func (fs *filesystem) StatFs(name string) *fuse.StatfsOut {
-	return &fuse.StatfsOut{}
+func (l *leased) Release() {
+	l.File.Release()
+	l.entry.release()
 }
 
 // WithAttr override nodefs.File with custom GetAttr
@@ -0,0 +1,108 @@
+package rofs
+
+import (
+	"testing"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/stretchr/testify/assert"
+	"github.com/threefoldtech/0-fs/meta"
+	"golang.org/x/sys/unix"
+)
+
+type TestStore map[string]meta.Meta
+
+func (s TestStore) Get(name string) (meta.Meta, bool) {
+	m, ok := s[name]
+	return m, ok
+}
+
+func (s TestStore) Close() error {
+	return nil
+}
+
+func TestGetAttrSpecial(t *testing.T) {
+	store := TestStore{
+		"null": &TestMeta{id: "null", info: &meta.Info{
+			Type:        meta.CharDeviceType,
+			Access:      meta.Access{Mode: 0666},
+			SpecialData: "1,3",
+		}},
+		"sda": &TestMeta{id: "sda", info: &meta.Info{
+			Type:        meta.BlockDeviceType,
+			Access:      meta.Access{Mode: 0660, GID: 6},
+			SpecialData: "8,300",
+		}},
+		"fifo": &TestMeta{id: "fifo", info: &meta.Info{
+			Type:   meta.FIFOType,
+			Access: meta.Access{Mode: 0644},
+		}},
+	}
+
+	fs := &filesystem{Config: NewConfig(nil, store, t.TempDir())}
+
+	attr, status := fs.GetAttr("null", nil)
+	if ok := assert.Equal(t, fuse.OK, status); !ok {
+		t.Fatal()
+	}
+	assert.Equal(t, uint32(unix.S_IFCHR|0666), attr.Mode)
+	assert.Equal(t, uint32(1), unix.Major(uint64(attr.Rdev)))
+	assert.Equal(t, uint32(3), unix.Minor(uint64(attr.Rdev)))
+
+	attr, status = fs.GetAttr("sda", nil)
+	if ok := assert.Equal(t, fuse.OK, status); !ok {
+		t.Fatal()
+	}
+	assert.Equal(t, uint32(unix.S_IFBLK|0660), attr.Mode)
+	assert.Equal(t, uint32(6), attr.Gid)
+	assert.Equal(t, uint32(8), unix.Major(uint64(attr.Rdev)))
+	assert.Equal(t, uint32(300), unix.Minor(uint64(attr.Rdev)))
+
+	attr, status = fs.GetAttr("fifo", nil)
+	if ok := assert.Equal(t, fuse.OK, status); !ok {
+		t.Fatal()
+	}
+	assert.Equal(t, uint32(unix.S_IFIFO|0644), attr.Mode)
+	assert.Equal(t, uint32(0), attr.Rdev)
+
+	_, status = fs.Open("null", 0, nil)
+	assert.Equal(t, fuse.EINVAL, status)
+}
+
+func TestXAttr(t *testing.T) {
+	store := TestStore{
+		"ping": &TestMeta{id: "ping", info: &meta.Info{
+			Type: meta.RegularType,
+			XAttrs: map[string][]byte{
+				"user.comment":        []byte("ping"),
+				"security.capability": []byte("\x01\x00\x00\x02"),
+			},
+		}},
+		"plain": &TestMeta{id: "plain", info: &meta.Info{
+			Type: meta.RegularType,
+		}},
+	}
+
+	fs := &filesystem{Config: NewConfig(nil, store, t.TempDir())}
+
+	names, status := fs.ListXAttr("ping", nil)
+	if ok := assert.Equal(t, fuse.OK, status); !ok {
+		t.Fatal()
+	}
+	assert.Equal(t, []string{"security.capability", "user.comment"}, names)
+
+	value, status := fs.GetXAttr("ping", "security.capability", nil)
+	if ok := assert.Equal(t, fuse.OK, status); !ok {
+		t.Fatal()
+	}
+	assert.Equal(t, []byte("\x01\x00\x00\x02"), value)
+
+	_, status = fs.GetXAttr("ping", "security.selinux", nil)
+	assert.Equal(t, fuse.ENOATTR, status)
+
+	names, status = fs.ListXAttr("plain", nil)
+	assert.Equal(t, fuse.OK, status)
+	assert.Empty(t, names)
+
+	_, status = fs.GetXAttr("missing", "user.comment", nil)
+	assert.Equal(t, fuse.ENOENT, status)
+}
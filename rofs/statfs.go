@@ -0,0 +1,115 @@
+package rofs
+
+import (
+	"sync/atomic"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/threefoldtech/0-fs/meta"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	nameLen = 255
+)
+
+// usage is the space used by the flist. It's computed in the background by walking
+// the whole store once it is set (see Config.countUsage), the usage counted so far is
+// reported until the walk is done
+type usage struct {
+	blocks  atomic.Uint64
+	files   atomic.Uint64
+	stopped atomic.Bool
+	// done is closed once the walk is finished
+	done chan struct{}
+}
+
+func (u *usage) count(store meta.Store) {
+	defer close(u.done)
+
+	err := walk(store, "", func(m meta.Meta) error {
+		if u.stopped.Load() {
+			return errStopped
+		}
+
+		u.files.Add(1)
+		if info := m.Info(); info.Type == meta.RegularType {
+			u.blocks.Add((info.Size + blkSize - 1) / blkSize)
+		}
+		return nil
+	})
+
+	if err != nil && err != errStopped {
+		log.Errorf("failed to compute flist usage: %s", err)
+	}
+}
+
+// stop stops the walk if it's still running
+func (u *usage) stop() {
+	u.stopped.Store(true)
+}
+
+func (u *usage) get() (blocks, files uint64) {
+	return u.blocks.Load(), u.files.Load()
+}
+
+// countUsage starts computing the usage of the store in the background, the
+// store is not closed until the walk is done or stopped
+func (c *Config) countUsage(store *storeRef) {
+	store.usage.done = make(chan struct{})
+	store.leases.Add(1)
+	go func() {
+		defer store.leases.Done()
+		store.usage.count(store.Store)
+	}()
+}
+
+// SetSpacePath sets the directory whose filesystem free space is reported
+// as the free space of the mount. It defaults to the cache directory
+func (c *Config) SetSpacePath(dir string) {
+	c.space.Store(&dir)
+}
+
+func (c *Config) spacePath() string {
+	if dir := c.space.Load(); dir != nil {
+		return *dir
+	}
+
+	return c.cache.cache
+}
+
+// StatFs reports the flist size and number of entries counted so far as used space
+// and files (see usage). Free space and files are the ones of the filesystem where
+// the space path is (see SetSpacePath)
+func (fs *filesystem) StatFs(name string) *fuse.StatfsOut {
+	log.Debugf("StatFs %s", name)
+	store := fs.acquire()
+	blocks, files := store.usage.get()
+	store.release()
+
+	out := &fuse.StatfsOut{
+		Blocks:  blocks,
+		Files:   files,
+		Bsize:   blkSize,
+		Frsize:  blkSize,
+		NameLen: nameLen,
+	}
+
+	var stat unix.Statfs_t
+	if err := unix.Statfs(fs.spacePath(), &stat); err != nil {
+		log.Errorf("failed to stat free space: %s", err)
+		return out
+	}
+
+	size := uint64(stat.Frsize)
+	if size == 0 {
+		size = uint64(stat.Bsize)
+	}
+
+	out.Bfree = stat.Bfree * size / blkSize
+	out.Bavail = stat.Bavail * size / blkSize
+	out.Blocks += out.Bfree
+	out.Ffree = stat.Ffree
+	out.Files += out.Ffree
+
+	return out
+}
@@ -0,0 +1,103 @@
+package rofs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/stretchr/testify/assert"
+	"github.com/threefoldtech/0-fs/meta"
+)
+
+func TestStatFs(t *testing.T) {
+	a := &TestMeta{id: "a", size: 10 * blkSize}
+	b := &TestMeta{id: "b", size: blkSize + 1}
+	link := &TestMeta{id: "link", info: &meta.Info{Type: meta.LinkType, LinkTarget: "a"}}
+	bin := &testDir{TestMeta: TestMeta{id: "bin"}, children: []meta.Meta{b, link}}
+	root := &testDir{children: []meta.Meta{bin, a}}
+
+	store := TestStore{"": root, "bin": bin, "a": a, "bin/b": b, "bin/link": link}
+	fs := &filesystem{Config: NewConfig(nil, store, t.TempDir())}
+	<-fs.store.Load().usage.done
+
+	out := fs.StatFs("")
+	if ok := assert.NotNil(t, out); !ok {
+		t.Fatal()
+	}
+
+	assert.Equal(t, uint32(blkSize), out.Bsize)
+	assert.Equal(t, uint32(blkSize), out.Frsize)
+	// a is 10 blocks, b is 2 blocks
+	assert.Equal(t, uint64(12), out.Blocks-out.Bfree)
+	assert.Equal(t, uint64(5), out.Files-out.Ffree)
+	assert.True(t, out.Bavail <= out.Bfree)
+
+	fs.SetSpacePath(t.TempDir())
+	out = fs.StatFs("")
+	assert.Equal(t, uint64(12), out.Blocks-out.Bfree)
+	assert.NotZero(t, out.Bfree)
+
+	// usage is computed again for the new store
+	fs.SetMetaStore(TestStore{"": &testDir{children: []meta.Meta{a}}, "a": a})
+	<-fs.store.Load().usage.done
+	out = fs.StatFs("")
+	assert.Equal(t, uint64(10), out.Blocks-out.Bfree)
+	assert.Equal(t, uint64(2), out.Files-out.Ffree)
+}
+
+func TestStatFsLayered(t *testing.T) {
+	layered, _, visible, _ := newLayeredStore(t)
+
+	// stores that can't be walked are walked one merged directory at a time
+	for _, store := range []meta.Store{layered, struct{ meta.Store }{layered}} {
+		fs := &filesystem{Config: NewConfig(nil, store, t.TempDir())}
+		<-fs.store.Load().usage.done
+		out := fs.StatFs("")
+		if ok := assert.NotNil(t, out); !ok {
+			t.Fatal()
+		}
+
+		// each visible file is one block, whiteouts and deleted files are not counted
+		assert.Equal(t, uint64(len(visible)), out.Blocks-out.Bfree)
+		// the root, usr, usr/lib, usr/lib/sub and the visible files
+		assert.Equal(t, uint64(4+len(visible)), out.Files-out.Ffree)
+	}
+}
+
+// blockedStore blocks the lookups until release is closed
+type blockedStore struct {
+	TestStore
+	release chan struct{}
+}
+
+func (s *blockedStore) Get(p string) (meta.Meta, bool) {
+	<-s.release
+	return s.TestStore.Get(p)
+}
+
+func TestStatFsBackground(t *testing.T) {
+	a := &TestMeta{id: "a", size: blkSize}
+	store := &blockedStore{
+		TestStore: TestStore{"": &testDir{children: []meta.Meta{a}}, "a": a},
+		release:   make(chan struct{}),
+	}
+
+	fs := &filesystem{Config: NewConfig(nil, store, t.TempDir())}
+
+	// statfs doesn't wait for the flist to be walked
+	done := make(chan *fuse.StatfsOut)
+	go func() { done <- fs.StatFs("") }()
+
+	select {
+	case out := <-done:
+		assert.Equal(t, uint64(0), out.Blocks-out.Bfree)
+	case <-time.After(5 * time.Second):
+		t.Fatal("statfs waits for the flist usage")
+	}
+
+	close(store.release)
+	<-fs.store.Load().usage.done
+	out := fs.StatFs("")
+	assert.Equal(t, uint64(1), out.Blocks-out.Bfree)
+	assert.Equal(t, uint64(2), out.Files-out.Ffree)
+}
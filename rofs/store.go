@@ -0,0 +1,213 @@
+package rofs
+
+import (
+	"io"
+	"path"
+	"reflect"
+	"sync"
+
+	"github.com/hanwen/go-fuse/v2/fuse/nodefs"
+	"github.com/hanwen/go-fuse/v2/fuse/pathfs"
+	"github.com/threefoldtech/0-fs/meta"
+	"github.com/threefoldtech/0-fs/storage"
+)
+
+// storeRef is a meta store, and the storage its file blocks are downloaded
+// from, that is only closed once all in-flight operations, and the entries bound
+// to it (see bind), are done with it.
+type storeRef struct {
+	meta.Store
+	storage storage.Storage
+	layers  map[meta.Store]storage.Storage
+	links   links
+	usage   usage
+
+	m       sync.RWMutex
+	retired bool
+
+	// leases counts the users of the store other than the operations (bound
+	// entries, links and usage count, reload) that are not done yet
+	leases sync.WaitGroup
+	// prev is the store this one replaced, it shares the storages that were
+	// not replaced by the swap
+	prev *storeRef
+	// done is closed once the store is retired and its resources closed
+	done chan struct{}
+}
+
+func newStoreRef(store meta.Store, storage storage.Storage, layers map[meta.Store]storage.Storage) *storeRef {
+	return &storeRef{
+		Store:   store,
+		storage: storage,
+		layers:  layers,
+		done:    make(chan struct{}),
+	}
+}
+
+// release must be called once the operation is done with the store
+func (r *storeRef) release() {
+	r.m.RUnlock()
+}
+
+// retire waits for all in-flight operations to finish, and for the entries
+// bound to the store, or to the stores before it, to be released. It then
+// closes the given resources of the store, the ones that are replaced by the swap
+func (r *storeRef) retire(closers ...io.Closer) {
+	r.m.Lock()
+	r.retired = true
+	r.m.Unlock()
+
+	// no entries are bound once the store is retired
+	r.links.stop()
+	r.usage.stop()
+	r.leases.Wait()
+	if r.prev != nil {
+		<-r.prev.done
+		r.prev = nil
+	}
+
+	defer close(r.done)
+	for _, closer := range closers {
+		if closer == nil {
+			continue
+		}
+
+		if err := closer.Close(); err != nil {
+			log.Errorf("failed to close replaced store: %s", err)
+		}
+	}
+}
+
+// storageOf returns the storage to download the blocks of m, an entry of the
+// store, from (see Cache.SetLayerStorage)
+func (r *storeRef) storageOf(m meta.Meta) storage.Storage {
+	if layer := meta.Layer(m); layer != nil {
+		if storage, ok := r.layers[layer]; ok {
+			return storage
+		}
+	}
+
+	return r.storage
+}
+
+// bound is an entry bound to the storage of the store it comes from, so the entry
+// blocks are downloaded from the same storage even if the store is swapped. The
+// store and its storage are not closed until the entry is released
+type bound struct {
+	meta.Meta
+	storage storage.Storage
+
+	ref  *storeRef
+	once sync.Once
+}
+
+// release releases the entry store, the entry must not be read after release
+func (b *bound) release() {
+	b.once.Do(b.ref.leases.Done)
+}
+
+// bind binds the entry m of the store to its storage, it must be called while
+// the store is acquired, and the entry must be released once it's not used anymore
+func (r *storeRef) bind(m meta.Meta) *bound {
+	r.leases.Add(1)
+	return &bound{Meta: m, storage: r.storageOf(m), ref: r}
+}
+
+// storages returns the storages of the store that can be closed
+func (r *storeRef) storages() []io.Closer {
+	var closers []io.Closer
+	for _, storage := range append([]storage.Storage{r.storage}, values(r.layers)...) {
+		if closer, ok := storage.(io.Closer); ok {
+			closers = append(closers, closer)
+		}
+	}
+
+	return closers
+}
+
+func values(layers map[meta.Store]storage.Storage) []storage.Storage {
+	list := make([]storage.Storage, 0, len(layers))
+	for _, storage := range layers {
+		list = append(list, storage)
+	}
+
+	return list
+}
+
+// swap replaces the current store with next, the resources returned by
+// replaced are closed once the operations that use the old store are done
+func (c *Config) swap(update func(next *storeRef), replaced func(old *storeRef) []io.Closer) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	old := c.store.Load()
+	next := newStoreRef(old.Store, old.storage, old.layers)
+	next.prev = old
+	update(next)
+
+	c.store.Store(next)
+	c.countUsage(next)
+	if c.inodes == HardlinkInodes {
+		c.countLinks(next)
+	}
+
+	go old.retire(replaced(old)...)
+}
+
+// acquire returns the current meta store, the store can't be closed until
+// release is called, so an operation never sees a closed store.
+// Operations should never acquire the store twice (recursively)
+func (c *Config) acquire() *storeRef {
+	for {
+		ref := c.store.Load()
+		ref.m.RLock()
+		if !ref.retired {
+			return ref
+		}
+
+		// store was swapped while we were waiting, try again
+		// with the new one
+		ref.m.RUnlock()
+	}
+}
+
+// invalidate notifies the kernel about all the entries it knows that
+// are changed between the old and the new store
+func (c *Config) invalidate(old, store meta.Store) {
+	nodeFs := c.nodeFs.Load()
+	if nodeFs == nil || old == nil || store == nil {
+		return
+	}
+
+	var walk func(dir string, inode *nodefs.Inode)
+	walk = func(dir string, inode *nodefs.Inode) {
+		for name, child := range inode.Children() {
+			p := path.Join(dir, name)
+			current, ok := store.Get(p)
+			if !ok {
+				log.Debugf("entry '%s' is removed", p)
+				nodeFs.EntryNotify(dir, name)
+				continue
+			}
+
+			if previous, ok := old.Get(p); !ok || changed(previous, current) {
+				log.Debugf("entry '%s' is changed", p)
+				nodeFs.Notify(p)
+			}
+
+			walk(p, child)
+		}
+	}
+
+	walk("", nodeFs.Root().Inode())
+}
+
+func changed(a, b meta.Meta) bool {
+	return !reflect.DeepEqual(a.Info(), b.Info()) || a.ID() != b.ID()
+}
+
+// mounted keeps a reference to the path node fs, so the kernel can be notified
+// with the changes when the meta store is changed
+func (c *Config) mounted(nodeFs *pathfs.PathNodeFs) {
+	c.nodeFs.Store(nodeFs)
+}
@@ -0,0 +1,177 @@
+package rofs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/threefoldtech/0-fs/meta"
+	"github.com/threefoldtech/0-fs/storage"
+)
+
+type ClosableStore struct {
+	TestStore
+	closed chan struct{}
+}
+
+func (s *ClosableStore) Close() error {
+	close(s.closed)
+	return nil
+}
+
+func TestSetMetaStore(t *testing.T) {
+	old := &ClosableStore{
+		TestStore: TestStore{"a": &TestMeta{id: "a"}},
+		closed:    make(chan struct{}),
+	}
+
+	store := &ClosableStore{
+		TestStore: TestStore{"b": &TestMeta{id: "b"}},
+		closed:    make(chan struct{}),
+	}
+
+	cfg := NewConfig(nil, old, t.TempDir())
+
+	// an operation in progress
+	ref := cfg.acquire()
+	cfg.SetMetaStore(store)
+
+	_, ok := ref.Get("a")
+	assert.True(t, ok)
+
+	select {
+	case <-old.closed:
+		t.Fatal("store closed while in use")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// new operations use the new store
+	current := cfg.acquire()
+	_, ok = current.Get("b")
+	assert.True(t, ok)
+	current.release()
+
+	ref.release()
+
+	select {
+	case <-old.closed:
+	case <-time.After(time.Second):
+		t.Fatal("old store was not closed")
+	}
+
+	select {
+	case <-store.closed:
+		t.Fatal("new store must not be closed")
+	default:
+	}
+}
+
+type ClosableStorage struct {
+	*TestStorage
+	closed chan struct{}
+}
+
+func (s *ClosableStorage) Close() error {
+	close(s.closed)
+	return nil
+}
+
+func TestReload(t *testing.T) {
+	data, blocks, err := MakeStorage(1)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	a := &TestMeta{id: "a", blocks: blocks, size: ChunkSize}
+	old := &ClosableStore{TestStore: TestStore{"a": a}, closed: make(chan struct{})}
+	oldStorage := &ClosableStorage{TestStorage: data, closed: make(chan struct{})}
+	cfg := NewConfig(oldStorage, old, t.TempDir())
+
+	// an operation in progress keeps using the old storage
+	ref := cfg.acquire()
+	m, _ := ref.Get("a")
+
+	store := &ClosableStore{TestStore: TestStore{"a": a}, closed: make(chan struct{})}
+	empty := &ClosableStorage{TestStorage: &TestStorage{}, closed: make(chan struct{})}
+	cfg.Reload(store, empty, map[meta.Store]storage.Storage{})
+
+	entry := ref.bind(m)
+	file, err := cfg.cache.CheckAndGet(entry)
+	if ok := assert.NoError(t, err); ok {
+		file.Close()
+	}
+
+	current := cfg.acquire()
+	assert.Equal(t, storage.Storage(empty), current.storageOf(a))
+	current.release()
+
+	select {
+	case <-oldStorage.closed:
+		t.Fatal("storage closed while in use")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	ref.release()
+
+	// the entry is still bound to the old storage, like an open file
+	select {
+	case <-oldStorage.closed:
+		t.Fatal("storage closed while an entry is bound to it")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	entry.release()
+	for _, closed := range []chan struct{}{old.closed, oldStorage.closed} {
+		select {
+		case <-closed:
+		case <-time.After(time.Second):
+			t.Fatal("old store was not closed")
+		}
+	}
+
+	select {
+	case <-empty.closed:
+		t.Fatal("new storage must not be closed")
+	default:
+	}
+}
+
+func TestReloadKeepStorage(t *testing.T) {
+	a := &TestMeta{id: "a"}
+	data := &ClosableStorage{TestStorage: &TestStorage{}, closed: make(chan struct{})}
+	cfg := NewConfig(data, TestStore{"a": a}, t.TempDir())
+
+	ref := cfg.acquire()
+	entry := ref.bind(a)
+	ref.release()
+
+	// the storage is kept by the first reload, and replaced by the second one
+	cfg.Reload(TestStore{"a": a}, nil, nil)
+	cfg.Reload(TestStore{"a": a}, &TestStorage{}, map[meta.Store]storage.Storage{})
+
+	select {
+	case <-data.closed:
+		t.Fatal("storage closed while an entry of an older store is bound to it")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	entry.release()
+	select {
+	case <-data.closed:
+	case <-time.After(time.Second):
+		t.Fatal("storage was not closed")
+	}
+}
+
+func TestChanged(t *testing.T) {
+	a := &TestMeta{id: "a", info: &meta.Info{Type: meta.RegularType, Size: 10}}
+	b := &TestMeta{id: "a", info: &meta.Info{Type: meta.RegularType, Size: 10}}
+
+	assert.False(t, changed(a, b))
+
+	b.info.Access.Mode = 0755
+	assert.True(t, changed(a, b))
+
+	c := &TestMeta{id: "c", info: &meta.Info{Type: meta.RegularType, Size: 10}}
+	assert.True(t, changed(a, c))
+}
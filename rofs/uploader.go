@@ -0,0 +1,98 @@
+package rofs
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/golang/snappy"
+	"github.com/threefoldtech/0-fs/meta"
+	"github.com/threefoldtech/0-fs/storage"
+	"github.com/xxtea/xxtea-go/xxtea"
+	"golang.org/x/crypto/blake2b"
+)
+
+// Uploader splits data into blocks, then compresses, encrypts and uploads them
+// to a storage in the format expected by the Downloader
+type Uploader struct {
+	storage   storage.Setter
+	blockSize uint64
+}
+
+// NewUploader creates an uploader that stores blocks of DefaultBlockSize in storage
+func NewUploader(storage storage.Setter) *Uploader {
+	return &Uploader{
+		storage:   storage,
+		blockSize: DefaultBlockSize * 1024,
+	}
+}
+
+// BlockSize returns the size of the uploaded blocks in bytes
+func (u *Uploader) BlockSize() uint64 {
+	return u.blockSize
+}
+
+// encodeBlock compresses and encrypts a data block in the storage format. The block
+// is encrypted with its blake2b hash, and stored under the hash of the encrypted data
+func encodeBlock(data []byte) (meta.BlockInfo, []byte, error) {
+	hasher, err := blake2b.New(16, nil)
+	if err != nil {
+		return meta.BlockInfo{}, nil, err
+	}
+
+	if _, err := hasher.Write(data); err != nil {
+		return meta.BlockInfo{}, nil, err
+	}
+
+	block := meta.BlockInfo{
+		Decipher: hasher.Sum(nil),
+	}
+
+	encrypted := xxtea.Encrypt(snappy.Encode(nil, data), block.Decipher)
+
+	hasher.Reset()
+	if _, err := hasher.Write(encrypted); err != nil {
+		return meta.BlockInfo{}, nil, err
+	}
+
+	block.Key = hasher.Sum(nil)
+	return block, encrypted, nil
+}
+
+// UploadBlock compresses, encrypts and uploads a single data block. The block is
+// encrypted with its blake2b hash, and stored under the hash of the encrypted data
+func (u *Uploader) UploadBlock(data []byte) (meta.BlockInfo, error) {
+	block, encrypted, err := encodeBlock(data)
+	if err != nil {
+		return meta.BlockInfo{}, err
+	}
+
+	log.Debugf("uploading block %x", block.Key)
+	if err := u.storage.Set(block.Key, encrypted); err != nil {
+		return meta.BlockInfo{}, fmt.Errorf("failed to upload block %x: %s", block.Key, err)
+	}
+
+	return block, nil
+}
+
+// Upload reads r until EOF and uploads the data block by block
+func (u *Uploader) Upload(r io.Reader) ([]meta.BlockInfo, error) {
+	var blocks []meta.BlockInfo
+	buf := make([]byte, u.blockSize)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			block, err := u.UploadBlock(buf[:n])
+			if err != nil {
+				return nil, err
+			}
+
+			blocks = append(blocks, block)
+		}
+
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return blocks, nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package rofs
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/threefoldtech/0-fs/meta"
+)
+
+func (t *TestStorage) Set(key []byte, data []byte) error {
+	t.data[string(key)] = data
+	return nil
+}
+
+func TestUploadDownload(t *testing.T) {
+	storage := &TestStorage{data: make(map[string][]byte)}
+	uploader := NewUploader(storage)
+
+	// 2 full blocks and a partial one
+	data := make([]byte, 2*uploader.BlockSize()+100)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+
+	blocks, err := uploader.Upload(bytes.NewReader(data))
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	assert.Len(t, blocks, 3)
+	assert.Len(t, storage.data, 3)
+
+	m := &TestMeta{
+		blocks: blocks,
+		size:   uint64(len(data)),
+		info: &meta.Info{
+			Type:          meta.RegularType,
+			Size:          uint64(len(data)),
+			FileBlockSize: uploader.BlockSize(),
+		},
+	}
+
+	downloader := NewDownloader(storage, m)
+	var downloaded []byte
+	for i := range blocks {
+		block, err := downloader.DownloadBlock(i)
+		if ok := assert.NoError(t, err); !ok {
+			t.Fatal()
+		}
+		downloaded = append(downloaded, block...)
+	}
+
+	assert.Equal(t, data, downloaded)
+}
+
+func TestUploadEmpty(t *testing.T) {
+	storage := &TestStorage{data: make(map[string][]byte)}
+	blocks, err := NewUploader(storage).Upload(bytes.NewReader(nil))
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	assert.Empty(t, blocks)
+}
@@ -89,11 +89,13 @@ func (c *Config) Router(factory PoolFactory) (*Router, error) {
 		for rangeStr, destStr := range cfg {
 			hashRange, err := NewRange(rangeStr)
 			if err != nil {
+				router.Close()
 				return nil, errors.Wrap(err, rangeStr)
 			}
 
 			dest, err := NewDestination(destStr)
 			if err != nil {
+				router.Close()
 				return nil, errors.Wrap(err, destStr)
 			}
 
@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"math/rand"
 	"net"
-	"strings"
 	"sync"
 	"time"
 )
@@ -25,8 +24,8 @@ type lookup struct {
 
 // dial wrapper around net.Dial that provide dns lookup caching
 func dial(network, address string) (net.Conn, error) {
-	parts := strings.SplitN(address, ":", 2)
-	if len(parts) != 2 {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
 		return nil, fmt.Errorf("mallformed address expected format <host>:<port>")
 	}
 
@@ -34,7 +33,7 @@ func dial(network, address string) (net.Conn, error) {
 	defer dnsCacheM.Unlock()
 
 	var ips []net.IP
-	if lookup, ok := dnsCache[parts[0]]; ok {
+	if lookup, ok := dnsCache[host]; ok {
 		if time.Since(lookup.on) < dnsCacheTimeout {
 			ips = lookup.ips
 		}
@@ -42,7 +41,7 @@ func dial(network, address string) (net.Conn, error) {
 
 	if len(ips) == 0 {
 		var err error
-		result, err := net.LookupIP(parts[0])
+		result, err := net.LookupIP(host)
 		if err != nil {
 			return nil, err
 		}
@@ -57,18 +56,18 @@ func dial(network, address string) (net.Conn, error) {
 			return nil, fmt.Errorf("can not resolve host '%s'", address)
 		}
 
-		dnsCache[parts[0]] = lookup{ips, time.Now()}
+		dnsCache[host] = lookup{ips, time.Now()}
 	}
 
 	i := rand.Intn(len(ips))
 
-	log.Debugf("dialling %s:%s", ips[i], parts[1])
-
 	ip := ips[i]
+	log.Debugf("dialling %s:%s", ip, port)
+
 	if ip4 := ip.To4(); ip4 != nil {
-		return net.Dial(network, fmt.Sprintf("%s:%s", ip4.String(), parts[1]))
+		return net.Dial(network, net.JoinHostPort(ip4.String(), port))
 	} else if ip6 := ip.To16(); ip6 != nil {
-		return net.Dial(network, fmt.Sprintf("[%s]:%s", ip6.String(), parts[1]))
+		return net.Dial(network, net.JoinHostPort(ip6.String(), port))
 	} else {
 		return nil, fmt.Errorf("invalid ip address '%s'", ip.String())
 	}
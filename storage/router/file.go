@@ -0,0 +1,85 @@
+package router
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// fileBackend is a backend for file:// destinations. Each block is stored as a
+// file in a local directory, sharded by the key prefix as `<dir>/xx/yy/<hex-key>`
+type fileBackend struct {
+	root string
+}
+
+func newFileBackend(d Destination) *fileBackend {
+	return &fileBackend{root: filepath.Join(d.Host, d.Path)}
+}
+
+func (b *fileBackend) path(key []byte) string {
+	hash := fmt.Sprintf("%x", key)
+	base := b.root
+	if len(hash) >= 2 {
+		base = filepath.Join(base, hash[0:2])
+	}
+
+	if len(hash) >= 4 {
+		base = filepath.Join(base, hash[2:4])
+	}
+
+	return filepath.Join(base, hash)
+}
+
+// Get key from destination
+func (b *fileBackend) Get(key []byte) ([]byte, error) {
+	data, err := os.ReadFile(b.path(key))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// Set key to data, the block is written to a temporary file first
+// so readers never see a partially written block
+func (b *fileBackend) Set(key, data []byte) error {
+	name := b.path(key)
+	dir := filepath.Dir(name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), name)
+}
+
+// Exists checks if key exists in the destination
+func (b *fileBackend) Exists(key []byte) (bool, error) {
+	_, err := os.Stat(b.path(key))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
@@ -0,0 +1,101 @@
+package router
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileDestination(t *testing.T) {
+	_, err := NewDestination("file:///var/lib/blocks")
+	assert.NoError(t, err)
+
+	_, err = NewDestination("file://")
+	assert.Error(t, err)
+}
+
+func TestFilePool(t *testing.T) {
+	root := t.TempDir()
+	dest, err := NewDestination("file://" + root)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	all, _ := NewRange("00:FF")
+	pool := NewScanPool(Rule{Range: all, Destination: dest})
+
+	key := HexToBytes("abcdef")
+	_, err = pool.Get(key)
+	assert.Equal(t, ErrNotFound, err)
+
+	if ok := assert.NoError(t, pool.Set(key, []byte("result value"))); !ok {
+		t.Fatal()
+	}
+
+	// blocks are sharded by prefix
+	data, err := os.ReadFile(filepath.Join(root, "ab", "cd", "abcdef"))
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	assert.Equal(t, "result value", string(data))
+
+	data, err = pool.Get(key)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	assert.Equal(t, "result value", string(data))
+}
+
+func TestRouterFileCache(t *testing.T) {
+	root := t.TempDir()
+	config := Config{
+		Pools: map[string]PoolConfig{
+			"local": {
+				"00:FF": "file://" + root,
+			},
+			"remote": {
+				"00:FF": "ardb://destination.remote:1234",
+			},
+		},
+		Lookup: []string{"local", "remote"},
+		Cache:  []string{"local"},
+	}
+
+	router, err := config.Router(nil)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	key := HexToBytes("abcdef")
+	remote := &TestPool{}
+	remote.On("Get", key).Return([]byte("result value"), nil)
+	router.pools["remote"] = remote
+
+	ret, err := router.Get(key)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	result, _ := io.ReadAll(ret)
+	assert.Equal(t, "result value", string(result))
+
+	// the block is written back to the local cache pool
+	local := router.pools["local"]
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		data, err := local.Get(key)
+		if err == nil {
+			assert.Equal(t, "result value", string(data))
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal("block was not written to cache")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
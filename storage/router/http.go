@@ -0,0 +1,214 @@
+package router
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	httpTimeout      = 30 * time.Second
+	httpDialTimeout  = 10 * time.Second
+	httpIdleTimeout  = 90 * time.Second
+	httpMaxIdleConns = 12
+)
+
+// httpBackend is a backend for http(s) destinations. Each block is an object
+// served as `<destination>/<hex-key>`, blocks are uploaded with PUT. The user
+// and password of the destination are sent with basic authentication
+type httpBackend struct {
+	base   string
+	user   *url.Userinfo
+	client *http.Client
+}
+
+func newHTTPBackend(d Destination) *httpBackend {
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   httpDialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          httpMaxIdleConns,
+		MaxIdleConnsPerHost:   httpMaxIdleConns,
+		IdleConnTimeout:       httpIdleTimeout,
+		TLSHandshakeTimeout:   httpDialTimeout,
+		ExpectContinueTimeout: time.Second,
+	}
+
+	u := *d
+	u.User = nil
+	return &httpBackend{
+		base: strings.TrimSuffix(u.String(), "/"),
+		user: d.User,
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   httpTimeout,
+		},
+	}
+}
+
+// do sends a request for the block key, with the destination credentials
+func (b *httpBackend) do(method string, key []byte, body io.Reader) (*http.Response, error) {
+	request, err := http.NewRequest(method, fmt.Sprintf("%s/%x", b.base, key), body)
+	if err != nil {
+		return nil, err
+	}
+
+	if b.user != nil {
+		password, _ := b.user.Password()
+		request.SetBasicAuth(b.user.Username(), password)
+	}
+
+	return b.client.Do(request)
+}
+
+// httpError maps the response status to an error, a nil error
+// means the status is a success. 404 and 416 are a missing block
+func httpError(response *http.Response) error {
+	switch {
+	case response.StatusCode >= 200 && response.StatusCode < 300:
+		return nil
+	case response.StatusCode == http.StatusNotFound,
+		response.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		// blocks are requested whole, so a range that can't be
+		// satisfied means the object is not the block
+		return ErrNotFound
+	default:
+		return fmt.Errorf("unexpected response: %s", response.Status)
+	}
+}
+
+// temporary returns true if the request can be retried
+func temporary(response *http.Response) bool {
+	return response.StatusCode >= 500 || response.StatusCode == http.StatusTooManyRequests
+}
+
+func (b *httpBackend) get(key []byte) ([]byte, bool, error) {
+	response, err := b.do(http.MethodGet, key, nil)
+	if err != nil {
+		return nil, true, err
+	}
+
+	defer response.Body.Close()
+
+	if err := httpError(response); err != nil {
+		// drain body so the connection can be reused
+		io.Copy(io.Discard, response.Body)
+		return nil, temporary(response), err
+	}
+
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, true, err
+	}
+
+	if err := checkContent(response, len(data)); err != nil {
+		return nil, true, err
+	}
+
+	return data, false, nil
+}
+
+// checkContent checks that the n bytes read from the response body are the whole
+// block. Blocks are always requested whole, so a partial content response must
+// cover the full object
+func checkContent(response *http.Response, n int) error {
+	if response.ContentLength >= 0 && int64(n) != response.ContentLength {
+		return fmt.Errorf("short read %d/%d", n, response.ContentLength)
+	}
+
+	if response.StatusCode != http.StatusPartialContent {
+		return nil
+	}
+
+	var start, end, size int64
+	contentRange := response.Header.Get("Content-Range")
+	if _, err := fmt.Sscanf(contentRange, "bytes %d-%d/%d", &start, &end, &size); err != nil {
+		return fmt.Errorf("invalid content range '%s'", contentRange)
+	}
+
+	if start != 0 || end != size-1 || int64(n) != size {
+		return fmt.Errorf("partial content %d-%d/%d, got %d bytes", start, end, size, n)
+	}
+
+	return nil
+}
+
+// Get key from destination
+func (b *httpBackend) Get(key []byte) ([]byte, error) {
+	var err error
+	for trial := 1; trial <= blockGetRetries; trial++ {
+		log.Debugf("try %x: trial %d/%d", key, trial, blockGetRetries)
+		var data []byte
+		var retry bool
+		data, retry, err = b.get(key)
+		if err == nil {
+			log.Debugf("block '%x' has been downloaded successfully", key)
+			return data, nil
+		} else if !retry {
+			return nil, err
+		}
+
+		log.Errorf("block '%x' downloading failed with error: %s", key, err)
+	}
+
+	return nil, err
+}
+
+// Exists checks if key exists in the destination with a HEAD request, servers
+// that don't support HEAD are checked with a GET
+func (b *httpBackend) Exists(key []byte) (bool, error) {
+	var err error
+	for trial := 1; trial <= blockGetRetries; trial++ {
+		var response *http.Response
+		response, err = b.do(http.MethodHead, key, nil)
+		if err != nil {
+			continue
+		}
+
+		response.Body.Close()
+		if response.StatusCode == http.StatusMethodNotAllowed ||
+			response.StatusCode == http.StatusNotImplemented {
+			_, err = b.Get(key)
+			if err == ErrNotFound {
+				return false, nil
+			}
+
+			return err == nil, err
+		}
+
+		err = httpError(response)
+		if err == ErrNotFound {
+			return false, nil
+		} else if err == nil {
+			return true, nil
+		} else if !temporary(response) {
+			return false, err
+		}
+	}
+
+	return false, err
+}
+
+// Set key to data
+func (b *httpBackend) Set(key, data []byte) error {
+	response, err := b.do(http.MethodPut, key, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+	io.Copy(io.Discard, response.Body)
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response: %s", response.Status)
+	}
+
+	return nil
+}
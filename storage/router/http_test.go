@@ -0,0 +1,290 @@
+package router
+
+import (
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestHTTPServer serves blocks as `/<hex-key>` objects
+type TestHTTPServer struct {
+	data     map[string][]byte
+	failures int
+	// head enables HEAD requests
+	head bool
+	gets int
+	m    sync.Mutex
+}
+
+func (s *TestHTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	switch r.Method {
+	case http.MethodHead:
+		if !s.head {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		if _, ok := s.data[key]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	case http.MethodGet:
+		s.gets++
+		if s.failures > 0 {
+			s.failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		data, ok := s.data[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	case http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		s.data[key] = data
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func newHTTPPool(t *testing.T, server *httptest.Server) Pool {
+	dest, err := NewDestination(server.URL)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	all, _ := NewRange("00:FF")
+	return NewScanPool(Rule{Range: all, Destination: dest})
+}
+
+func TestHTTPPoolGet(t *testing.T) {
+	handler := &TestHTTPServer{
+		data: map[string][]byte{"abcdef": []byte("result value")},
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	pool := newHTTPPool(t, server)
+
+	data, err := pool.Get(HexToBytes("abcdef"))
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	assert.Equal(t, "result value", string(data))
+
+	_, err = pool.Get(HexToBytes("aaaaaa"))
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestHTTPPoolRetry(t *testing.T) {
+	handler := &TestHTTPServer{
+		data:     map[string][]byte{"abcdef": []byte("result value")},
+		failures: blockGetRetries - 1,
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	pool := newHTTPPool(t, server)
+
+	data, err := pool.Get(HexToBytes("abcdef"))
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	assert.Equal(t, "result value", string(data))
+
+	handler.m.Lock()
+	handler.failures = blockGetRetries
+	handler.m.Unlock()
+	_, err = pool.Get(HexToBytes("abcdef"))
+	assert.Error(t, err)
+}
+
+func TestHTTPPoolExists(t *testing.T) {
+	backend := &TestHTTPServer{data: map[string][]byte{"abcdef": []byte("value")}}
+	server := httptest.NewServer(backend)
+	defer server.Close()
+
+	pool := newHTTPPool(t, server).(*ScanPool)
+
+	// servers without HEAD support are checked with a GET
+	found, err := pool.Exists(HexToBytes("abcdef"))
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, 1, backend.gets)
+
+	backend.head = true
+	found, err = pool.Exists(HexToBytes("abcdef"))
+	assert.NoError(t, err)
+	assert.True(t, found)
+
+	found, err = pool.Exists(HexToBytes("aaaa"))
+	assert.NoError(t, err)
+	assert.False(t, found)
+	assert.Equal(t, 1, backend.gets)
+}
+
+func TestHTTPPoolAuth(t *testing.T) {
+	backend := &TestHTTPServer{data: map[string][]byte{"abcdef": []byte("value")}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "user" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		backend.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	dest, err := NewDestination(strings.Replace(server.URL, "http://", "http://user:secret@", 1))
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	backend.head = true
+	store := newHTTPBackend(dest)
+	data, err := store.Get(HexToBytes("abcdef"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("value"), data)
+
+	found, err := store.Exists(HexToBytes("abcdef"))
+	assert.NoError(t, err)
+	assert.True(t, found)
+
+	assert.NoError(t, store.Set(HexToBytes("aaaa"), []byte("value")))
+
+	_, err = store.Get(HexToBytes("aaaaaa"))
+	assert.Equal(t, ErrNotFound, err)
+
+	// errors other than 404, like 401 without credentials, are not a missing block
+	dest.User = nil
+	_, err = newHTTPBackend(dest).Get(HexToBytes("abcdef"))
+	assert.Error(t, err)
+	assert.NotEqual(t, ErrNotFound, err)
+}
+
+func TestHTTPPoolRange(t *testing.T) {
+	var (
+		status  int
+		header  http.Header
+		content string
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for k, v := range header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(status)
+		io.WriteString(w, content)
+	}))
+	defer server.Close()
+
+	dest, err := NewDestination(server.URL)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	store := newHTTPBackend(dest)
+
+	// an unsatisfiable range is a missing block
+	status, header, content = http.StatusRequestedRangeNotSatisfiable, nil, ""
+	_, err = store.Get(HexToBytes("abcdef"))
+	assert.Equal(t, ErrNotFound, err)
+
+	// a partial content that covers the whole block
+	status, header, content = http.StatusPartialContent, http.Header{"Content-Range": {"bytes 0-4/5"}}, "value"
+	data, err := store.Get(HexToBytes("abcdef"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("value"), data)
+
+	// only part of the block
+	header = http.Header{"Content-Range": {"bytes 0-4/10"}}
+	_, err = store.Get(HexToBytes("abcdef"))
+	assert.Error(t, err)
+	assert.NotEqual(t, ErrNotFound, err)
+
+	header = http.Header{"Content-Range": {"bytes */10"}}
+	_, err = store.Get(HexToBytes("abcdef"))
+	assert.Error(t, err)
+
+	// a body shorter than the announced length
+	status, header = http.StatusOK, http.Header{"Content-Length": {"10"}}
+	_, err = store.Get(HexToBytes("abcdef"))
+	assert.Error(t, err)
+}
+
+func TestHTTPPoolSet(t *testing.T) {
+	handler := &TestHTTPServer{
+		data: map[string][]byte{},
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	pool := newHTTPPool(t, server)
+
+	key := HexToBytes("abcdef")
+	if ok := assert.NoError(t, pool.Set(key, []byte("value"))); !ok {
+		t.Fatal()
+	}
+
+	assert.Equal(t, []byte("value"), handler.data[hex.EncodeToString(key)])
+}
+
+func TestRouterHTTPMiss(t *testing.T) {
+	local := httptest.NewServer(&TestHTTPServer{
+		data: map[string][]byte{},
+	})
+	defer local.Close()
+
+	remote := httptest.NewServer(&TestHTTPServer{
+		data: map[string][]byte{"abcdef": []byte("result value")},
+	})
+	defer remote.Close()
+
+	config := Config{
+		Pools: map[string]PoolConfig{
+			"local": {
+				"00:FF": local.URL,
+			},
+			"remote": {
+				"00:FF": remote.URL,
+			},
+		},
+		Lookup: []string{"local", "remote"},
+	}
+
+	if ok := assert.NoError(t, config.Valid()); !ok {
+		t.Fatal()
+	}
+
+	router, err := config.Router(nil)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	blocks := testutil.ToFloat64(poolBlocks.WithLabelValues("remote"))
+	bytes := testutil.ToFloat64(poolBytes.WithLabelValues("remote"))
+
+	ret, err := router.Get(HexToBytes("abcdef"))
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	result, _ := io.ReadAll(ret)
+	assert.Equal(t, "result value", string(result))
+
+	assert.Equal(t, blocks+1, testutil.ToFloat64(poolBlocks.WithLabelValues("remote")))
+	assert.Equal(t, bytes+float64(len(result)), testutil.ToFloat64(poolBytes.WithLabelValues("remote")))
+}
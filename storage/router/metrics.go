@@ -0,0 +1,36 @@
+package router
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	poolBlocks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "zerofs",
+		Subsystem: "router",
+		Name:      "blocks_total",
+		Help:      "Number of blocks fetched by pool",
+	}, []string{"pool"})
+
+	poolBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "zerofs",
+		Subsystem: "router",
+		Name:      "bytes_total",
+		Help:      "Number of bytes fetched by pool",
+	}, []string{"pool"})
+
+	poolErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "zerofs",
+		Subsystem: "router",
+		Name:      "errors_total",
+		Help:      "Number of failed block fetches by pool",
+	}, []string{"pool"})
+
+	cacheQueue = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "zerofs",
+		Subsystem: "router",
+		Name:      "cache_queue_depth",
+		Help:      "Number of blocks waiting to be written back to the cache pools",
+	})
+)
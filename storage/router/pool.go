@@ -3,10 +3,9 @@ package router
 import (
 	"bytes"
 	"fmt"
+	"io"
+	"net/url"
 	"sync"
-	"time"
-
-	"github.com/garyburd/redigo/redis"
 )
 
 const (
@@ -21,6 +20,13 @@ type Pool interface {
 	Set(key []byte, data []byte) error
 }
 
+// backend reads and writes blocks from and to a single destination.
+// Get returns ErrNotFound if the key doesn't exist
+type backend interface {
+	Get(key []byte) ([]byte, error)
+	Set(key, data []byte) error
+}
+
 /*
 ScanPool defines a set of routing rules
 This implementation of pool does a sequential scan of the rules. That's not very efficient usually
@@ -31,7 +37,9 @@ the hash.
 */
 type ScanPool struct {
 	Rules []Rule
-	conn  map[Destination]*redis.Pool
+	// conn holds a backend per destination url, so rules that point to the
+	// same destination (same host and namespace) share the connections
+	conn map[string]backend
 
 	m sync.Mutex
 }
@@ -77,73 +85,34 @@ func (p *ScanPool) Routes(h []byte) []Destination {
 	return dest
 }
 
-func (p *ScanPool) newPool(d Destination) *redis.Pool {
-	return &redis.Pool{
-		Dial: func() (redis.Conn, error) {
-			opts := []redis.DialOption{
-				redis.DialNetDial(dial),
-			}
-
-			if d.User != nil {
-				//assume ardb://password@host.com:port/
-				opts = append(opts, redis.DialPassword(d.User.Username()))
-			}
-
-			return redis.Dial("tcp", d.Host, opts...)
-		},
-		TestOnBorrow: func(c redis.Conn, t time.Time) error {
-			if time.Since(t) > 10*time.Second {
-				//only check connection if more than 10 second of inactivity
-				_, err := c.Do("PING")
-				return err
-			}
-
-			return nil
-		},
-		MaxActive:   12,
-		MaxIdle:     4,
-		IdleTimeout: 1 * time.Minute,
-		Wait:        true,
+func (p *ScanPool) newBackend(d Destination) backend {
+	switch d.Scheme {
+	case "http", "https":
+		return newHTTPBackend(d)
+	case "file":
+		return newFileBackend(d)
+	default:
+		return &redisBackend{pool: newRedisPool(d)}
 	}
 }
 
-func (p *ScanPool) getPool(d Destination) (*redis.Pool, error) {
+func (p *ScanPool) getBackend(d Destination) (backend, error) {
 	p.m.Lock()
 	defer p.m.Unlock()
 
-	pool, ok := p.conn[d]
+	key := (*url.URL)(d).String()
+	b, ok := p.conn[key]
 	if ok {
-		return pool, nil
+		return b, nil
 	}
 
-	pool = p.newPool(d)
+	b = p.newBackend(d)
 	if p.conn == nil {
-		p.conn = make(map[Destination]*redis.Pool)
+		p.conn = make(map[string]backend)
 	}
-	p.conn[d] = pool
+	p.conn[key] = b
 
-	return pool, nil
-}
-
-func (p *ScanPool) get(pool *redis.Pool, key []byte) ([]byte, error) {
-	con := pool.Get()
-	defer con.Close()
-
-	trial := 1
-	var err error
-	var bytes []byte
-	for trial <= blockGetRetries {
-		log.Debugf("try %x: trial %d/%d", key, trial, blockGetRetries)
-		bytes, err = redis.Bytes(con.Do("GET", key))
-		if err == nil || err == redis.ErrNil {
-			log.Debugf("block '%x' has been downloaded successfully", key)
-			return bytes, err
-		}
-		log.Errorf("block '%x' downloading failed with error: %s", key, err)
-		trial++
-	}
-
-	return bytes, err
+	return b, nil
 }
 
 // Get key from pool
@@ -154,14 +123,14 @@ func (p *ScanPool) Get(key []byte) ([]byte, error) {
 	}
 
 	for _, dest := range dests {
-		pool, err := p.getPool(dest)
+		b, err := p.getBackend(dest)
 		if err != nil {
 			return nil, err
 		}
 
-		data, err := p.get(pool, key)
+		data, err := b.Get(key)
 		if err != nil {
-			if err != redis.ErrNil {
+			if err != ErrNotFound {
 				log.Errorf("destination(%s://%s, %x): %s", dest.Scheme, dest.Host, key, err)
 			}
 
@@ -174,6 +143,53 @@ func (p *ScanPool) Get(key []byte) ([]byte, error) {
 	return nil, ErrNotFound
 }
 
+// Exists checks if key exists in the pool without downloading it, destinations
+// that can't check for a key are checked with a Get
+func (p *ScanPool) Exists(key []byte) (bool, error) {
+	dests := p.Routes(key)
+	if len(dests) == 0 {
+		return false, ErrNotRoutable
+	}
+
+	for _, dest := range dests {
+		b, err := p.getBackend(dest)
+		if err != nil {
+			return false, err
+		}
+
+		found, err := exists(b, key)
+		if err != nil {
+			log.Errorf("destination(%s://%s, %x): %s", dest.Scheme, dest.Host, key, err)
+			continue
+		}
+
+		if found {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// exister is implemented by the backends that can check a key without downloading it
+type exister interface {
+	Exists(key []byte) (bool, error)
+}
+
+// exists checks if key exists in store, with Exists if the store implements it
+func exists(store backend, key []byte) (bool, error) {
+	if store, ok := store.(exister); ok {
+		return store.Exists(key)
+	}
+
+	_, err := store.Get(key)
+	if err == ErrNotFound {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
 // Set key to data
 func (p *ScanPool) Set(key, data []byte) error {
 	dest := p.Route(key)
@@ -181,16 +197,30 @@ func (p *ScanPool) Set(key, data []byte) error {
 		return ErrNotRoutable
 	}
 
-	pool, err := p.getPool(dest)
+	b, err := p.getBackend(dest)
 	if err != nil {
 		return err
 	}
 
-	con := pool.Get()
-	defer con.Close()
+	return b.Set(key, data)
+}
+
+// Close closes the connections of the pool destinations. Connections are opened
+// again on demand if the pool is used after it's closed
+func (p *ScanPool) Close() error {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	for _, b := range p.conn {
+		if closer, ok := b.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				log.Errorf("failed to close backend: %s", err)
+			}
+		}
+	}
 
-	_, err = con.Do("SET", key, data)
-	return err
+	p.conn = nil
+	return nil
 }
 
 func (p *ScanPool) String() string {
@@ -0,0 +1,125 @@
+package router
+
+import (
+	"strings"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/pkg/errors"
+)
+
+// redisBackend is a backend for redis compatible destinations (ardb, zdb and redis)
+type redisBackend struct {
+	pool *redis.Pool
+}
+
+// Close closes the pool connections
+func (b *redisBackend) Close() error {
+	return b.pool.Close()
+}
+
+// namespace returns the 0-db namespace of a zdb destination, empty
+// for the default namespace
+func namespace(d Destination) string {
+	if d.Scheme != "zdb" {
+		return ""
+	}
+
+	return strings.Trim(d.Path, "/")
+}
+
+func newRedisPool(d Destination) *redis.Pool {
+	ns := namespace(d)
+	return &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			opts := []redis.DialOption{
+				redis.DialNetDial(dial),
+			}
+
+			if d.User != nil && len(ns) == 0 {
+				//assume ardb://password@host.com:port/
+				opts = append(opts, redis.DialPassword(d.User.Username()))
+			}
+
+			con, err := redis.Dial("tcp", d.Host, opts...)
+			if err != nil || len(ns) == 0 {
+				return con, err
+			}
+
+			//zdb://password@host.com:port/namespace, the password
+			//is the namespace password
+			args := []interface{}{ns}
+			if d.User != nil {
+				args = append(args, d.User.Username())
+			}
+
+			if _, err := con.Do("SELECT", args...); err != nil {
+				con.Close()
+				return nil, errors.Wrapf(err, "failed to select namespace '%s'", ns)
+			}
+
+			return con, nil
+		},
+		TestOnBorrow: func(c redis.Conn, t time.Time) error {
+			if time.Since(t) > 10*time.Second {
+				//only check connection if more than 10 second of inactivity
+				_, err := c.Do("PING")
+				return err
+			}
+
+			return nil
+		},
+		MaxActive:   12,
+		MaxIdle:     4,
+		IdleTimeout: 1 * time.Minute,
+		Wait:        true,
+	}
+}
+
+// get gets key with a connection of the pool, a broken connection is
+// dropped by the pool once closed
+func (b *redisBackend) get(key []byte) ([]byte, error) {
+	con := b.pool.Get()
+	defer con.Close()
+
+	return redis.Bytes(con.Do("GET", key))
+}
+
+// Get key from destination, each trial uses a new connection from the pool
+func (b *redisBackend) Get(key []byte) ([]byte, error) {
+	trial := 1
+	var err error
+	var bytes []byte
+	for trial <= blockGetRetries {
+		log.Debugf("try %x: trial %d/%d", key, trial, blockGetRetries)
+		bytes, err = b.get(key)
+		if err == nil {
+			log.Debugf("block '%x' has been downloaded successfully", key)
+			return bytes, nil
+		} else if err == redis.ErrNil {
+			return nil, ErrNotFound
+		}
+		log.Errorf("block '%x' downloading failed with error: %s", key, err)
+		trial++
+	}
+
+	return bytes, err
+}
+
+// Exists checks if key exists in the destination
+func (b *redisBackend) Exists(key []byte) (bool, error) {
+	con := b.pool.Get()
+	defer con.Close()
+
+	count, err := redis.Int(con.Do("EXISTS", key))
+	return count != 0, err
+}
+
+// Set key to data
+func (b *redisBackend) Set(key, data []byte) error {
+	con := b.pool.Get()
+	defer con.Close()
+
+	_, err := con.Do("SET", key, data)
+	return err
+}
@@ -0,0 +1,257 @@
+package router
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestZdb is a minimal redis protocol server that emulates 0-db namespaces
+type TestZdb struct {
+	listener   net.Listener
+	namespaces map[string]map[string][]byte
+	passwords  map[string]string
+	// drops is the number of GET requests answered by closing the connection
+	drops int
+
+	m sync.Mutex
+}
+
+func newTestZdb(t *testing.T) *TestZdb {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zdb := &TestZdb{
+		listener: listener,
+		namespaces: map[string]map[string][]byte{
+			"default": {},
+		},
+		passwords: map[string]string{},
+	}
+
+	go zdb.serve()
+	t.Cleanup(func() { listener.Close() })
+
+	return zdb
+}
+
+func (z *TestZdb) Addr() string {
+	return z.listener.Addr().String()
+}
+
+func (z *TestZdb) serve() {
+	for {
+		con, err := z.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go z.handle(con)
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+
+	var args []string
+	for i := 0; i < count; i++ {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, err
+		}
+
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+
+		args = append(args, string(buf[:size]))
+	}
+
+	return args, nil
+}
+
+func (z *TestZdb) handle(con net.Conn) {
+	defer con.Close()
+
+	reader := bufio.NewReader(con)
+	ns := "default"
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+
+		z.m.Lock()
+		var reply string
+		switch strings.ToUpper(args[0]) {
+		case "PING":
+			reply = "+PONG\r\n"
+		case "SELECT":
+			password := ""
+			if len(args) > 2 {
+				password = args[2]
+			}
+
+			if _, ok := z.namespaces[args[1]]; !ok {
+				reply = "-Namespace not found\r\n"
+			} else if z.passwords[args[1]] != password {
+				reply = "-Access denied\r\n"
+			} else {
+				ns = args[1]
+				reply = "+OK\r\n"
+			}
+		case "GET":
+			if z.drops > 0 {
+				z.drops--
+				z.m.Unlock()
+				return
+			}
+
+			if data, ok := z.namespaces[ns][args[1]]; ok {
+				reply = fmt.Sprintf("$%d\r\n%s\r\n", len(data), data)
+			} else {
+				reply = "$-1\r\n"
+			}
+		case "SET":
+			z.namespaces[ns][args[1]] = []byte(args[2])
+			reply = "+OK\r\n"
+		default:
+			reply = "-Unknown command\r\n"
+		}
+		z.m.Unlock()
+
+		if _, err := io.WriteString(con, reply); err != nil {
+			return
+		}
+	}
+}
+
+func newZdbPool(t *testing.T, dests ...string) *ScanPool {
+	all, _ := NewRange("00:FF")
+	var rules []Rule
+	for _, d := range dests {
+		dest, err := NewDestination(d)
+		if ok := assert.NoError(t, err); !ok {
+			t.Fatal()
+		}
+		rules = append(rules, Rule{Range: all, Destination: dest})
+	}
+
+	return NewScanPool(rules...).(*ScanPool)
+}
+
+func TestZdbNamespace(t *testing.T) {
+	zdb := newTestZdb(t)
+	zdb.namespaces["private"] = map[string][]byte{"\xab\xcd\xef": []byte("result value")}
+	zdb.passwords["private"] = "secret"
+
+	key := HexToBytes("abcdef")
+
+	pool := newZdbPool(t, fmt.Sprintf("zdb://secret@%s/private", zdb.Addr()))
+	data, err := pool.Get(key)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	assert.Equal(t, "result value", string(data))
+
+	// blocks are written to the selected namespace
+	if ok := assert.NoError(t, pool.Set(HexToBytes("aaaa"), []byte("value"))); !ok {
+		t.Fatal()
+	}
+
+	zdb.m.Lock()
+	assert.Equal(t, []byte("value"), zdb.namespaces["private"]["\xaa\xaa"])
+	assert.Empty(t, zdb.namespaces["default"])
+	zdb.m.Unlock()
+
+	// not visible in the default namespace
+	pool = newZdbPool(t, fmt.Sprintf("zdb://%s", zdb.Addr()))
+	_, err = pool.Get(key)
+	assert.Equal(t, ErrNotFound, err)
+
+	// wrong password
+	pool = newZdbPool(t, fmt.Sprintf("zdb://wrong@%s/private", zdb.Addr()))
+	_, err = pool.Get(key)
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestZdbPooling(t *testing.T) {
+	zdb := newTestZdb(t)
+	zdb.namespaces["a"] = map[string][]byte{}
+	zdb.namespaces["b"] = map[string][]byte{}
+
+	pool := newZdbPool(t,
+		fmt.Sprintf("zdb://%s/a", zdb.Addr()),
+		fmt.Sprintf("zdb://%s/a", zdb.Addr()),
+		fmt.Sprintf("zdb://%s/b", zdb.Addr()),
+	)
+
+	for _, rule := range pool.Rules {
+		if _, err := pool.getBackend(rule.Destination); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	assert.Len(t, pool.conn, 2)
+}
+
+func TestZdbRetry(t *testing.T) {
+	zdb := newTestZdb(t)
+	zdb.namespaces["default"]["\xab\xcd\xef"] = []byte("result value")
+	zdb.drops = blockGetRetries - 1
+
+	// each trial gets a new connection, the broken ones are dropped
+	pool := newZdbPool(t, fmt.Sprintf("zdb://%s", zdb.Addr()))
+	data, err := pool.Get(HexToBytes("abcdef"))
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	assert.Equal(t, "result value", string(data))
+
+	zdb.m.Lock()
+	zdb.drops = blockGetRetries
+	zdb.m.Unlock()
+
+	_, err = pool.Get(HexToBytes("abcdef"))
+	assert.Error(t, err)
+}
+
+func TestZdbDestination(t *testing.T) {
+	dest, err := NewDestination("zdb://secret@hub.grid.tf:9900/namespace")
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	assert.Equal(t, "namespace", namespace(dest))
+
+	dest, err = NewDestination("zdb://hub.grid.tf:9900")
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	assert.Equal(t, "", namespace(dest))
+
+	_, err = NewDestination("zdb://hub.grid.tf:9900/a/b")
+	assert.Error(t, err)
+}
@@ -32,6 +32,9 @@ type Router struct {
 	cache  map[string]struct{}
 	o      sync.Once
 	feed   chan chunk
+
+	m      sync.RWMutex
+	closed bool
 }
 
 type chunk struct {
@@ -54,6 +57,7 @@ func (r *Router) worker() {
 				log.Errorf("failed to update cache pool (%s): %s", name, err)
 			}
 		}
+		cacheQueue.Dec()
 	}
 }
 
@@ -67,13 +71,16 @@ func (r *Router) get(key []byte) (string, []byte, error) {
 		data, err := pool.Get(key)
 		//only try next entry if entry is not found in this pool, or not routable
 		//otherwise return (nil, or other errors)
-		if err == ErrNotRoutable || err == redis.ErrNil {
+		if err == ErrNotRoutable || err == ErrNotFound || err == redis.ErrNil {
 			continue
 		} else if err != nil {
 			log.Errorf("pool(%s, %x) : %s", poolName, key, err)
+			poolErrors.WithLabelValues(poolName).Inc()
 			continue
 		}
 
+		poolBlocks.WithLabelValues(poolName).Inc()
+		poolBytes.WithLabelValues(poolName).Add(float64(len(data)))
 		return poolName, data, err
 	}
 
@@ -91,9 +98,71 @@ func (r *Router) updateCache(src string, key []byte, data []byte) {
 		return
 	}
 
+	r.m.RLock()
+	defer r.m.RUnlock()
+	if r.closed {
+		return
+	}
+
+	cacheQueue.Inc()
 	r.feed <- chunk{key: key, data: data}
 }
 
+// Close stops the cache workers and closes the connections of the router pools.
+// Pools are shared by merged routers, so the routers sharing pools should be closed
+// together. The router can still be used after Close but the cache pools are not
+// updated anymore
+func (r *Router) Close() error {
+	r.o.Do(func() {})
+
+	r.m.Lock()
+	defer r.m.Unlock()
+	if r.closed {
+		return nil
+	}
+
+	r.closed = true
+	if r.feed != nil {
+		close(r.feed)
+	}
+
+	for name, pool := range r.pools {
+		if closer, ok := pool.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				log.Errorf("failed to close pool (%s): %s", name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Exists checks if key exists in any of the pools of the table, without
+// downloading it if the pools support it
+func (r *Router) Exists(key []byte) (bool, error) {
+	for _, poolName := range r.lookup {
+		pool, ok := r.pools[poolName]
+		if !ok {
+			return false, ErrPoolNotFound
+		}
+
+		found, err := exists(pool, key)
+		if err == ErrNotRoutable || err == redis.ErrNil {
+			continue
+		} else if err != nil {
+			log.Errorf("pool(%s, %x) : %s", poolName, key, err)
+			poolErrors.WithLabelValues(poolName).Inc()
+			continue
+		}
+
+		if found {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
 // Get gets key from table
 func (r *Router) Get(key []byte) (io.ReadCloser, error) {
 	src, data, err := r.get(key)
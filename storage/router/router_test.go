@@ -1,6 +1,7 @@
 package router
 
 import (
+	"fmt"
 	"io"
 	"sync"
 	"testing"
@@ -217,3 +218,41 @@ func TestMerget(t *testing.T) {
 	}
 
 }
+
+func TestRouterClose(t *testing.T) {
+	store := NewFileStore(t.TempDir())
+	key := HexToBytes("abcdef")
+	if ok := assert.NoError(t, store.Set(key, []byte("value"))); !ok {
+		t.Fatal()
+	}
+
+	addr := newTestServer(t, store)
+	config := Config{
+		Pools: map[string]PoolConfig{
+			"peer": {"00:FF": fmt.Sprintf("zdb://%s/namespace", addr)},
+		},
+		Lookup: []string{"peer"},
+	}
+
+	router, err := config.Router(nil)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	_, err = router.Get(key)
+	assert.NoError(t, err)
+
+	pool := router.pools["peer"].(*ScanPool)
+	assert.Len(t, pool.conn, 1)
+
+	assert.NoError(t, router.Close())
+	assert.NoError(t, router.Close())
+	assert.Nil(t, pool.conn)
+
+	// connections are opened again on demand
+	reader, err := router.Get(key)
+	if ok := assert.NoError(t, err); ok {
+		data, _ := io.ReadAll(reader)
+		assert.Equal(t, []byte("value"), data)
+	}
+}
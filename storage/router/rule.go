@@ -1,13 +1,15 @@
 package router
 
 import (
+	"fmt"
 	"net/url"
+	"strings"
 )
 
 var (
 	//SupportedScheme list of supported url scheme
 	SupportedScheme = []string{
-		"ardb", "zdb", "redis",
+		"ardb", "zdb", "redis", "http", "https", "file",
 	}
 )
 
@@ -41,5 +43,13 @@ func NewDestination(dest string) (Destination, error) {
 		return nil, ErrUnknownScheme
 	}
 
+	if u.Scheme == "file" && len(u.Host) == 0 && len(u.Path) == 0 {
+		return nil, fmt.Errorf("file destination has no directory")
+	}
+
+	if u.Scheme == "zdb" && strings.Contains(strings.Trim(u.Path, "/"), "/") {
+		return nil, fmt.Errorf("invalid zdb namespace '%s'", u.Path)
+	}
+
 	return Destination(u), nil
 }
@@ -0,0 +1,363 @@
+package router
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// maxRequestSize is the max size of all the arguments of a single request,
+	// it's large enough for a SET of the biggest blocks
+	maxRequestSize = 8 * 1024 * 1024
+	// maxArgs is the max number of arguments of a single request
+	maxArgs = 1024
+)
+
+var (
+	errProtocol = errors.New("protocol error")
+	errReadOnly = errors.New("read only store")
+)
+
+// Store is a block store that can be served by a Server. Get returns ErrNotFound
+// if the key doesn't exist. Pools are stores, so are the stores returned by
+// NewFileStore. A store can optionally implement Exists, which is used instead
+// of Get to check if a key exists
+type Store interface {
+	Get(key []byte) ([]byte, error)
+	Set(key, data []byte) error
+}
+
+// NewFileStore creates a store over the local directory dir, with the layout of
+// file:// destinations
+func NewFileStore(dir string) Store {
+	return &fileBackend{root: dir}
+}
+
+type readOnly struct {
+	Store
+}
+
+func (s readOnly) Set(key, data []byte) error {
+	return errReadOnly
+}
+
+// ReadOnly wraps store so all writes are rejected
+func ReadOnly(store Store) Store {
+	return readOnly{store}
+}
+
+// Server serves the blocks of a store over the subset of the redis protocol
+// used by the redis compatible destinations (GET, SET, EXISTS, PING and
+// SELECT). A served store can be listed as a zdb:// (or redis://) pool in the
+// router.yaml of other nodes. There is a single namespace, SELECT accepts any
+// namespace
+type Server struct {
+	store Store
+
+	// open listeners and connections
+	open   map[io.Closer]struct{}
+	closed bool
+	m      sync.Mutex
+}
+
+// NewServer creates a server for store
+func NewServer(store Store) *Server {
+	return &Server{
+		store: store,
+		open:  make(map[io.Closer]struct{}),
+	}
+}
+
+// ListenAndServe listens on the tcp address and serves connections until the
+// server is closed
+func (s *Server) ListenAndServe(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	return s.Serve(listener)
+}
+
+// Serve serves the connections of listener until the server is closed
+func (s *Server) Serve(listener net.Listener) error {
+	if !s.track(listener) {
+		return net.ErrClosed
+	}
+
+	defer s.forget(listener)
+
+	for {
+		con, err := listener.Accept()
+		if err != nil {
+			s.m.Lock()
+			closed := s.closed
+			s.m.Unlock()
+
+			if closed {
+				return net.ErrClosed
+			}
+
+			return err
+		}
+
+		if !s.track(con) {
+			return net.ErrClosed
+		}
+
+		go s.handle(con)
+	}
+}
+
+// Close stops all the listeners and closes all the connections
+func (s *Server) Close() error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.closed = true
+	for c := range s.open {
+		c.Close()
+	}
+
+	return nil
+}
+
+// track adds c to the open listeners and connections, c is closed if the
+// server is already closed
+func (s *Server) track(c io.Closer) bool {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.closed {
+		c.Close()
+		return false
+	}
+
+	s.open[c] = struct{}{}
+	return true
+}
+
+func (s *Server) forget(c io.Closer) {
+	s.m.Lock()
+	delete(s.open, c)
+	s.m.Unlock()
+
+	c.Close()
+}
+
+func (s *Server) handle(con net.Conn) {
+	defer s.forget(con)
+
+	reader := bufio.NewReader(con)
+	writer := bufio.NewWriter(con)
+	for {
+		args, err := readRequest(reader)
+		if err == errProtocol {
+			writeError(writer, "ERR Protocol error")
+			writer.Flush()
+			return
+		} else if err != nil {
+			return
+		}
+
+		if len(args) == 0 {
+			continue
+		}
+
+		quit := s.exec(writer, args)
+		// replies of pipelined requests are sent together
+		if reader.Buffered() == 0 || quit {
+			if err := writer.Flush(); err != nil {
+				return
+			}
+		}
+
+		if quit {
+			return
+		}
+	}
+}
+
+// exec executes a single request, it returns true if the connection must be closed
+func (s *Server) exec(w *bufio.Writer, args [][]byte) bool {
+	cmd := strings.ToLower(string(args[0]))
+	args = args[1:]
+
+	arity := func(min, max int) bool {
+		if len(args) < min || (max >= 0 && len(args) > max) {
+			writeError(w, fmt.Sprintf("ERR wrong number of arguments for '%s' command", cmd))
+			return false
+		}
+
+		return true
+	}
+
+	switch cmd {
+	case "ping":
+		if !arity(0, 1) {
+			break
+		}
+
+		if len(args) == 1 {
+			writeBulk(w, args[0])
+		} else {
+			writeSimple(w, "PONG")
+		}
+	case "select":
+		if arity(1, 2) {
+			writeSimple(w, "OK")
+		}
+	case "get":
+		if !arity(1, 1) {
+			break
+		}
+
+		data, err := s.store.Get(args[0])
+		if err == ErrNotFound {
+			writeBulk(w, nil)
+		} else if err != nil {
+			log.Errorf("failed to get block '%x': %s", args[0], err)
+			writeError(w, "ERR "+err.Error())
+		} else {
+			writeBulk(w, data)
+		}
+	case "set":
+		if !arity(2, 2) {
+			break
+		}
+
+		if err := s.store.Set(args[0], args[1]); err != nil {
+			log.Errorf("failed to set block '%x': %s", args[0], err)
+			writeError(w, "ERR "+err.Error())
+		} else {
+			writeSimple(w, "OK")
+		}
+	case "exists":
+		if !arity(1, -1) {
+			break
+		}
+
+		count := 0
+		for _, key := range args {
+			ok, err := s.exists(key)
+			if err != nil {
+				log.Errorf("failed to check block '%x': %s", key, err)
+				writeError(w, "ERR "+err.Error())
+				return false
+			}
+
+			if ok {
+				count++
+			}
+		}
+
+		fmt.Fprintf(w, ":%d\r\n", count)
+	case "quit":
+		writeSimple(w, "OK")
+		return true
+	default:
+		writeError(w, fmt.Sprintf("ERR unknown command '%s'", cmd))
+	}
+
+	return false
+}
+
+func (s *Server) exists(key []byte) (bool, error) {
+	return exists(s.store, key)
+}
+
+func writeSimple(w io.Writer, s string) {
+	fmt.Fprintf(w, "+%s\r\n", s)
+}
+
+func writeError(w io.Writer, s string) {
+	fmt.Fprintf(w, "-%s\r\n", strings.NewReplacer("\r", " ", "\n", " ").Replace(s))
+}
+
+// writeBulk writes data as a bulk string, or a nil reply if data is nil
+func writeBulk(w io.Writer, data []byte) {
+	if data == nil {
+		io.WriteString(w, "$-1\r\n")
+		return
+	}
+
+	fmt.Fprintf(w, "$%d\r\n", len(data))
+	w.Write(data)
+	io.WriteString(w, "\r\n")
+}
+
+// readLine reads a single line without the line terminator
+func readLine(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return nil, errProtocol
+	} else if err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSuffix(line[:len(line)-1], []byte{'\r'}), nil
+}
+
+// readRequest reads a request, either as an array of bulk strings, or as an
+// inline command (arguments separated by spaces). The size of every argument is
+// checked against what's left of maxRequestSize before it's allocated
+func readRequest(r *bufio.Reader) ([][]byte, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(line) == 0 || line[0] != '*' {
+		var args [][]byte
+		for _, field := range bytes.Fields(line) {
+			args = append(args, append([]byte(nil), field...))
+		}
+
+		return args, nil
+	}
+
+	count, err := strconv.Atoi(string(line[1:]))
+	if err != nil || count < 0 || count > maxArgs {
+		return nil, errProtocol
+	}
+
+	args := make([][]byte, 0, count)
+	budget := maxRequestSize
+	for i := 0; i < count; i++ {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(line) == 0 || line[0] != '$' {
+			return nil, errProtocol
+		}
+
+		size, err := strconv.Atoi(string(line[1:]))
+		if err != nil || size < 0 || size > budget {
+			return nil, errProtocol
+		}
+
+		budget -= size
+
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+
+		if !bytes.HasSuffix(buf, []byte("\r\n")) {
+			return nil, errProtocol
+		}
+
+		args = append(args, buf[:size])
+	}
+
+	return args, nil
+}
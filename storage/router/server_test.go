@@ -0,0 +1,153 @@
+package router
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestServer(t *testing.T, store Store) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := NewServer(store)
+	done := make(chan error)
+	go func() {
+		done <- server.Serve(listener)
+	}()
+
+	t.Cleanup(func() {
+		server.Close()
+		assert.True(t, errors.Is(<-done, net.ErrClosed))
+	})
+
+	return listener.Addr().String()
+}
+
+func TestServer(t *testing.T) {
+	dir := t.TempDir()
+	addr := newTestServer(t, NewFileStore(dir))
+
+	con, err := redis.Dial("tcp", addr)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	defer con.Close()
+
+	reply, err := redis.String(con.Do("PING"))
+	assert.NoError(t, err)
+	assert.Equal(t, "PONG", reply)
+
+	reply, err = redis.String(con.Do("SELECT", "namespace", "password"))
+	assert.NoError(t, err)
+	assert.Equal(t, "OK", reply)
+
+	key := HexToBytes("abcdef")
+	reply, err = redis.String(con.Do("SET", key, "value"))
+	assert.NoError(t, err)
+	assert.Equal(t, "OK", reply)
+
+	data, err := redis.Bytes(con.Do("GET", key))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("value"), data)
+
+	_, err = redis.Bytes(con.Do("GET", HexToBytes("aaaa")))
+	assert.Equal(t, redis.ErrNil, err)
+
+	count, err := redis.Int(con.Do("EXISTS", key, HexToBytes("aaaa")))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	_, err = con.Do("GET")
+	assert.Error(t, err)
+	_, err = con.Do("DEL", key)
+	assert.Error(t, err)
+
+	// the blocks are stored with the layout of file:// destinations
+	pool := newZdbPool(t, "file://"+dir)
+	data, err = pool.Get(key)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("value"), data)
+}
+
+func TestServerPool(t *testing.T) {
+	addr := newTestServer(t, NewFileStore(t.TempDir()))
+
+	// the server can be used as a pool by the redis compatible backends
+	pool := newZdbPool(t, fmt.Sprintf("zdb://secret@%s/namespace", addr))
+	key := HexToBytes("abcdef")
+	if ok := assert.NoError(t, pool.Set(key, []byte("value"))); !ok {
+		t.Fatal()
+	}
+
+	data, err := pool.Get(key)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("value"), data)
+
+	_, err = pool.Get(HexToBytes("aaaa"))
+	assert.Equal(t, ErrNotFound, err)
+
+	found, err := pool.Exists(key)
+	assert.NoError(t, err)
+	assert.True(t, found)
+
+	found, err = pool.Exists(HexToBytes("aaaa"))
+	assert.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestServerReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	key := HexToBytes("abcdef")
+	if ok := assert.NoError(t, NewFileStore(dir).Set(key, []byte("value"))); !ok {
+		t.Fatal()
+	}
+
+	addr := newTestServer(t, ReadOnly(NewFileStore(dir)))
+	con, err := redis.Dial("tcp", addr)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	defer con.Close()
+
+	_, err = con.Do("SET", HexToBytes("aaaa"), "value")
+	assert.Error(t, err)
+
+	data, err := redis.Bytes(con.Do("GET", key))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("value"), data)
+}
+
+func TestReadRequest(t *testing.T) {
+	half := maxRequestSize / 2
+	cases := []struct {
+		name    string
+		request string
+		args    int
+		err     error
+	}{
+		{"inline", "GET key\r\n", 2, nil},
+		{"bulk", "*2\r\n$3\r\nGET\r\n$3\r\nkey\r\n", 2, nil},
+		{"too many arguments", fmt.Sprintf("*%d\r\n", maxArgs+1), 0, errProtocol},
+		{"argument too large", fmt.Sprintf("*1\r\n$%d\r\n", maxRequestSize+1), 0, errProtocol},
+		// the second header is rejected before its payload is read
+		{"request too large", fmt.Sprintf("*3\r\n$3\r\nSET\r\n$%d\r\n%s\r\n$%d\r\n", half, strings.Repeat("a", half), half+1), 0, errProtocol},
+		{"missing terminator", "*1\r\n$3\r\nGETX\r\n", 0, errProtocol},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			args, err := readRequest(bufio.NewReader(strings.NewReader(c.request)))
+			assert.Equal(t, c.err, err)
+			assert.Len(t, args, c.args)
+		})
+	}
+}
@@ -77,3 +77,8 @@ func NewStorage(c io.Reader) (*router.Router, error) {
 type Storage interface {
 	Get(key []byte) (io.ReadCloser, error)
 }
+
+// Setter interface, a storage that data blocks can be uploaded to (like a router.Pool)
+type Setter interface {
+	Set(key []byte, data []byte) error
+}